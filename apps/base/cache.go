@@ -0,0 +1,84 @@
+package base
+
+import (
+	"container/list"
+	"github.com/akiross/gogp/ga"
+	"sync"
+)
+
+// Name of the counter that tracks cache hits (true) and misses (false)
+const CacheHitCounter = "fit-cache-hit"
+
+// A cached value, as saved in a checkpoint
+type CacheEntry struct {
+	Hash       uint64
+	Fitness    ga.Fitness
+	Objectives ga.Objectives
+}
+
+// FitnessCache stores the fitness of the last evaluated trees, keyed by
+// their structural hash, so identical trees are not drawn and scored again.
+// It is safe for concurrent use
+type FitnessCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Most recently used in front
+	entries  map[uint64]*list.Element
+}
+
+// Create a cache holding at most capacity fitness values
+func NewFitnessCache(capacity int) *FitnessCache {
+	return &FitnessCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[uint64]*list.Element),
+	}
+}
+
+// Get the fitness and objectives of the tree with given hash, if cached
+func (c *FitnessCache) Get(hash uint64) (ga.Fitness, ga.Objectives, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[hash]; ok {
+		c.order.MoveToFront(e)
+		ce := e.Value.(*CacheEntry)
+		return ce.Fitness, ce.Objectives, true
+	}
+	return 0, nil, false
+}
+
+// Store the fitness and objectives (possibly nil) of the tree with given
+// hash, evicting the least recently used value if the cache is full
+func (c *FitnessCache) Put(hash uint64, fit ga.Fitness, obj ga.Objectives) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[hash]; ok {
+		e.Value = &CacheEntry{hash, fit, obj}
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[hash] = c.order.PushFront(&CacheEntry{hash, fit, obj})
+	if c.order.Len() > c.capacity {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.entries, last.Value.(*CacheEntry).Hash)
+	}
+}
+
+// Number of cached values
+func (c *FitnessCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// The cached values, least recently used first
+func (c *FitnessCache) Entries() []CacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]CacheEntry, 0, c.order.Len())
+	for e := c.order.Back(); e != nil; e = e.Prev() {
+		entries = append(entries, *e.Value.(*CacheEntry))
+	}
+	return entries
+}
@@ -0,0 +1,111 @@
+package base
+
+import (
+	"github.com/akiross/gogp/ga"
+	"github.com/akiross/gogp/image/draw2d/imgut"
+	"github.com/akiross/gogp/node"
+	"github.com/akiross/gogp/util/stats/counter"
+	"github.com/akiross/gogp/util/stats/sequence"
+)
+
+// State of an individual, as saved in a checkpoint
+type IndividualState struct {
+	Tree       []byte // Tree encoded by node.MarshalJSON
+	Fitness    ga.Fitness
+	Objectives ga.Objectives
+	FitIsValid bool
+}
+
+// State of the population and of its settings, as saved in a checkpoint
+type PopulationState struct {
+	Pop      []IndividualState
+	Best     *IndividualState // Best individual found so far, if any
+	MaxDepth int
+
+	Statistics  map[string]*sequence.SequenceStats
+	Counters    map[string]*counter.BoolCounter
+	IntCounters map[string]*counter.IntCounter
+
+	Cache []CacheEntry // Content of the fitness cache, if used
+}
+
+func (ind *Individual) state() (*IndividualState, error) {
+	tree, err := ind.Node.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	return &IndividualState{tree, ind.fitness, ind.objectives, ind.fitIsValid}, nil
+}
+
+func (pop *Population) restoreIndividual(st *IndividualState, lookup node.Lookup) (*Individual, error) {
+	tree, err := node.Unmarshal(st.Tree, lookup)
+	if err != nil {
+		return nil, err
+	}
+	tmpImg := imgut.Create(pop.Set.ImgTarget.W, pop.Set.ImgTarget.H, pop.Set.ImgTarget.ColorSpace)
+	return &Individual{tree, st.Fitness, st.Objectives, nil, st.FitIsValid, pop.Set, tmpImg}, nil
+}
+
+// Save the state of the population, including fitness values and statistics
+func (pop *Population) Checkpoint() (*PopulationState, error) {
+	var st PopulationState
+	st.Pop = make([]IndividualState, len(pop.Pop))
+	for i := range pop.Pop {
+		is, err := pop.Pop[i].state()
+		if err != nil {
+			return nil, err
+		}
+		st.Pop[i] = *is
+	}
+	if pop.best != nil {
+		var err error
+		if st.Best, err = pop.best.state(); err != nil {
+			return nil, err
+		}
+	}
+	st.MaxDepth = pop.Set.MaxDepth
+	st.Statistics = pop.Set.Statistics
+	st.Counters = pop.Set.Counters
+	st.IntCounters = pop.Set.IntCounters
+	if pop.Set.Cache != nil {
+		st.Cache = pop.Set.Cache.Entries()
+	}
+	return &st, nil
+}
+
+// Restore the population and the statistics in its settings from a saved state.
+// Trees are rebuilt resolving primitives with lookup
+func (pop *Population) Restore(st *PopulationState, lookup node.Lookup) error {
+	pop.Pop = make([]*Individual, len(st.Pop))
+	for i := range st.Pop {
+		ind, err := pop.restoreIndividual(&st.Pop[i], lookup)
+		if err != nil {
+			return err
+		}
+		pop.Pop[i] = ind
+	}
+	pop.best = nil
+	if st.Best != nil {
+		best, err := pop.restoreIndividual(st.Best, lookup)
+		if err != nil {
+			return err
+		}
+		pop.best = best
+	}
+	pop.Set.MaxDepth = st.MaxDepth
+	for k, v := range st.Statistics {
+		pop.Set.Statistics[k] = v
+	}
+	for k, v := range st.Counters {
+		pop.Set.Counters[k] = v
+	}
+	for k, v := range st.IntCounters {
+		pop.Set.IntCounters[k] = v
+	}
+	if pop.Set.Cache != nil {
+		for _, e := range st.Cache {
+			pop.Set.Cache.Put(e.Hash, e.Fitness, e.Objectives)
+		}
+	}
+	return nil
+}
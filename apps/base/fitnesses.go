@@ -26,3 +26,113 @@ func fitnessRMSEImage(ind, targ *imgut.Image) float64 {
 func fitnessLinearScalingRMSE(ind, targ *imgut.Image) float64 {
 	return 0.0
 }
+
+// Channels compared on img: all the colours, or one on gray scale images
+func colourChans(img *imgut.Image) string {
+	if img.ColorSpace == imgut.MODE_RGB || img.ColorSpace == imgut.MODE_RGBA {
+		return "RGB"
+	}
+	return "R"
+}
+
+// Weighted RMSE on data with 3 interleaved channels. Each channel error is
+// weighted, equal weights give the plain RMSE
+func weightedRMSE(dataInd, dataTarg, weights []float64) float64 {
+	var acc, wsum float64
+	for c := 0; c < 3; c++ {
+		wsum += weights[c]
+	}
+	for i := range dataInd {
+		d := dataInd[i] - dataTarg[i]
+		acc += weights[i%3] * d * d
+	}
+	return math.Sqrt(3 * acc / wsum / float64(len(dataInd)))
+}
+
+// Use default weights if none are specified
+func channelWeights(weights, defaults []float64) []float64 {
+	if len(weights) == 0 {
+		return defaults
+	}
+	if len(weights) != 3 {
+		panic("Exactly 3 channel weights are required")
+	}
+	sum := weights[0] + weights[1] + weights[2]
+	if !(weights[0] >= 0 && weights[1] >= 0 && weights[2] >= 0) || !(sum > 0) || math.IsInf(sum, 1) {
+		panic("Channel weights must be finite, non negative and not all zero")
+	}
+	return weights
+}
+
+// RMSE on all the RGB channels, optionally weighted (nil for equal weights)
+func MakeFitRGB(targetImage *imgut.Image, weights []float64) func(*imgut.Image) float64 {
+	dataTarg := imgut.ToSliceChans(targetImage, "RGB")
+	weights = channelWeights(weights, []float64{1, 1, 1})
+	return func(indImage *imgut.Image) float64 {
+		return weightedRMSE(imgut.ToSliceChans(indImage, "RGB"), dataTarg, weights)
+	}
+}
+
+// RMSE in YCbCr space. By default luma counts as much as both chroma channels,
+// since the eye is more sensitive to it
+func MakeFitYCbCr(targetImage *imgut.Image, weights []float64) func(*imgut.Image) float64 {
+	dataTarg := imgut.ToSliceYCbCr(targetImage)
+	weights = channelWeights(weights, []float64{2, 1, 1})
+	return func(indImage *imgut.Image) float64 {
+		return weightedRMSE(imgut.ToSliceYCbCr(indImage), dataTarg, weights)
+	}
+}
+
+// Mean CIE76 colour difference (Delta E) in CIELAB space. Weights, if
+// specified, scale the squared differences of L*, a* and b*
+func MakeFitLab(targetImage *imgut.Image, weights []float64) func(*imgut.Image) float64 {
+	dataTarg := imgut.ToSliceLab(targetImage)
+	weights = channelWeights(weights, []float64{1, 1, 1})
+	return func(indImage *imgut.Image) float64 {
+		dataInd := imgut.ToSliceLab(indImage)
+		var acc float64
+		for i := 0; i < len(dataInd); i += 3 {
+			var de float64
+			for c := 0; c < 3; c++ {
+				d := dataInd[i+c] - dataTarg[i+c]
+				de += weights[c] * d * d
+			}
+			acc += math.Sqrt(de)
+		}
+		return acc / float64(len(dataInd)/3)
+	}
+}
+
+// Errors on separate test cases, to be used as Settings.CaseFunc. Every case
+// is a block x block tile of the image (the ones on the borders may be
+// smaller), and its error is the RMSE of the tile on all the colours.
+// Use block 1 for pixels
+func MakeCaseErrors(targetImage *imgut.Image, block int) func(*imgut.Image) []float64 {
+	if block < 1 {
+		panic("Tiles must be at least 1 pixel wide")
+	}
+	w, h := targetImage.W, targetImage.H
+	chans := colourChans(targetImage)
+	nc := len(chans)
+	dataTarg := imgut.ToSliceChans(targetImage, chans)
+	cols, rows := (w+block-1)/block, (h+block-1)/block
+	return func(indImage *imgut.Image) []float64 {
+		dataInd := imgut.ToSliceChans(indImage, chans)
+		errs := make([]float64, cols*rows)
+		count := make([]float64, cols*rows)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				c := (y/block)*cols + x/block
+				for k := (y*w + x) * nc; k < (y*w+x+1)*nc; k++ {
+					d := dataInd[k] - dataTarg[k]
+					errs[c] += d * d
+					count[c]++
+				}
+			}
+		}
+		for c := range errs {
+			errs[c] = math.Sqrt(errs[c] / count[c])
+		}
+		return errs
+	}
+}
@@ -2,6 +2,7 @@ package base
 
 import (
 	"github.com/akiross/gogp/image/draw2d/imgut"
+	"math"
 	"math/rand"
 	"testing"
 	"time"
@@ -23,3 +24,91 @@ func TestRMSE(t *testing.T) {
 	rmseImg := fitnessRMSEImage(img1, img2)
 	t.Log("Vec RMSE", rmseVec, rmseImg)
 }
+
+func TestColourFitness(t *testing.T) {
+	white := imgut.Create(4, 4, imgut.MODE_RGBA)
+	white.FillSurface(1, 1, 1)
+	red := imgut.Create(4, 4, imgut.MODE_RGBA)
+	red.FillSurface(1, 0, 0)
+
+	for name, fit := range map[string]func(*imgut.Image) float64{
+		"rgb":   MakeFitRGB(white, nil),
+		"ycbcr": MakeFitYCbCr(white, nil),
+		"lab":   MakeFitLab(white, nil),
+	} {
+		if v := fit(white); v != 0 {
+			t.Error(name, "fitness of the target should be 0, got", v)
+		}
+		if v := fit(red); v <= 0 {
+			t.Error(name, "fitness of a different colour should be positive, got", v)
+		}
+	}
+	// Red differs only in green and blue
+	if v := MakeFitRGB(white, []float64{1, 0, 0})(red); v != 0 {
+		t.Error("Weighted fitness should ignore green and blue, got", v)
+	}
+	if v := MakeFitRGB(white, nil)(red); math.Abs(v-255*math.Sqrt(2.0/3)) > 1e-9 {
+		t.Error("Wrong RGB fitness", v)
+	}
+
+	for _, w := range [][]float64{{0, 0, 0}, {1, -1, 1}, {1, math.NaN(), 1}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected panic for weights", w)
+				}
+			}()
+			MakeFitRGB(white, w)
+		}()
+	}
+}
+
+func TestSSIMColours(t *testing.T) {
+	white := imgut.Create(16, 16, imgut.MODE_RGB)
+	white.FillSurface(1, 1, 1)
+	red := imgut.Create(16, 16, imgut.MODE_RGB)
+	red.FillSurface(1, 0, 0)
+	for name, fit := range map[string]func(*imgut.Image) float64{
+		"ssim":   MakeFitSSIM(white),
+		"msssim": MakeFitMSSSIM(white),
+	} {
+		if v := fit(white); math.Abs(v) > 1e-9 {
+			t.Error(name, "fitness of the target should be 0, got", v)
+		}
+		// Red and white differ only in green and blue
+		if v := fit(red); v <= 0 {
+			t.Error(name, "fitness of a different colour should be positive, got", v)
+		}
+	}
+}
+
+func TestCaseErrors(t *testing.T) {
+	targ := imgut.Create(5, 3, imgut.MODE_RGBA)
+	targ.FillSurface(0, 0, 0)
+	img := imgut.Create(5, 3, imgut.MODE_RGBA)
+	img.FillSurface(0, 0, 0)
+	img.FillRect(4, 0, 5, 3, 1, 1, 1) // Last column is white
+
+	errs := MakeCaseErrors(targ, 2)(img)
+	// 3 x 2 tiles, the ones on the right are 1 pixel wide
+	exp := []float64{0, 0, 255, 0, 0, 255}
+	if len(errs) != len(exp) {
+		t.Fatal("Expected", len(exp), "cases, got", len(errs))
+	}
+	for i := range exp {
+		if math.Abs(errs[i]-exp[i]) > 1e-9 {
+			t.Error("Wrong error on case", i, errs[i], "expected", exp[i])
+		}
+	}
+	if n := len(MakeCaseErrors(targ, 1)(img)); n != 15 {
+		t.Error("Expected a case per pixel, got", n)
+	}
+
+	// Colours are compared on every channel
+	img.FillSurface(1, 0, 0)
+	for i, e := range MakeCaseErrors(targ, 5)(img) {
+		if math.Abs(e-255/math.Sqrt(3)) > 1e-9 {
+			t.Error("Wrong error on coloured case", i, e)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package base
 
 import (
-	"bytes"
 	"fmt"
 	"github.com/akiross/gogp/ga"
 	"github.com/akiross/gogp/gp"
@@ -10,12 +9,9 @@ import (
 	"github.com/akiross/gogp/util/stats/counter"
 	"github.com/akiross/gogp/util/stats/sequence"
 	"github.com/gonum/floats"
-	"io/ioutil"
 	"math"
-	"os"
-	"os/exec"
-	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type Settings struct {
@@ -37,25 +33,86 @@ type Settings struct {
 
 	// Fitness func for one individual
 	FitFunc func(ind *imgut.Image) float64
+	// Objectives for multi-objective optimization, computed after FitFunc
+	// when the individual is drawn on ImgTemp. Nil if not used
+	ObjFunc func(ind *Individual) ga.Objectives
+	// Errors on separate test cases (e.g. image tiles), computed on the drawn
+	// individual and used by lexicase selection. Nil if not used
+	CaseFunc func(ind *imgut.Image) []float64
 
 	// Operators used in evolution
 	GenFunc   func(int) *node.Node // Generate tree
 	Select    func([]*Individual, int) []ga.Individual
 	CrossOver func(float64, *Individual, *Individual) bool
 	Mutate    func(float64, *Individual) bool
-
-	// These hold general purpose statistics for debugging purposes
+	// Build a simplified copy of a tree, nil if not available
+	Simplify func(*node.Node) *node.Node
+	// Build the full tree of a tree referencing other trees, to be saved.
+	// Nil if trees are self-contained
+	Expand func(*node.Node) (*node.Node, error)
+
+	// Number of goroutines used to evaluate the population
+	Workers int
+	// Fitness of already seen trees, nil to always evaluate
+	Cache *FitnessCache
+	// Evaluations not yet reported by Population.Evaluate, updated atomically
+	evaluations int64
+
+	// These hold general purpose statistics for debugging purposes.
+	// Use Sequence, Counter and IntCounter when running in parallel
 	Statistics  map[string]*sequence.SequenceStats // Float values
 	Counters    map[string]*counter.BoolCounter    // Count events
 	IntCounters map[string]*counter.IntCounter     // Count ints
+	statsMu     sync.Mutex                         // Guards the maps above
 
 	// Minimization problem
 	ga.MinProblem
 }
 
+// Get the named statistic, creating it if missing. Safe for concurrent use
+func (s *Settings) Sequence(name string) *sequence.SequenceStats {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	if s.Statistics == nil {
+		s.Statistics = make(map[string]*sequence.SequenceStats)
+	}
+	if _, ok := s.Statistics[name]; !ok {
+		s.Statistics[name] = sequence.Create()
+	}
+	return s.Statistics[name]
+}
+
+// Get the named counter, creating it if missing. Safe for concurrent use
+func (s *Settings) Counter(name string) *counter.BoolCounter {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	if s.Counters == nil {
+		s.Counters = make(map[string]*counter.BoolCounter)
+	}
+	if _, ok := s.Counters[name]; !ok {
+		s.Counters[name] = new(counter.BoolCounter)
+	}
+	return s.Counters[name]
+}
+
+// Get the named int counter, creating it if missing. Safe for concurrent use
+func (s *Settings) IntCounter(name string) *counter.IntCounter {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	if s.IntCounters == nil {
+		s.IntCounters = make(map[string]*counter.IntCounter)
+	}
+	if _, ok := s.IntCounters[name]; !ok {
+		s.IntCounters[name] = new(counter.IntCounter)
+	}
+	return s.IntCounters[name]
+}
+
 type Individual struct {
 	Node       *node.Node
 	fitness    ga.Fitness
+	objectives ga.Objectives
+	cases      []float64 // Errors on test cases, nil if not evaluated
 	fitIsValid bool
 	set        *Settings
 	ImgTemp    *imgut.Image // where to render the individual
@@ -72,11 +129,48 @@ func (ind *Individual) MarshalJSON() ([]byte, error) {
 // This method should always return the current futness
 // Possibly caching evaluated results
 func (ind *Individual) Fitness() ga.Fitness {
-	if !ind.fitIsValid {
-		ind.fitness = ind.Evaluate()
+	ind.updateFitness()
+	return ind.fitness
+}
+
+// Compute the fitness if not valid, looking in the cache if there is one.
+// Returns true if the individual was actually evaluated
+func (ind *Individual) updateFitness() bool {
+	if ind.fitIsValid {
+		return false
+	}
+	cache := ind.set.Cache
+	if cache == nil {
+		ind.fitness, ind.objectives = ind.evaluate()
 		ind.fitIsValid = true
+		atomic.AddInt64(&ind.set.evaluations, 1)
+		return true
 	}
-	return ind.fitness
+	hash := ind.Node.Hash()
+	fit, obj, hit := cache.Get(hash)
+	ind.CountEvent(CacheHitCounter, hit)
+	if !hit {
+		fit, obj = ind.evaluate()
+		cache.Put(hash, fit, obj)
+		atomic.AddInt64(&ind.set.evaluations, 1)
+	}
+	ind.fitness, ind.objectives, ind.fitIsValid = fit, obj, true
+	return !hit
+}
+
+// Compute fitness and, if used, objectives
+func (ind *Individual) evaluate() (ga.Fitness, ga.Objectives) {
+	fit := ind.Evaluate()
+	if ind.set.ObjFunc == nil {
+		return fit, nil
+	}
+	return fit, ind.set.ObjFunc(ind)
+}
+
+// Objectives of the individual, evaluated if necessary
+func (ind *Individual) Objectives() ga.Objectives {
+	ind.updateFitness()
+	return ind.objectives
 }
 
 // Returns true if ind is better than i
@@ -86,7 +180,7 @@ func (ind *Individual) BetterThan(i *Individual) bool {
 
 func (ind *Individual) Copy() ga.Individual {
 	tmpImg := imgut.Create(ind.set.ImgTarget.W, ind.set.ImgTarget.H, ind.set.ImgTarget.ColorSpace)
-	return &Individual{ind.Node.Copy(), ind.fitness, ind.fitIsValid, ind.set, tmpImg}
+	return &Individual{ind.Node.Copy(), ind.fitness, ind.objectives, ind.cases, ind.fitIsValid, ind.set, tmpImg}
 }
 
 func (ind *Individual) Crossover(pCross float64, mate ga.Individual) {
@@ -162,14 +256,25 @@ func MakeFitLinScale(targetImage *imgut.Image) func(*imgut.Image) float64 {
 	}
 }
 
-func MakeFitEdge(targetImage *imgut.Image, stats map[string]*sequence.SequenceStats) func(*imgut.Image) float64 {
-	// Compute edge detection
-	edgeKern := &imgut.ConvolutionMatrix{3, []float64{
+// Laplacian kernel used for edge detection
+func makeEdgeKernel() *imgut.ConvolutionMatrix {
+	return &imgut.ConvolutionMatrix{3, []float64{
 		0, 1, 0,
 		1, -4, 1,
 		0, 1, 0},
 	}
+}
+
+func MakeFitEdge(targetImage *imgut.Image, stats map[string]*sequence.SequenceStats) func(*imgut.Image) float64 {
+	// Compute edge detection
+	edgeKern := makeEdgeKernel()
 	targEdge := imgut.ApplyConvolution(edgeKern, targetImage)
+	// Statistics are created now, so the map is not written concurrently
+	for _, k := range []string{"sub-fit-plain", "sub-fit-edged"} {
+		if _, ok := stats[k]; !ok {
+			stats[k] = sequence.Create()
+		}
+	}
 	// Function to compute RMSE
 	rmseFit := MakeFitRMSE(targetImage)
 	return func(indImg *imgut.Image) float64 {
@@ -181,70 +286,67 @@ func MakeFitEdge(targetImage *imgut.Image, stats map[string]*sequence.SequenceSt
 		edRmse := imgut.PixelRMSE(imgEdge, targEdge)
 
 		// Statistics on output values
-		if _, ok := stats["sub-fit-plain"]; !ok {
-			stats["sub-fit-plain"] = sequence.Create()
-		}
 		stats["sub-fit-plain"].Observe(rmse)
-
-		if _, ok := stats["sub-fit-edged"]; !ok {
-			stats["sub-fit-edged"] = sequence.Create()
-		}
 		stats["sub-fit-edged"].Observe(edRmse)
 		// Weighted fitness
 		return rmse * edRmse
 	}
 }
 
+// Objectives for multi-objective optimization: RMSE, tree size and RMSE of edges
+func MakeObjectives(targetImage *imgut.Image) func(*Individual) ga.Objectives {
+	edgeKern := makeEdgeKernel()
+	targEdge := imgut.ApplyConvolution(edgeKern, targetImage)
+	rmseFit := MakeFitRMSE(targetImage)
+	return func(ind *Individual) ga.Objectives {
+		imgEdge := imgut.ApplyConvolution(edgeKern, ind.ImgTemp)
+		return ga.Objectives{rmseFit(ind.ImgTemp), float64(node.Size(ind.Node)), imgut.PixelRMSE(imgEdge, targEdge)}
+	}
+}
+
+// Structural dissimilarity, DSSIM = (1 - SSIM) / 2, averaged on the colours
 func MakeFitSSIM(targetImage *imgut.Image) func(*imgut.Image) float64 {
+	ssim := imgut.MakeSSIM(targetImage, colourChans(targetImage))
 	return func(indImage *imgut.Image) float64 {
-		// Create temporary files
-		tarTemp, _ := ioutil.TempFile(".", "tarImg")
-		indTemp, _ := ioutil.TempFile(".", "indImg")
-		// Schedule for cleaning
-		defer os.Remove(tarTemp.Name())
-		defer os.Remove(indTemp.Name())
-		tarTemp.Close()
-		indTemp.Close()
-		// Save images to those files
-		targetImage.WritePNG(tarTemp.Name())
-		indImage.WritePNG(indTemp.Name())
-
-		// Compute the DSSIM value (dissimilarity)
-		cmd := exec.Command("./dssim.exe", tarTemp.Name(), indTemp.Name())
-		var out, stderr bytes.Buffer
-		cmd.Stdout = &out
-		cmd.Stderr = &stderr
-		err := cmd.Run()
-		if err != nil {
-			print("ERRORE CON LA CALL", stderr.String())
-			ooo, _ := cmd.CombinedOutput()
-			print("Output ")
-			print(ooo)
-			panic(err)
-		}
-		res := strings.Split(out.String(), "\t")[0]
-		fit, err := strconv.ParseFloat(res, 64)
-		if err != nil {
-			print("ERROR CON CONVERT")
-			panic(err)
-		}
-		return fit
+		return (1 - ssim(indImage)) / 2
+	}
+}
+
+// Like MakeFitSSIM, but using multi-scale SSIM
+func MakeFitMSSSIM(targetImage *imgut.Image) func(*imgut.Image) float64 {
+	msssim := imgut.MakeMSSSIM(targetImage, colourChans(targetImage))
+	return func(indImage *imgut.Image) float64 {
+		return (1 - msssim(indImage)) / 2
 	}
 }
 
 // This method evaluates the current genotype and returns its fitness
 // without caching the results (i.e. fitnessIsValid is NOT read or written)
+// Errors on test cases are recorded, if Settings.CaseFunc is used
 func (ind *Individual) Evaluate() ga.Fitness {
-	ind.set.Draw(ind, ind.ImgTemp)                  // Draw individual
+	ind.set.Draw(ind, ind.ImgTemp) // Draw individual
+	if ind.set.CaseFunc != nil {
+		ind.cases = ind.set.CaseFunc(ind.ImgTemp)
+	}
 	return ga.Fitness(ind.set.FitFunc(ind.ImgTemp)) // Evaluate fit
 }
 
+// Errors on the test cases, drawing the individual if they were not recorded
+// (e.g. when the fitness was cached). Nil if Settings.CaseFunc is nil
+func (ind *Individual) Cases() []float64 {
+	if ind.cases == nil && ind.set.CaseFunc != nil {
+		ind.Evaluate()
+	}
+	return ind.cases
+}
+
 func (ind *Individual) FitnessValid() bool {
 	return ind.fitIsValid
 }
 
 func (ind *Individual) Invalidate() {
 	ind.fitIsValid = false
+	ind.cases = nil
 }
 
 func (ind *Individual) Initialize() {
@@ -260,8 +362,5 @@ func (ind *Individual) Mutate(pMut float64) {
 
 func (ind *Individual) CountEvent(name string, e bool) {
 	// Statistics on output values
-	if _, ok := ind.set.Counters[name]; !ok {
-		ind.set.Counters[name] = new(counter.BoolCounter)
-	}
-	ind.set.Counters[name].Count(e)
+	ind.set.Counter(name).Count(e)
 }
@@ -3,6 +3,11 @@ package base
 import (
 	"github.com/akiross/gogp/ga"
 	"github.com/akiross/gogp/image/draw2d/imgut"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
 )
 
 type ParamError struct {
@@ -28,23 +33,69 @@ func (pop *Population) BestIndividual() ga.Individual {
 func (pop *Population) Get(i int) ga.Individual {
 	return pop.Pop[i]
 }
+
+func (pop *Population) Replace(i int, ind ga.Individual) {
+	pop.Pop[i] = ind.(*Individual)
+}
+
+// Bind an individual of another population to the settings of this one
+// (e.g. target image and fitness), invalidating its fitness
+func (pop *Population) Adopt(ind ga.Individual) {
+	i := ind.(*Individual)
+	i.set = pop.Set
+	i.ImgTemp = imgut.Create(pop.Set.ImgTarget.W, pop.Set.ImgTarget.H, pop.Set.ImgTarget.ColorSpace)
+	i.Invalidate()
+}
+
 func (pop *Population) Size() int {
 	return len(pop.Pop)
 }
 
-// Evaluate population fitness, return number of evaluations
-func (pop *Population) Evaluate() (fitnessEval int) {
-	for i := range pop.Pop {
-		var ind *Individual = pop.Pop[i]
+// Evaluate population fitness, return number of evaluations since the last
+// call, including the ones done while breeding.
+// Individuals are evaluated in parallel using Set.Workers goroutines
+func (pop *Population) Evaluate() int {
+	// Collect the individuals that need an evaluation
+	var invalid []*Individual
+	for _, ind := range pop.Pop {
 		if !ind.FitnessValid() {
-			ind.Evaluate()
-			fitnessEval++
+			invalid = append(invalid, ind)
 		}
+	}
+
+	workers := pop.Set.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(invalid) {
+		workers = len(invalid)
+	}
+	// Every individual renders on its own ImgTemp, so they are independent
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				invalid[i].updateFitness()
+			}
+		}()
+	}
+	for i := range invalid {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Pick the best in order, so ties are broken regardless of scheduling
+	for _, ind := range pop.Pop {
 		if pop.best == nil || pop.Set.BetterThan(ind.fitness, pop.best.fitness) {
 			pop.best = ind
 		}
 	}
-	return
+	// Fitness found in the cache is not counted as an evaluation
+	return int(atomic.SwapInt64(&pop.Set.evaluations, 0))
 }
 
 func (pop *Population) Initialize(n int) {
@@ -143,6 +194,90 @@ func MakeSelectIRMAD(tournSize, divSize int, betterFit func(a, b ga.Fitness) boo
 	}
 }
 
+// Median absolute deviation of the values
+func medianAbsDev(values []float64) float64 {
+	median := func(v []float64) float64 {
+		sort.Float64s(v)
+		if len(v)%2 == 1 {
+			return v[len(v)/2]
+		}
+		return (v[len(v)/2-1] + v[len(v)/2]) / 2
+	}
+	v := append([]float64{}, values...)
+	m := median(v)
+	for i := range v {
+		v[i] = math.Abs(v[i] - m)
+	}
+	return median(v)
+}
+
+// Lexicase selection, using the errors on test cases of the individuals (see
+// Settings.CaseFunc). Each parent is picked considering the cases in random
+// order and keeping only the individuals with the lowest error on each case,
+// until one is left or the cases are over, then picking one at random.
+// With epsilon lexicase, individuals whose error is within the median
+// absolute deviation of the errors on the case from the lowest are kept too
+func MakeSelectLexicase(epsilon bool) func([]*Individual, int) []ga.Individual {
+	return func(oldPop []*Individual, selectionSize int) []ga.Individual {
+		errs := make([][]float64, len(oldPop))
+		for i := range oldPop {
+			errs[i] = oldPop[i].Cases()
+		}
+		nCases := len(errs[0])
+		// Tolerance on each case
+		eps := make([]float64, nCases)
+		if epsilon {
+			col := make([]float64, len(oldPop))
+			for c := range eps {
+				for i := range errs {
+					col[i] = errs[i][c]
+				}
+				eps[c] = medianAbsDev(col)
+			}
+		}
+
+		newPop := make([]ga.Individual, selectionSize)
+		pool := make([]int, len(oldPop))
+		for s := range newPop {
+			// Start from the whole population
+			cand := pool[:len(oldPop)]
+			for i := range cand {
+				cand[i] = i
+			}
+			for _, c := range rand.Perm(nCases) {
+				if len(cand) == 1 {
+					break
+				}
+				best := math.Inf(1)
+				for _, i := range cand {
+					best = math.Min(best, errs[i][c])
+				}
+				// Filter in place
+				kept := cand[:0]
+				for _, i := range cand {
+					if errs[i][c] <= best+eps[c] {
+						kept = append(kept, i)
+					}
+				}
+				cand = kept
+			}
+			newPop[s] = oldPop[cand[rand.Intn(len(cand))]].Copy()
+		}
+		return newPop
+	}
+}
+
+// NSGA-II selection, individuals are ranked using their objectives
+func MakeSelectNSGA2() func([]*Individual, int) []ga.Individual {
+	return func(oldPop []*Individual, selectionSize int) []ga.Individual {
+		inds := make([]ga.Individual, len(oldPop))
+		for i := range oldPop {
+			inds[i] = oldPop[i]
+		}
+		return ga.SelectNSGA2(inds, selectionSize)
+	}
+}
+
 func (pop *Population) Select(n int, gen float32) ([]ga.Individual, error) {
 	if n < 1 {
 		return nil, &ParamError{"Cannot have selectionSize < 1"}
@@ -0,0 +1,240 @@
+package base
+
+import (
+	"github.com/akiross/gogp/ga"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/image/draw2d/imgut"
+	"github.com/akiross/gogp/node"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestParallelEvaluate(t *testing.T) {
+	var set Settings
+	set.ImgTarget = imgut.Create(8, 8, imgut.MODE_RGBA)
+	set.ImgTarget.FillSurface(0, 0, 0)
+	set.FitFunc = MakeFitRMSE(set.ImgTarget)
+	set.Workers = 4
+
+	// Every individual is drawn with its own gray level, two of them are best
+	levels := []float64{0.9, 0.5, 0.2, 0.7, 0.2, 1, 0.4, 0.3}
+	pop := &Population{Set: &set}
+	gray := make(map[*Individual]float64)
+	for _, l := range levels {
+		ind := &Individual{set: &set, ImgTemp: imgut.Create(8, 8, imgut.MODE_RGBA)}
+		pop.Pop = append(pop.Pop, ind)
+		gray[ind] = l
+	}
+	set.Draw = func(ind *Individual, img *imgut.Image) {
+		img.FillSurface(gray[ind], gray[ind], gray[ind])
+	}
+
+	if n := pop.Evaluate(); n != len(levels) {
+		t.Error("Expected", len(levels), "evaluations, got", n)
+	}
+	if pop.BestIndividual() != ga.Individual(pop.Pop[2]) {
+		t.Error("Best individual is not the first of the best ones")
+	}
+	for i, ind := range pop.Pop {
+		if !ind.FitnessValid() || ind.fitness != ind.Evaluate() {
+			t.Error("Wrong fitness for individual", i)
+		}
+	}
+
+	pop.Pop[5].Invalidate()
+	if n := pop.Evaluate(); n != 1 {
+		t.Error("Expected 1 evaluation, got", n)
+	}
+
+	// Evaluations done outside Evaluate, e.g. while breeding, are counted too
+	pop.Pop[3].Invalidate()
+	pop.Pop[3].Fitness()
+	if n := pop.Evaluate(); n != 1 {
+		t.Error("Expected 1 evaluation, got", n)
+	}
+	if n := pop.Evaluate(); n != 0 {
+		t.Error("Expected no evaluations, got", n)
+	}
+
+	// An individual adopted by another population uses its settings
+	other := &Settings{Draw: set.Draw, Workers: 1}
+	other.ImgTarget = imgut.Create(4, 4, imgut.MODE_RGBA)
+	other.FitFunc = MakeFitRMSE(other.ImgTarget)
+	otherPop := &Population{Set: other}
+	ind := &Individual{fitIsValid: true, set: &set, ImgTemp: imgut.Create(8, 8, imgut.MODE_RGBA)}
+	otherPop.Adopt(ind)
+	if ind.set != other || ind.ImgTemp.W != 4 || ind.FitnessValid() {
+		t.Error("Adopted individual is bound to the old population")
+	}
+	otherPop.Pop = append(otherPop.Pop, ind)
+	if n, m := pop.Evaluate(), otherPop.Evaluate(); n != 0 || m != 1 {
+		t.Error("Adopted individual evaluations counted", n, "and", m, "times, expected 0 and 1")
+	}
+}
+
+func TestFitnessCache(t *testing.T) {
+	c := NewFitnessCache(2)
+	c.Put(1, 10, nil)
+	c.Put(2, 20, nil)
+	c.Get(1) // 2 is now the least recently used
+	c.Put(3, 30, nil)
+	if _, _, ok := c.Get(2); ok {
+		t.Error("Least recently used value was not evicted")
+	}
+	if f, _, ok := c.Get(1); !ok || f != 10 {
+		t.Error("Expected fitness 10, got", f, ok)
+	}
+	if c.Len() != 2 {
+		t.Error("Cache has", c.Len(), "values, expected 2")
+	}
+
+	// Restored caches evict in the same order
+	r := NewFitnessCache(2)
+	for _, e := range c.Entries() {
+		r.Put(e.Hash, e.Fitness, e.Objectives)
+	}
+	c.Put(4, 40, nil)
+	r.Put(4, 40, nil)
+	for _, h := range []uint64{1, 3, 4} {
+		_, _, ok1 := c.Get(h)
+		_, _, ok2 := r.Get(h)
+		if ok1 != ok2 {
+			t.Error("Restored cache differs on", h)
+		}
+	}
+}
+
+func TestLexicase(t *testing.T) {
+	var set Settings
+	set.ImgTarget = imgut.Create(2, 2, imgut.MODE_RGBA)
+	// Specialists are the best on one case each, the generalist is never
+	// the best but has the lowest total error
+	cases := [][]float64{
+		{0, 10, 10},
+		{10, 0, 10},
+		{10, 10, 0},
+		{1, 1, 1},
+	}
+	var pop []*Individual
+	for i, c := range cases {
+		pop = append(pop, &Individual{Node: new(node.Node), fitness: ga.Fitness(i), cases: c, set: &set})
+	}
+	picked := make(map[ga.Fitness]int)
+	for _, ind := range MakeSelectLexicase(false)(pop, 300) {
+		picked[ind.(*Individual).fitness]++
+	}
+	if picked[3] != 0 {
+		t.Error("Lexicase selected the generalist", picked[3], "times")
+	}
+	for i := 0; i < 3; i++ {
+		if picked[ga.Fitness(i)] == 0 {
+			t.Error("Lexicase never selected specialist", i)
+		}
+	}
+
+	// With epsilon lexicase, errors close to the best are tolerated
+	pop[3].cases = []float64{0.5, 0.5, 0.5}
+	picked = make(map[ga.Fitness]int)
+	for _, ind := range MakeSelectLexicase(true)(pop, 300) {
+		picked[ind.(*Individual).fitness]++
+	}
+	if picked[3] == 0 {
+		t.Error("Epsilon lexicase never selected the generalist")
+	}
+}
+
+// Primitives that do nothing, used only to build trees
+type namedPrim struct {
+	name  string
+	arity int
+}
+
+func (p namedPrim) IsFunctional() bool                 { return p.arity > 0 }
+func (p namedPrim) IsEphemeral() bool                  { return false }
+func (p namedPrim) Arity() int                         { return p.arity }
+func (p namedPrim) Run(_ ...gp.Primitive) gp.Primitive { return p }
+func (p namedPrim) Name() string                       { return p.name }
+
+func TestSeed(t *testing.T) {
+	reg := gp.NewRegistry()
+	reg.Register(namedPrim{"Add", 2}, namedPrim{"X", 0}, namedPrim{"Y", 0})
+	dir := t.TempDir()
+	files := map[string]string{
+		"a-tree-0.json": `{"functional": "Add", "children": [{"terminal": "X"}, {"terminal": "Y"}]}`,
+		"a-pareto-0.json": `[{"objectives": [1, 2], "tree": {"terminal": "X"}},
+			{"objectives": [2, 1], "tree": {"terminal": "Y"}}]`,
+		"b.txt":      "(Add Y (Add X X))",
+		"a-tree.png": "not a tree",
+	}
+	for name, src := range files {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644)
+	}
+	seeds, err := LoadTrees(dir, reg.Lookup)
+	if err != nil {
+		t.Fatal("Cannot load trees:", err)
+	}
+	want := []string{"T{X}", "T{Y}", "F{Add}(T{X}, T{Y})", "F{Add}(T{Y}, F{Add}(T{X}, T{X}))"}
+	if len(seeds) != len(want) {
+		t.Fatal("Expected", len(want), "trees, got", seeds)
+	}
+	for i := range want {
+		if seeds[i].String() != want[i] {
+			t.Error("Expected tree", want[i], "got", seeds[i])
+		}
+	}
+
+	var set Settings
+	set.ImgTarget = imgut.Create(4, 4, imgut.MODE_RGB)
+	random := "F{Add}(T{Y}, T{Y})"
+	set.GenFunc = func(int) *node.Node {
+		return node.New(namedPrim{"Add", 2}, node.New(namedPrim{"Y", 0}), node.New(namedPrim{"Y", 0}))
+	}
+	mutated := 0
+	set.Mutate = func(p float64, ind *Individual) bool {
+		mutated++
+		return true
+	}
+	pop := &Population{Set: &set}
+	pop.Initialize(10)
+	// Duplicates are seeded once
+	if n := pop.Seed(append(seeds, seeds[0].Copy(), seeds[2].Copy()), 0.6, 0.1); n != 6 {
+		t.Error("Expected 6 seeded individuals, got", n)
+	}
+	count := make(map[string]int)
+	for _, ind := range pop.Pop {
+		count[ind.Node.String()]++
+	}
+	// 4 random trees, the seeds and two copies of random seeds
+	extra := 0
+	for _, w := range want {
+		if count[w] < 1 {
+			t.Error("Seed", w, "is missing")
+		}
+		extra += count[w] - 1
+	}
+	if count[random] != 4 || extra != 2 {
+		t.Error("Wrong seeded population", count)
+	}
+	if mutated != 2 {
+		t.Error("Expected 2 mutated copies, got", mutated)
+	}
+
+	// When there is no room for all the seeds, any of them can be picked
+	picked := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		pop.Initialize(10)
+		pop.Seed(seeds, 0.1, 0)
+		for _, ind := range pop.Pop {
+			picked[ind.Node.String()] = true
+		}
+	}
+	if len(picked) != len(want)+1 {
+		t.Error("Expected every seed to be picked, got", picked)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "c.txt"), []byte("(Sub X Y)"), 0644)
+	if _, err := LoadTrees(dir, reg.Lookup); err == nil {
+		t.Error("Expected error for unknown primitive")
+	}
+}
@@ -5,7 +5,8 @@ import (
 	"github.com/akiross/gogp/image/draw2d/imgut"
 	"github.com/akiross/gogp/node"
 	"github.com/akiross/gogp/repr/expr/binary"
-	//	"math/rand"
+	"math"
+	"math/rand"
 )
 
 /***********************************
@@ -33,6 +34,90 @@ func MakeMaxDepth(limit int) func(*imgut.Image) int {
 	}
 }
 
+// Add the primitives used to evolve expressions: arithmetic with protected
+// division, conditional, trigonometric, the coordinates and some constants
+func AddDefaultPrimitives() {
+	Functionals = append(Functionals, binary.MakeTernary("ITE", func(a, b, c binary.NumericOut) binary.NumericOut {
+		if a >= 0 {
+			return b
+		} else {
+			return c
+		}
+	}))
+	// Shared with the geometric semantic operators
+	Functionals = append(Functionals, binary.Sum, binary.Sub, binary.Mul)
+	Functionals = append(Functionals, binary.MakeBinary("Div", func(a, b binary.NumericOut) binary.NumericOut {
+		if b == 0 {
+			return binary.NumericOut(1)
+		} else {
+			return a / b
+		}
+	}))
+	Functionals = append(Functionals, binary.MakeBinary("Min", func(a, b binary.NumericOut) binary.NumericOut {
+		if a < b {
+			return a
+		} else {
+			return b
+		}
+	}))
+	Functionals = append(Functionals, binary.MakeBinary("Max", func(a, b binary.NumericOut) binary.NumericOut {
+		if a > b {
+			return a
+		} else {
+			return b
+		}
+	}))
+	Functionals = append(Functionals, binary.MakeBinary("Pow", func(a, b binary.NumericOut) binary.NumericOut {
+		return binary.NumericOut(math.Pow(float64(a), float64(b)))
+	}))
+
+	Functionals = append(Functionals, binary.MakeUnary("Sqr", func(a binary.NumericOut) binary.NumericOut { return a * a }))
+	Functionals = append(Functionals, binary.MakeUnary("Sqrt", func(a binary.NumericOut) binary.NumericOut {
+		if a < 0 {
+			return binary.NumericOut(math.Sqrt(float64(-a)))
+		} else {
+			return binary.NumericOut(math.Sqrt(float64(a)))
+		}
+	}))
+	Functionals = append(Functionals, binary.MakeUnary("Abs", func(a binary.NumericOut) binary.NumericOut {
+		if a < 0 {
+			return -a
+		} else {
+			return a
+		}
+	}))
+	Functionals = append(Functionals, binary.MakeUnary("Neg", func(a binary.NumericOut) binary.NumericOut { return -a }))
+	Functionals = append(Functionals, binary.MakeUnary("Sign", func(a binary.NumericOut) binary.NumericOut {
+		if a < 0 {
+			return -1
+		} else {
+			return 1
+		}
+	}))
+	Functionals = append(Functionals, binary.MakeUnary("Sin", func(a binary.NumericOut) binary.NumericOut {
+		return binary.NumericOut(math.Sin(float64(a)))
+	}))
+	Functionals = append(Functionals, binary.MakeUnary("Cos", func(a binary.NumericOut) binary.NumericOut {
+		return binary.NumericOut(math.Cos(float64(a)))
+	}))
+	Functionals = append(Functionals, binary.MakeUnary("Tanh", func(a binary.NumericOut) binary.NumericOut {
+		return binary.NumericOut(math.Tanh(float64(a)))
+	}))
+
+	Terminals = append(Terminals, binary.MakeIdentityX())
+	Terminals = append(Terminals, binary.MakeIdentityY())
+	Terminals = append(Terminals, binary.MakeConstant(-1))
+	Terminals = append(Terminals, binary.MakeConstant(0))
+	Terminals = append(Terminals, binary.MakeConstant(1))
+	Terminals = append(Terminals, binary.MakeConstant(2))
+	Terminals = append(Terminals, binary.MakeConstant(10))
+
+	Terminals = append(Terminals, binary.MakeEphimeral("MakeRand", func() *binary.Primitive {
+		v := rand.Float64()
+		return binary.MakeConstant(binary.NumericOut(v))
+	}))
+}
+
 func init() {
 	// Add some random constants to terminals
 	//	for i := 0; i < 20; i++ {
@@ -8,6 +8,7 @@ import (
 	"github.com/akiross/gogp/repr/rr"
 	"math"
 	"math/rand"
+	"strings"
 )
 
 /***********************************
@@ -39,47 +40,145 @@ func MakeMaxDepth(limit int) func(*imgut.Image) int {
 	}
 }
 
+// Solid gray terminals, count shades from black to white
+func Palette(count int) []gp.Primitive {
+	var pal []gp.Primitive
+	for i := 0; i < count; i++ {
+		c := float64(i) / float64(count-1)
+		name := fmt.Sprintf("G%02X", int(c*255))
+		pal = append(pal, rr.MakeTerminal(name, rr.Filler(c, c, c, 1)))
+	}
+	return pal
+}
+
+// Black and white terminals, used when no other terminal is enabled
+func BlackWhite() []gp.Primitive {
+	return []gp.Primitive{
+		rr.MakeTerminal("Black", rr.Filler(0, 0, 0, 1)),
+		rr.MakeTerminal("White", rr.Filler(1, 1, 1, 1)),
+	}
+}
+
+// Ephemerals and shades pick quantized values, so that the names of the
+// generated terminals encode them exactly, and they can be parsed back by
+// ParseEphemeral.
+// Colors have 256 levels and positions 100 steps: the values used to be
+// continuous, so the search space differs from runs made before registries
+
+func makeFullColor(c int) *rr.Primitive {
+	fc := float64(c) / 255
+	return rr.MakeTerminal(fmt.Sprintf("T_%d", c), rr.Filler(fc, fc, fc))
+}
+
 func MakeFullColor() *rr.Primitive {
-	c := rand.Float64()
-	name := fmt.Sprintf("T_%d", int(c*255))
-	return rr.MakeTerminal(name, rr.Filler(c, c, c))
+	return makeFullColor(rand.Intn(256))
+}
+
+func makeShadeColor(c, k, sx, sy, ex, ey int) *rr.Primitive {
+	name := fmt.Sprintf("EPH_%x-%x_%d-%d_%d-%d", c, k, sx, sy, ex, ey)
+	return rr.MakeTerminal(name, rr.LinShade(float64(c)/255, float64(k)/255,
+		float64(sx)/100, float64(sy)/100, float64(ex)/100, float64(ey)/100))
 }
 
 func MakeShadeColor() *rr.Primitive {
-	// Pick two random colors
-	c, k := rand.Float64(), rand.Float64()
-	// Pick random positions
-	sx, sy, ex, ey := rand.Float64(), rand.Float64(), rand.Float64(), rand.Float64()
-	name := fmt.Sprintf("EPH_%x-%x_%d-%d_%d-%d", int(c*255), int(k*255), int(sx*100), int(sy*100), int(ex*100), int(ey*100))
-	return rr.MakeTerminal(name, rr.LinShade(c, k, sx, sy, ex, ey))
+	// Pick two random colors and random positions
+	return makeShadeColor(rand.Intn(256), rand.Intn(256), rand.Intn(100), rand.Intn(100), rand.Intn(100), rand.Intn(100))
 }
 
-func MakeDiagFill() *rr.Primitive {
-	// Pick two random colors
-	c, k := rand.Float64(), rand.Float64()
-	// Pick a random diagonal
-	d := rand.Intn(2) == 0
+func makeLineShade(c, k, sx, sy, ex, ey int) *rr.Primitive {
+	name := fmt.Sprintf("L_%d-%d_%d-%d_%d-%d", c, k, sx, sy, ex, ey)
+	return rr.MakeTerminal(name, rr.LinShade(float64(c)/255, float64(k)/255,
+		float64(sx)/100, float64(sy)/100, float64(ex)/100, float64(ey)/100))
+}
+
+// Shade terminals between count+1 gray levels from black to white: for every
+// pair of levels, reps shades with random positions. Names are unique
+func Shades(count, reps int) []gp.Primitive {
+	var shades []gp.Primitive
+	seen := make(map[string]bool)
+	for i := 0; i <= count; i++ {
+		for j := i + 1; j <= count; j++ {
+			for n := 0; n < reps; n++ {
+				var t *rr.Primitive
+				for t == nil || seen[t.Name()] {
+					t = makeLineShade(255*i/count, 255*j/count, rand.Intn(100), rand.Intn(100), rand.Intn(100), rand.Intn(100))
+				}
+				seen[t.Name()] = true
+				shades = append(shades, t)
+			}
+		}
+	}
+	return shades
+}
+
+func makeDiagFill(c, k int, d bool) *rr.Primitive {
 	var name string
 	if d {
-		name = fmt.Sprintf("Df_%x-%x", int(c*255), int(k*255))
+		name = fmt.Sprintf("Df_%x-%x", c, k)
 	} else {
-		name = fmt.Sprintf("dF_%x-%x", int(c*255), int(k*255))
+		name = fmt.Sprintf("dF_%x-%x", c, k)
 	}
-	return rr.MakeTerminal(name, rr.DiagShade(c, k, d))
+	return rr.MakeTerminal(name, rr.DiagShade(float64(c)/255, float64(k)/255, d))
 }
 
-func MakeDiagLine() *rr.Primitive {
-	// Pick random back/foreground colors
-	b, f := rand.Float64(), rand.Float64()
-	// Pick a random diagonal
-	d := rand.Intn(2) == 0
-	// Pick a random line size
-	s := rand.Intn(16)
+func MakeDiagFill() *rr.Primitive {
+	// Pick two random colors and a random diagonal
+	return makeDiagFill(rand.Intn(256), rand.Intn(256), rand.Intn(2) == 0)
+}
+
+func makeDiagLine(b, f int, d bool, s int) *rr.Primitive {
 	var name string
 	if d {
-		name = fmt.Sprintf("Dl_%x_%x-%x", int(s*15), int(b*255), int(f*255))
+		name = fmt.Sprintf("Dl_%x_%x-%x", s*15, b, f)
 	} else {
-		name = fmt.Sprintf("dL_%x_%x-%x", int(s*15), int(b*255), int(f*255))
+		name = fmt.Sprintf("dL_%x_%x-%x", s*15, b, f)
+	}
+	return rr.MakeTerminal(name, rr.DiagLine(float64(b)/255, float64(f)/255, d, s))
+}
+
+func MakeDiagLine() *rr.Primitive {
+	// Pick random back/foreground colors, a random diagonal and a random line size
+	return makeDiagLine(rand.Intn(256), rand.Intn(256), rand.Intn(2) == 0, rand.Intn(16))
+}
+
+// Rebuild a terminal generated by one of the ephemerals in this package, or
+// built by Shades
+func ParseEphemeral(name string) (gp.Primitive, error) {
+	var p *rr.Primitive
+	var c, k, x1, y1, x2, y2 int
+	switch {
+	case strings.HasPrefix(name, "T_"):
+		if _, err := fmt.Sscanf(name, "T_%d", &c); err == nil {
+			p = makeFullColor(c)
+		}
+	case strings.HasPrefix(name, "EPH_"):
+		if _, err := fmt.Sscanf(name, "EPH_%x-%x_%d-%d_%d-%d", &c, &k, &x1, &y1, &x2, &y2); err == nil {
+			p = makeShadeColor(c, k, x1, y1, x2, y2)
+		}
+	case strings.HasPrefix(name, "L_"):
+		if _, err := fmt.Sscanf(name, "L_%d-%d_%d-%d_%d-%d", &c, &k, &x1, &y1, &x2, &y2); err == nil {
+			p = makeLineShade(c, k, x1, y1, x2, y2)
+		}
+	case strings.HasPrefix(name, "Df_"), strings.HasPrefix(name, "dF_"):
+		if _, err := fmt.Sscanf(name[3:], "%x-%x", &c, &k); err == nil {
+			p = makeDiagFill(c, k, name[0] == 'D')
+		}
+	case strings.HasPrefix(name, "Dl_"), strings.HasPrefix(name, "dL_"):
+		if _, err := fmt.Sscanf(name[3:], "%x_%x-%x", &x1, &c, &k); err == nil {
+			p = makeDiagLine(c, k, name[0] == 'D', x1/15)
+		}
+	}
+	// Names must match exactly, or values were not encoded by us
+	if p == nil || p.Name() != name {
+		return nil, fmt.Errorf("%q is not a valid ephemeral terminal", name)
+	}
+	return p, nil
+}
+
+// Allow reg to rebuild the terminals generated by ephemerals and the shades,
+// that are random in every process
+func RegisterEphemerals(reg *gp.Registry) {
+	for _, prefix := range []string{"T_", "EPH_", "L_", "Df_", "dF_", "Dl_", "dL_"} {
+		reg.RegisterEphemeral(prefix, ParseEphemeral)
 	}
-	return rr.MakeTerminal(name, rr.DiagLine(b, f, d, s))
 }
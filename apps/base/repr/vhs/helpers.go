@@ -49,26 +49,56 @@ func MakeEphimeral() gp.Primitive {
 }
 */
 
+// Shades pick quantized positions, so that their names encode them exactly
+// and they can be rebuilt by ParseTerminal in other processes. Positions
+// have 100 steps: they used to be continuous, changing the search space
+func makeShade(c, k, sx, sy, ex, ey int) *vhs.NamedTerminal {
+	name := fmt.Sprintf("T_%d-%d_%d-%d_%d-%d", c, k, sx, sy, ex, ey)
+	return vhs.Named(name, vhs.LinShade(float64(c)/256, float64(k)/256,
+		float64(sx)/100, float64(sy)/100, float64(ex)/100, float64(ey)/100))
+}
+
+// Rebuild a shaded terminal built by this package, from its name
+func ParseTerminal(name string) (gp.Primitive, error) {
+	var c, k, sx, sy, ex, ey int
+	if _, err := fmt.Sscanf(name, "T_%d-%d_%d-%d_%d-%d", &c, &k, &sx, &sy, &ex, &ey); err == nil {
+		if t := makeShade(c, k, sx, sy, ex, ey); t.Name() == name {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("%q is not a valid terminal", name)
+}
+
+// Allow reg to rebuild the shaded terminals, that are random in every process
+func RegisterTerminals(reg *gp.Registry) {
+	reg.RegisterEphemeral("T_", ParseTerminal)
+}
+
 func init() {
 	// Build some colors
 	count := 8 // number of total colors, from black to white
 	for i := 0; i <= count; i++ {
 		c := float64(i) / float64(count)
-		Terminals = append(Terminals, vhs.Terminal(vhs.Filler(c, c, c, 1)))
-		TermNames = append(TermNames, fmt.Sprintf("T_%d", int(c*256)))
+		name := fmt.Sprintf("T_%d", int(c*256))
+		Terminals = append(Terminals, vhs.Named(name, vhs.Filler(c, c, c, 1)))
+		TermNames = append(TermNames, name)
 	}
 
+	// Names are used to save trees, so they must be unique
+	seen := make(map[string]bool)
 	count = 8
 	reps := 8
 	for i := 0; i <= count; i++ {
-		c := float64(i) / float64(count)
 		for j := i + 1; j <= count; j++ {
-			k := float64(j) / float64(count)
 			// Multiple copie
 			for n := 0; n < reps; n++ {
-				sx, sy, ex, ey := rand.Float64(), rand.Float64(), rand.Float64(), rand.Float64()
-				Terminals = append(Terminals, vhs.Terminal(vhs.LinShade(c, k, sx, sy, ex, ey)))
-				TermNames = append(TermNames, fmt.Sprintf("T_%d-%d_%d-%d_%d-%d", int(c*256), int(k*256), int(sx*100), int(sy*100), int(ex*100), int(ey*100)))
+				var t *vhs.NamedTerminal
+				for t == nil || seen[t.Name()] {
+					t = makeShade(256*i/count, 256*j/count, rand.Intn(100), rand.Intn(100), rand.Intn(100), rand.Intn(100))
+				}
+				seen[t.Name()] = true
+				Terminals = append(Terminals, t)
+				TermNames = append(TermNames, t.Name())
 			}
 		}
 	}
@@ -0,0 +1,112 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/akiross/gogp/image/draw2d/imgut"
+	"github.com/akiross/gogp/node"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// An element of the Pareto fronts written by stats
+type frontEntry struct {
+	Tree json.RawMessage `json:"tree"`
+}
+
+// Read the trees in a file: a tree written by MarshalJSON, a Pareto front
+// (list of {"tree": ...}) as saved by stats, or a tree in text form (.txt)
+func loadTreeFile(path string, lookup node.Lookup) ([]*node.Node, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) == ".txt" {
+		t, err := node.Parse(string(data), lookup)
+		if err != nil {
+			return nil, err
+		}
+		return []*node.Node{t}, nil
+	}
+	var front []frontEntry
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		if err := json.Unmarshal(data, &front); err != nil {
+			return nil, err
+		}
+	} else {
+		front = append(front, frontEntry{data})
+	}
+	var trees []*node.Node
+	for _, f := range front {
+		t, err := node.Unmarshal(f.Tree, lookup)
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, t)
+	}
+	return trees, nil
+}
+
+// Load the trees saved in the .json and .txt files of dir (e.g. the log
+// directory of another run), sorted by file name
+func LoadTrees(dir string, lookup node.Lookup) ([]*node.Node, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, f := range files {
+		if ext := filepath.Ext(f.Name()); !f.IsDir() && (ext == ".json" || ext == ".txt") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+	var trees []*node.Node
+	for _, name := range names {
+		t, err := loadTreeFile(filepath.Join(dir, name), lookup)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", name, err)
+		}
+		trees = append(trees, t...)
+	}
+	if len(trees) == 0 {
+		return nil, fmt.Errorf("no trees found in %v", dir)
+	}
+	return trees, nil
+}
+
+// Replace a fraction of the initialized population with copies of seeds,
+// placed at random positions. Duplicate seeds are dropped, and the remaining
+// ones are picked in random order: every seed is copied at least once,
+// unmodified, if there is room. Further copies are mutated with probability
+// pMut using Set.Mutate, so that the seeds are not simply cloned. Seeds are
+// not limited by Set.MaxDepth. Returns the number of seeded individuals
+func (pop *Population) Seed(seeds []*node.Node, fraction, pMut float64) int {
+	var unique []*node.Node
+	seen := make(map[uint64]bool)
+	for _, t := range seeds {
+		if h := t.Hash(); !seen[h] {
+			seen[h] = true
+			unique = append(unique, t)
+		}
+	}
+	if len(unique) == 0 {
+		return 0
+	}
+	order := rand.Perm(len(unique))
+	n := int(math.Min(1, math.Max(0, fraction))*float64(len(pop.Pop)) + 0.5)
+	for k, i := range rand.Perm(len(pop.Pop))[:n] {
+		ind := &Individual{Node: unique[order[k%len(unique)]].Copy(), set: pop.Set}
+		ind.ImgTemp = imgut.Create(pop.Set.ImgTarget.W, pop.Set.ImgTarget.H, pop.Set.ImgTarget.ColorSpace)
+		if k >= len(unique) && pMut > 0 {
+			ind.Mutate(pMut)
+		}
+		pop.Pop[i] = ind
+	}
+	pop.best = nil
+	return n
+}
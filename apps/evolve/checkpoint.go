@@ -0,0 +1,66 @@
+package evolve
+
+import (
+	"encoding/gob"
+	"flag"
+	"github.com/akiross/gogp/apps/base"
+	"os"
+)
+
+// Flags that are not restored when resuming
+var volatileFlags = map[string]bool{"resume": true, "cpuprofile": true}
+
+// Everything needed to resume an evolution
+type checkpoint struct {
+	Flags       map[string]string // Values of command line flags
+	Generation  int               // Generation at which the checkpoint was taken
+	Evaluations int               // Fitness evaluations performed so far
+	Seed        int64             // RNG is seeded with this value after the checkpoint
+	Stats       []byte            // Encoded stats.Stats
+	Pop         *base.PopulationState
+}
+
+func checkpointPath(basedir, basename string) string {
+	return basedir + "/log/" + basename + "-checkpoint.gob"
+}
+
+// Save the values of all the flags
+func flagValues(fs *flag.FlagSet) map[string]string {
+	vals := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		if !volatileFlags[f.Name] {
+			vals[f.Name] = f.Value.String()
+		}
+	})
+	return vals
+}
+
+// Write the checkpoint, replacing the previous one only when fully written
+func saveCheckpoint(path string, cp *checkpoint) error {
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(cp); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func loadCheckpoint(path string) (*checkpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cp checkpoint
+	if err := gob.NewDecoder(f).Decode(&cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
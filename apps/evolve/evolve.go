@@ -6,22 +6,37 @@ import (
 	"github.com/akiross/gogp/apps/base"
 	"github.com/akiross/gogp/apps/stats"
 	"github.com/akiross/gogp/ga"
+	"github.com/akiross/gogp/ge"
 	"github.com/akiross/gogp/gp"
 	"github.com/akiross/gogp/image/draw2d/imgut"
 	"github.com/akiross/gogp/node"
 	"github.com/akiross/gogp/util/stats/counter"
 	"github.com/akiross/gogp/util/stats/sequence"
+	"io/ioutil"
 	"math"
 	"math/rand"
 	"os"
 	"runtime"
 	"runtime/pprof" // profiling...
+	"strconv"
+	"strings"
 	"time"
 )
 
 const (
 	tree_init_depth = "tree-init-depth"
 
+	ge_invalid = "ge-invalid"
+	ge_wraps   = "ge-wraps"
+
+	simp_size_reduction = "simp-size-reduction"
+
+	gs_cross_event  = "gs-cross-event"
+	gs_cross_improv = "gs-cross-improv"
+	gs_mut_event    = "gs-mut-event"
+	gs_mut_improv   = "gs-mut-improv"
+	gs_cache_size   = "gs-cache-size"
+
 	mut_single_event       = "mut-single-event"
 	mut_single_improv      = "mut-single-improv"
 	mut_single_node_depth  = "mut-single-node-depth"
@@ -58,6 +73,9 @@ const (
 	mut_count_multi = "mut-count-multiple"
 )
 
+// Build a mutation picking among the enabled ones. The fitness before and after
+// each mutation, used for statistics, is computed with Fitness so that it is
+// counted and cached like the other evaluations
 func makeMultiMutation(s *base.Settings, multiMut, enbSin, enbNod, enbSub, enbAre, enbLsubt, enbLoc bool) func(float64, *base.Individual) bool {
 	// La mutazione a che profondità avviene?
 	// Quanto è profondo l'albero che vado a generare?
@@ -65,17 +83,11 @@ func makeMultiMutation(s *base.Settings, multiMut, enbSin, enbNod, enbSub, enbAr
 	// Separare foglie e nodi
 
 	countInt := func(name string, v int) {
-		if _, ok := s.IntCounters[name]; !ok {
-			s.IntCounters[name] = new(counter.IntCounter)
-		}
-		s.IntCounters[name].Count(v)
+		s.IntCounter(name).Count(v)
 	}
 
 	countBool := func(name string, v bool) {
-		if _, ok := s.Counters[name]; !ok {
-			s.Counters[name] = new(counter.BoolCounter)
-		}
-		s.Counters[name].Count(v)
+		s.Counter(name).Count(v)
 	}
 
 	statFuncSin := func(nDepth, replDepth int, isLeaf bool) {
@@ -123,9 +135,10 @@ func makeMultiMutation(s *base.Settings, multiMut, enbSin, enbNod, enbSub, enbAr
 					ind.CountEvent(mut_single_event, event)
 					if event {
 						evCount++
-						fit := ind.Evaluate()
+						fit := ind.Fitness()
 						singleMut(ind.Node)
-						newFit := ind.Evaluate()
+						ind.Invalidate()
+						newFit := ind.Fitness()
 						ind.CountEvent(mut_single_improv, s.BetterThan(newFit, fit))
 					}
 					if !multiMut {
@@ -134,12 +147,13 @@ func makeMultiMutation(s *base.Settings, multiMut, enbSin, enbNod, enbSub, enbAr
 				}
 			case 1:
 				if enbNod {
-					fit := ind.Evaluate()
+					fit := ind.Fitness()
 					event = nodeMut(pMut, ind.Node) != 0
 					ind.CountEvent(mut_multi_event, event)
 					if event {
 						evCount++
-						newFit := ind.Evaluate()
+						ind.Invalidate()
+						newFit := ind.Fitness()
 						ind.CountEvent(mut_multi_improv, s.BetterThan(newFit, fit))
 					}
 					if !multiMut {
@@ -151,9 +165,10 @@ func makeMultiMutation(s *base.Settings, multiMut, enbSin, enbNod, enbSub, enbAr
 					ind.CountEvent(mut_tree_event, event)
 					if event {
 						evCount++
-						fit := ind.Evaluate()
+						fit := ind.Fitness()
 						subtrMut(ind.Node)
-						newFit := ind.Evaluate()
+						ind.Invalidate()
+						newFit := ind.Fitness()
 						ind.CountEvent(mut_tree_improv, s.BetterThan(newFit, fit))
 					}
 					if !multiMut {
@@ -165,9 +180,10 @@ func makeMultiMutation(s *base.Settings, multiMut, enbSin, enbNod, enbSub, enbAr
 					ind.CountEvent(mut_area_event, event)
 					if event {
 						evCount++
-						fit := ind.Evaluate()
+						fit := ind.Fitness()
 						areaMut(ind.Node)
-						newFit := ind.Evaluate()
+						ind.Invalidate()
+						newFit := ind.Fitness()
 						ind.CountEvent(mut_area_improv, s.BetterThan(newFit, fit))
 					}
 					if !multiMut {
@@ -179,9 +195,10 @@ func makeMultiMutation(s *base.Settings, multiMut, enbSin, enbNod, enbSub, enbAr
 					ind.CountEvent(mut_lsubt_event, event)
 					if event {
 						evCount++
-						fit := ind.Evaluate()
+						fit := ind.Fitness()
 						subtLevelMut(ind.Node)
-						newFit := ind.Evaluate()
+						ind.Invalidate()
+						newFit := ind.Fitness()
 						ind.CountEvent(mut_lsubt_improv, s.BetterThan(newFit, fit))
 					}
 					if !multiMut {
@@ -194,7 +211,7 @@ func makeMultiMutation(s *base.Settings, multiMut, enbSin, enbNod, enbSub, enbAr
 					ind.CountEvent(mut_local_event, event)
 					if event {
 						evCount++
-						fit := ind.Evaluate()
+						fit := ind.Fitness()
 						for i := 0; i < neighbSize; i++ {
 							mutated := ind.Copy().(*base.Individual) // Copy individual
 							singleMut(mutated.Node)                  // Apply mutation
@@ -206,7 +223,7 @@ func makeMultiMutation(s *base.Settings, multiMut, enbSin, enbNod, enbSub, enbAr
 							}
 						}
 						// Perform singleMut K times
-						newFit := ind.Evaluate()
+						newFit := ind.Fitness()
 						ind.CountEvent(mut_local_improv, s.BetterThan(newFit, fit))
 					}
 					if !multiMut {
@@ -225,6 +242,25 @@ func makeMultiMutation(s *base.Settings, multiMut, enbSin, enbNod, enbSub, enbAr
 	}
 }
 
+// Simplify, if not nil, builds a simplified copy of a tree. Best individuals
+// are saved also simplified, and the -simp flag uses it during evolution
+var Simplify func(*node.Node) *node.Node
+
+// Wrap mutate, simplifying the mutated individuals with probability pSimp
+func makeSimplifyingMutation(s *base.Settings, mutate func(float64, *base.Individual) bool, pSimp float64) func(float64, *base.Individual) bool {
+	return func(pMut float64, ind *base.Individual) bool {
+		changed := mutate(pMut, ind)
+		if rand.Float64() < pSimp {
+			size := node.Size(ind.Node)
+			ind.Node = s.Simplify(ind.Node)
+			reduction := size - node.Size(ind.Node)
+			s.IntCounter(simp_size_reduction).Count(reduction)
+			changed = changed || reduction > 0
+		}
+		return changed
+	}
+}
+
 func makeCrossover(s *base.Settings) func(float64, *base.Individual, *base.Individual) bool {
 	xo := node.MakeTree1pCrossover(s.MaxDepth)
 	return func(pCross float64, mate1, mate2 *base.Individual) bool {
@@ -237,6 +273,172 @@ func makeCrossover(s *base.Settings) func(float64, *base.Individual, *base.Indiv
 	}
 }
 
+// Geometric semantic operators, building offspring that reference their parents
+type SemanticOperators interface {
+	// Build two offspring of t1 and t2, without modifying them
+	Crossover(t1, t2 *node.Node) (*node.Node, *node.Node)
+	// Build a mutated copy of t
+	Mutation(t *node.Node) *node.Node
+	// Release the data not needed by the live trees, returning the number of
+	// parents still cached
+	Prune(live []*node.Node) int
+	// Build the full tree of t, replacing the references to the parents.
+	// Fails if it has more than maxSize nodes
+	Expand(t *node.Node, maxSize int) (*node.Node, error)
+}
+
+// Max number of nodes of the trees saved by runs using semantic operators.
+// Offspring grow exponentially once the parents are expanded in them
+const semMaxSavedSize = 100000
+
+// Semantic, if not nil, builds geometric semantic operators for trees drawn on
+// w x h images, using gen to build random trees and step as mutation step.
+// Used by the -gsx and -gsm flags
+var Semantic func(w, h int, gen func(int) *node.Node, step float64) SemanticOperators
+
+func makeSemanticCrossover(s *base.Settings, gs SemanticOperators) func(float64, *base.Individual, *base.Individual) bool {
+	return func(pCross float64, mate1, mate2 *base.Individual) bool {
+		event := rand.Float64() < pCross
+		s.Counter(gs_cross_event).Count(event)
+		if event {
+			fit1, fit2 := mate1.Fitness(), mate2.Fitness()
+			mate1.Node, mate2.Node = gs.Crossover(mate1.Node, mate2.Node)
+			mate1.Invalidate()
+			mate2.Invalidate()
+			s.Counter(gs_cross_improv).Count(s.BetterThan(mate1.Fitness(), fit1))
+			s.Counter(gs_cross_improv).Count(s.BetterThan(mate2.Fitness(), fit2))
+		}
+		return event
+	}
+}
+
+func makeSemanticMutation(s *base.Settings, gs SemanticOperators) func(float64, *base.Individual) bool {
+	return func(pMut float64, ind *base.Individual) bool {
+		event := rand.Float64() < pMut
+		ind.CountEvent(gs_mut_event, event)
+		if event {
+			fit := ind.Fitness()
+			ind.Node = gs.Mutation(ind.Node)
+			ind.Invalidate()
+			ind.CountEvent(gs_mut_improv, s.BetterThan(ind.Fitness(), fit))
+		}
+		return event
+	}
+}
+
+// Split a fitness specification like "lab:2,1,1" in name and channel weights
+func parseFitness(spec string) (string, []float64, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	if len(parts) == 1 {
+		return spec, nil, nil
+	}
+	var weights []float64
+	var sum float64
+	for _, w := range strings.Split(parts[1], ",") {
+		v, err := strconv.ParseFloat(w, 64)
+		if err != nil {
+			return "", nil, err
+		}
+		if !(v >= 0) || math.IsInf(v, 1) {
+			return "", nil, fmt.Errorf("invalid channel weight %v", w)
+		}
+		weights = append(weights, v)
+		sum += v
+	}
+	if len(weights) != 3 {
+		return "", nil, fmt.Errorf("expected 3 channel weights, got %v", len(weights))
+	}
+	if sum == 0 {
+		return "", nil, fmt.Errorf("channel weights cannot be all zero")
+	}
+	return parts[0], weights, nil
+}
+
+// Build a tree generator deriving trees from random genomes of genomeLen codons
+// in [0, maxCodon), using the grammar in path and allowing maxWraps wraps.
+// Primitives must be already registered in gp.DefaultRegistry
+func makeGrammarGenFunc(s *base.Settings, path string, maxWraps, genomeLen, maxCodon int) (func(int) *node.Node, error) {
+	if maxWraps < 0 || genomeLen < 1 || maxCodon < 1 {
+		return nil, fmt.Errorf("invalid wraps (%v), genome length (%v) or codon range (%v)", maxWraps, genomeLen, maxCodon)
+	}
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	g, err := ge.ParseGrammar(string(src), gp.DefaultRegistry.Lookup)
+	if err != nil {
+		return nil, err
+	}
+	gen, err := ge.NewMapper(g, maxWraps).MakeGenFunc(genomeLen, maxCodon, func(info ge.MapInfo, err error) {
+		s.Counter(ge_invalid).Count(err != nil)
+		s.IntCounter(ge_wraps).Count(info.Wraps)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return func(maxDep int) *node.Node {
+		t := gen(maxDep)
+		s.IntCounter(tree_init_depth).Count(node.Depth(t))
+		return t
+	}, nil
+}
+
+// A population evolved by its own engine, with its settings and statistics
+type island struct {
+	pop    *base.Population
+	engine *ga.Engine
+	sta    *stats.Stats
+
+	// Names of extra statistics
+	countersKeys, statsKeys, intCountersKeys []string
+
+	// Surface for the entire population
+	imgTempPop         *imgut.Image
+	pImgCols, pImgRows int
+}
+
+// Save statistics and the image of the population
+func (isl *island) snapshot(quiet bool) {
+	_, snapPopName := isl.sta.SaveSnapshot(isl.pop, quiet, isl.countersKeys, isl.statsKeys, isl.intCountersKeys)
+	// Save pop images
+	isl.pop.Draw(isl.imgTempPop, isl.pImgCols, isl.pImgRows)
+	isl.imgTempPop.WritePNG(snapPopName)
+}
+
+// Compute various statistics and save a snapshot every saveInterval generations
+func (isl *island) observe(gen, saveInterval int, quiet bool) {
+	isl.sta.Observe(isl.pop)
+	if gen%saveInterval == 0 {
+		isl.snapshot(quiet)
+	}
+}
+
+// Generation of the engine. In steady-state mode, a generation is counted
+// every time the offspring evaluated after the initial population are as
+// many as the population
+func generationOf(e *ga.Engine) int {
+	if e.SteadyState == 0 {
+		return e.Generation
+	}
+	n := e.Pop.Size()
+	if e.Evaluations < n {
+		return 0
+	}
+	return (e.Evaluations - n) / n
+}
+
+// Build a function returning the generation of the engine, and true if it
+// changed since the last call. Every hook needs its own
+func makeNewGeneration() func(e *ga.Engine) (int, bool) {
+	last := -1
+	return func(e *ga.Engine) (int, bool) {
+		gen := generationOf(e)
+		isNew := gen != last
+		last = gen
+		return gen, isNew
+	}
+}
+
 func Evolve(calcMaxDepth func(*imgut.Image) int, fun, ter []gp.Primitive, drawfun func(*base.Individual, *imgut.Image)) {
 	startTime := time.Now()
 
@@ -250,10 +452,23 @@ func Evolve(calcMaxDepth func(*imgut.Image) int, fun, ter []gp.Primitive, drawfu
 	pMut := fs.Float64("M", 0.1, "Bit mutation probability")
 	quiet := fs.Bool("q", false, "Quiet mode")
 	fElite := fs.Bool("el", false, "Enable elite individual")
+	eliteSize := fs.Int("elite", 1, "Number of elite individuals, when elitism is enabled")
+	workers := fs.Int("workers", runtime.NumCPU(), "Number of goroutines evaluating the population")
+	cacheSize := fs.Int("cache", 10000, "Number of fitness values cached by tree, 0 to disable")
+	pipelines := fs.Int("pipes", 1, "Number of parallel crossover/mutation pipelines. Runs using more than one are not reproducible, and cannot be resumed in the same way")
+	steadyState := fs.Int("ss", 0, "Steady-state mode, breeding this number of offspring at every step (0 for generational). Generations (e.g. for -g and -n) are counted every population size evaluated offspring, and runs stop anyway after g * p steps")
+	steadyReplace := fs.String("ssr", "worst", "Individual replaced by an offspring in steady-state mode (worst, tourn)")
 
 	fInitFull := fs.Bool("full", true, "Enable full initialization")
 	fInitGrow := fs.Bool("grow", true, "Enable grow initialization")
 	fInitRamped := fs.Bool("ramp", true, "Enable ramped initialization")
+	grammarPath := fs.String("grammar", "", "BNF grammar used to generate the initial trees, by grammatical evolution mapping of random genomes. Cannot be used with subtree mutations")
+	geWraps := fs.Int("ge-wraps", 2, "Times a genome can be wrapped when mapped with -grammar")
+	geLen := fs.Int("ge-len", 100, "Number of codons of the random genomes mapped with -grammar")
+	geCodon := fs.Int("ge-codon", 256, "Codons of the random genomes mapped with -grammar are in [0, ge-codon)")
+	seedsDir := fs.String("seeds", "", "Directory of saved trees (.json, or .txt in text form) used to seed the initial population, e.g. the log directory of another run. Duplicate trees are dropped and the others are picked at random")
+	seedFrac := fs.Float64("seed-frac", 0.1, "Fraction of the initial population built from the seeds, the rest is generated as usual")
+	seedMut := fs.Float64("seed-mut", 0.1, "Mutation probability of repeated copies of the seeds, using the enabled mutations (0 to clone them)")
 
 	fMutSin := fs.Bool("ms", false, "Enable Single Mutation")
 	fMutNod := fs.Bool("mn", false, "Enable Node Mutation")
@@ -262,16 +477,26 @@ func Evolve(calcMaxDepth func(*imgut.Image) int, fun, ter []gp.Primitive, drawfu
 	fMutLsubt := fs.Bool("mlt", false, "Enable Level-Subtree Mutation")
 	fMutLoc := fs.Bool("ml", false, "Enable Local Mutation")
 
-	fSelect := fs.String("sel", "torun", "Pick selection method (tourn, rmad, irmad)")
+	fSelect := fs.String("sel", "torun", "Pick selection method (tourn, rmad, irmad, nsga2, lexicase, elexicase)")
+	caseBlock := fs.Int("cases", 8, "Size of the image tiles used as test cases by lexicase selection, 1 to use pixels")
 
 	fMultiMut := fs.Bool("mM", false, "Enable multiple mutations")
-	fFitness := fs.String("fit", "rmse", "Pick fitness function (rmse, mse, rmsed, ssim)")
+	islandSpec := fs.String("islands", "", "Evolve an island for each semicolon separated list of flags, overriding the other ones, e.g. \"-mt;-ms -M 0.2\"")
+	migrTopology := fs.String("migr", "ring", "Migration topology between islands (ring, full, random)")
+	migrInterval := fs.Int("migr-int", 10, "Generations between two migrations, 0 to disable")
+	migrSize := fs.Int("migr-size", 1, "Number of individuals sent to each neighbour island")
+	migrSelect := fs.String("migr-sel", "best", "Pick the migrating individuals (best, random)")
+	fSemCross := fs.Bool("gsx", false, "Use geometric semantic crossover, if the representation supports it")
+	semStep := fs.Float64("gsm", 0, "Use geometric semantic mutation with this step, if the representation supports it (0 to disable)")
+	pSimp := fs.Float64("simp", 0, "Probability of simplifying an individual after mutation, if the representation has a simplifier")
+	fFitness := fs.String("fit", "rmse", "Pick fitness function (rmse, mse, rmsed, ssim, msssim, rgb, ycbcr, lab). Colour fitnesses accept channel weights, e.g. lab:2,1,1")
 
 	//advStats := fs.Bool("stats", false, "Enable advanced statistics")
 	//nps := fs.Bool("nps", false, "Disable population snapshot (no-pop-snap)")
 	targetPath := fs.String("t", "", "Target image (PNG) path")
 	var basedir, basename string
 	cpuProfile := fs.String("cpuprofile", "", "Write CPU profile to file")
+	resume := fs.Bool("resume", false, "Resume evolution from the last checkpoint in basedir")
 
 	fs.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
@@ -294,82 +519,54 @@ func Evolve(calcMaxDepth func(*imgut.Image) int, fun, ter []gp.Primitive, drawfu
 		basename = args[1]
 	}
 
-	sta := stats.Create(basedir, basename)
-
-	// Build settings
-	var settings base.Settings
-	// Primitives to use
-	settings.Functionals = fun
-	settings.Terminals = ter
-	// Draw function to use
-	settings.Draw = drawfun
-
-	settings.Ramped = *fInitRamped
-	if settings.Ramped {
-		fmt.Println("Using ramped initialization")
-	}
-
-	// Pick initialization method based on flags
-	var genFuncBit func(minH, maxH int, funcs, terms []gp.Primitive) *node.Node
-
-	if *fInitFull && !*fInitGrow {
-		fmt.Println("Using init strategy: full")
-		genFuncBit = node.MakeTreeFull // Initialize tree using full
-	} else if !*fInitFull && *fInitGrow {
-		fmt.Println("Using init strategy: balanced grow")
-		genFuncBit = node.MakeTreeGrowBalanced // Initialize using grow
-	} else {
-		fmt.Println("Using init strategy: half-and-half")
-		genFuncBit = node.MakeTreeHalfAndHalf // Initialize using both (half and half)
-	}
-	settings.GenFunc = func(maxDep int) *node.Node {
-		t := genFuncBit(0, maxDep, fun, ter) // /* TODO */ sistemare la minH
-		s := settings
-		if _, ok := s.IntCounters[tree_init_depth]; !ok {
-			s.IntCounters[tree_init_depth] = new(counter.IntCounter)
+	// When resuming, use the same settings of the checkpointed run
+	var cp *checkpoint
+	if *resume {
+		var err error
+		cp, err = loadCheckpoint(checkpointPath(basedir, basename))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: Cannot load checkpoint:", err)
+			return
 		}
-		s.IntCounters[tree_init_depth].Count(node.Depth(t))
-		return t
+		for name, val := range cp.Flags {
+			fs.Set(name, val)
+		}
+		fmt.Println("Resuming from generation", cp.Generation)
 	}
 
-	// Build statistic map
-	settings.Statistics = make(map[string]*sequence.SequenceStats)
-	settings.Counters = make(map[string]*counter.BoolCounter)
-	settings.IntCounters = make(map[string]*counter.IntCounter)
-
-	// Names of extra statistics
-	statsKeys := []string{}
-	countersKeys := []string{}
-	intCountersKeys := []string{}
-
-	intCountersKeys = append(intCountersKeys, tree_init_depth)
-
-	if *fMutSin {
-		countersKeys = append(countersKeys, mut_single_event, mut_single_improv, mut_single_node_leaves)
-		intCountersKeys = append(intCountersKeys, mut_single_node_depth, mut_single_node_repld)
-	}
-	if *fMutNod {
-		countersKeys = append(countersKeys, mut_multi_event, mut_multi_improv, mut_multi_node_leaves)
-		intCountersKeys = append(intCountersKeys, mut_multi_node_depth, mut_multi_node_repld)
-	}
-	if *fMutSub {
-		countersKeys = append(countersKeys, mut_tree_event, mut_tree_improv, mut_tree_node_leaves)
-		intCountersKeys = append(intCountersKeys, mut_tree_node_depth, mut_tree_node_repld)
-	}
-	if *fMutAre {
-		countersKeys = append(countersKeys, mut_area_event, mut_area_improv, mut_area_node_leaves)
-		intCountersKeys = append(intCountersKeys, mut_area_node_depth, mut_area_node_repld)
-	}
-	if *fMutLsubt {
-		countersKeys = append(countersKeys, mut_lsubt_event, mut_lsubt_improv, mut_lsubt_improv)
-		intCountersKeys = append(intCountersKeys, mut_lsubt_node_depth, mut_lsubt_node_repld)
-	}
-	if *fMutLoc {
-		countersKeys = append(countersKeys, mut_local_event, mut_local_improv)
-		//		intCountersKeys = append(intCountersKeys, "mut_local_") TODO
-	}
-	if *fMultiMut {
-		intCountersKeys = append(intCountersKeys, mut_count_multi)
+	// Islands, each one evolved with its own flags
+	islandArgs := [][]string{nil}
+	var topology ga.Topology
+	var emigrate ga.MigrantSelection
+	if *islandSpec != "" {
+		if cp != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: Island runs cannot be resumed")
+			return
+		}
+		islandArgs = nil
+		for _, spec := range strings.Split(*islandSpec, ";") {
+			islandArgs = append(islandArgs, strings.Fields(spec))
+		}
+		switch *migrTopology {
+		case "ring":
+			topology = ga.RingTopology
+		case "full":
+			topology = ga.FullTopology
+		case "random":
+			topology = ga.MakeRandomTopology(1)
+		default:
+			fmt.Fprintln(os.Stderr, "ERROR: Unknown migration topology", *migrTopology)
+			return
+		}
+		switch *migrSelect {
+		case "best":
+			emigrate = ga.BestMigrants
+		case "random":
+			emigrate = ga.RandomMigrants
+		default:
+			fmt.Fprintln(os.Stderr, "ERROR: Unknown migrant selection", *migrSelect)
+			return
+		}
 	}
 
 	if *cpuProfile != "" {
@@ -383,54 +580,18 @@ func Evolve(calcMaxDepth func(*imgut.Image) int, fun, ter []gp.Primitive, drawfu
 	}
 
 	// Load the target
-	var err error
-	settings.ImgTarget, err = imgut.Load(*targetPath)
+	imgTarget, err := imgut.Load(*targetPath)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "ERROR: Cannot load image", *targetPath)
 		panic("Cannot load image")
 	}
 	if !*quiet {
-		fmt.Println("Image format RGB?", settings.ImgTarget.ColorSpace == imgut.MODE_RGB, settings.ImgTarget.ColorSpace)
+		fmt.Println("Image format RGB?", imgTarget.ColorSpace == imgut.MODE_RGB, imgTarget.ColorSpace)
 	}
 
 	// Compute the right value of maxDepth
-	settings.MaxDepth = calcMaxDepth(settings.ImgTarget)
 	if !*quiet {
-		fmt.Println("For area of", settings.ImgTarget.W*settings.ImgTarget.H, "pixels, max depth is", settings.MaxDepth)
-	}
-
-	// Create temporary surface, of same size and mode
-	//settings.ImgTemp = imgut.Create(settings.ImgTarget.W, settings.ImgTarget.H, settings.ImgTarget.ColorSpace)
-	// Create temporary surface for the entire population
-	pImgCols := int(math.Ceil(math.Sqrt(float64(*popSize))))
-	pImgRows := int(math.Ceil(float64(*popSize) / float64(pImgCols)))
-	imgTempPop := imgut.Create(pImgCols*settings.ImgTarget.W, pImgRows*settings.ImgTarget.H, settings.ImgTarget.ColorSpace)
-
-	// Define the operators
-	settings.CrossOver = makeCrossover(&settings)
-	settings.Mutate = makeMultiMutation(&settings, *fMultiMut, *fMutSin, *fMutNod, *fMutSub, *fMutAre, *fMutLsubt, *fMutLoc)
-
-	// Fitness
-	if *fFitness == "mse" {
-		settings.FitFunc = base.MakeFitMSE(settings.ImgTarget)
-	} else if *fFitness == "rmsed" {
-		statsKeys = append(statsKeys, "fit-delta-rmse")
-		settings.FitFunc = base.MakeFitEdge(settings.ImgTarget, settings.Statistics)
-	} else if *fFitness == "ssim" {
-		settings.FitFunc = base.MakeFitSSIM(settings.ImgTarget)
-	} else {
-		statsKeys = append(statsKeys, "fit-delta-rmse")
-		settings.FitFunc = base.MakeFitRMSE(settings.ImgTarget)
-	}
-
-	// Selection
-	ts := *tournSize
-	if *fSelect == "rmad" {
-		settings.Select = base.MakeSelectRMAD(ts, ts*ts, settings.BetterThan)
-	} else if *fSelect == "irmad" {
-		settings.Select = base.MakeSelectIRMAD(ts, ts*ts, settings.BetterThan)
-	} else {
-		settings.Select = base.MakeSelectTourn(ts, settings.BetterThan)
+		fmt.Println("For area of", imgTarget.W*imgTarget.H, "pixels, max depth is", calcMaxDepth(imgTarget))
 	}
 
 	// Seed rng
@@ -440,116 +601,421 @@ func Evolve(calcMaxDepth func(*imgut.Image) int, fun, ter []gp.Primitive, drawfu
 		fmt.Println("CPUs limits", runtime.GOMAXPROCS(0))
 	}
 
-	// Build population
-	pop := new(base.Population)
-	pop.Set = &settings
-	//pop.TournSize = *tournSize
-	pop.Initialize(*popSize)
+	// Primitives are registered once, so that saved trees can be loaded
+	for _, prims := range [][]gp.Primitive{fun, ter} {
+		if err := gp.DefaultRegistry.Register(prims...); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: Cannot register primitives:", err)
+			return
+		}
+	}
+
+	// Build a population, evolved by its own engine, using the current values
+	// of the flags. Returns nil if the flags are not valid
+	newIsland := func(name string) *island {
+		sta := stats.Create(basedir, name)
 
-	// Save initial population FIXME it's for debugging
-	/*
-		for i := range pop.Pop {
-			pop.Pop[i].Draw(imgTemp)
-			imgTemp.WritePNG(fmt.Sprintf("pop_ind_%v.png", i))
+		// Build settings
+		var settings base.Settings
+		// Primitives to use
+		settings.Functionals = fun
+		settings.Terminals = ter
+		// Draw function to use
+		settings.Draw = drawfun
+
+		settings.Workers = *workers
+		settings.Ramped = *fInitRamped
+		if settings.Ramped {
+			fmt.Println("Using ramped initialization")
 		}
-	*/
 
-	// Number of parallel generators to setup
-	pipelineSize := 1 // Was 4 FIXME but there are statistics used in parallel and not ready for concurrent access
-	// Containers for pipelined operators
-	chXo := make([]<-chan ga.PipelineIndividual, pipelineSize)
-	chMut := make([]<-chan ga.PipelineIndividual, pipelineSize)
-
-	// Save best individual, for elitism
-	var elite ga.Individual = nil
-
-	// Save time before starting
-	//genTime := time.Now()
-
-	// Loop until max number of generation is reached
-	for g := 0; g < *numGen; g++ {
-		// Compute fitness for every individual with no fitness
-		fitnessEval := pop.Evaluate()
-		fitnessEval = fitnessEval
-		//
-		//if !*quiet {
-		//	fmt.Println("Generation ", g, "fit evals", fitnessEval, time.Since(genTime))
-		//	genTime = time.Now()
-		//}
-
-		// Compute various statistics
-		sta.Observe(pop)
-
-		// Statistics and samples
-		if g%*saveInterval == 0 {
-			snapName, snapPopName := sta.SaveSnapshot(pop, *quiet, countersKeys, statsKeys, intCountersKeys)
-			// Save best individual
-			if false {
-				pop.BestIndividual().Fitness()
-				pop.BestIndividual().(*base.Individual).ImgTemp.WritePNG(snapName)
+		// Pick initialization method based on flags
+		var genFuncBit func(minH, maxH int, funcs, terms []gp.Primitive) *node.Node
+
+		if *fInitFull && !*fInitGrow {
+			fmt.Println("Using init strategy: full")
+			genFuncBit = node.MakeTreeFull // Initialize tree using full
+		} else if !*fInitFull && *fInitGrow {
+			fmt.Println("Using init strategy: balanced grow")
+			genFuncBit = node.MakeTreeGrowBalanced // Initialize using grow
+		} else {
+			fmt.Println("Using init strategy: half-and-half")
+			genFuncBit = node.MakeTreeHalfAndHalf // Initialize using both (half and half)
+		}
+		settings.GenFunc = func(maxDep int) *node.Node {
+			t := genFuncBit(0, maxDep, fun, ter) // /* TODO */ sistemare la minH
+			settings.IntCounter(tree_init_depth).Count(node.Depth(t))
+			return t
+		}
+		if *grammarPath != "" {
+			// Subtrees generated by mutations would not follow the grammar
+			if *fMutSub || *fMutAre || *fMutLsubt {
+				fmt.Fprintln(os.Stderr, "ERROR: Subtree mutations cannot be used with -grammar")
+				return nil
+			}
+			genFunc, err := makeGrammarGenFunc(&settings, *grammarPath, *geWraps, *geLen, *geCodon)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "ERROR: Cannot load grammar", *grammarPath, err)
+				return nil
+			}
+			fmt.Println("Using init strategy: grammar", *grammarPath)
+			settings.GenFunc = genFunc
+		}
+
+		// Build statistic map
+		settings.Statistics = make(map[string]*sequence.SequenceStats)
+		settings.Counters = make(map[string]*counter.BoolCounter)
+		settings.IntCounters = make(map[string]*counter.IntCounter)
+
+		// Names of extra statistics
+		statsKeys := []string{}
+		countersKeys := []string{}
+		intCountersKeys := []string{}
+
+		intCountersKeys = append(intCountersKeys, tree_init_depth)
+		if *grammarPath != "" {
+			countersKeys = append(countersKeys, ge_invalid)
+			intCountersKeys = append(intCountersKeys, ge_wraps)
+		}
+
+		if *fMutSin {
+			countersKeys = append(countersKeys, mut_single_event, mut_single_improv, mut_single_node_leaves)
+			intCountersKeys = append(intCountersKeys, mut_single_node_depth, mut_single_node_repld)
+		}
+		if *fMutNod {
+			countersKeys = append(countersKeys, mut_multi_event, mut_multi_improv, mut_multi_node_leaves)
+			intCountersKeys = append(intCountersKeys, mut_multi_node_depth, mut_multi_node_repld)
+		}
+		if *fMutSub {
+			countersKeys = append(countersKeys, mut_tree_event, mut_tree_improv, mut_tree_node_leaves)
+			intCountersKeys = append(intCountersKeys, mut_tree_node_depth, mut_tree_node_repld)
+		}
+		if *fMutAre {
+			countersKeys = append(countersKeys, mut_area_event, mut_area_improv, mut_area_node_leaves)
+			intCountersKeys = append(intCountersKeys, mut_area_node_depth, mut_area_node_repld)
+		}
+		if *fMutLsubt {
+			countersKeys = append(countersKeys, mut_lsubt_event, mut_lsubt_improv, mut_lsubt_improv)
+			intCountersKeys = append(intCountersKeys, mut_lsubt_node_depth, mut_lsubt_node_repld)
+		}
+		if *fMutLoc {
+			countersKeys = append(countersKeys, mut_local_event, mut_local_improv)
+			//		intCountersKeys = append(intCountersKeys, "mut_local_") TODO
+		}
+		if *fMultiMut {
+			intCountersKeys = append(intCountersKeys, mut_count_multi)
+		}
+		if *cacheSize > 0 {
+			settings.Cache = base.NewFitnessCache(*cacheSize)
+			countersKeys = append(countersKeys, base.CacheHitCounter)
+		}
+
+		settings.ImgTarget = imgTarget
+		settings.MaxDepth = calcMaxDepth(imgTarget)
+
+		// Create temporary surface, of same size and mode
+		//settings.ImgTemp = imgut.Create(settings.ImgTarget.W, settings.ImgTarget.H, settings.ImgTarget.ColorSpace)
+		// Create temporary surface for the entire population
+		pImgCols := int(math.Ceil(math.Sqrt(float64(*popSize))))
+		pImgRows := int(math.Ceil(float64(*popSize) / float64(pImgCols)))
+		imgTempPop := imgut.Create(pImgCols*settings.ImgTarget.W, pImgRows*settings.ImgTarget.H, settings.ImgTarget.ColorSpace)
+
+		// Define the operators
+		settings.CrossOver = makeCrossover(&settings)
+		settings.Mutate = makeMultiMutation(&settings, *fMultiMut, *fMutSin, *fMutNod, *fMutSub, *fMutAre, *fMutLsubt, *fMutLoc)
+		settings.Simplify = Simplify
+		if *pSimp > 0 {
+			if Simplify == nil {
+				fmt.Fprintln(os.Stderr, "ERROR: Simplification is not available for this representation")
+				return nil
+			}
+			settings.Mutate = makeSimplifyingMutation(&settings, settings.Mutate, *pSimp)
+			intCountersKeys = append(intCountersKeys, simp_size_reduction)
+		}
+		var semOps SemanticOperators
+		if *fSemCross || *semStep > 0 {
+			if Semantic == nil {
+				fmt.Fprintln(os.Stderr, "ERROR: Semantic operators are not available for this representation")
+				return nil
 			}
-			// Save best individual code
+			if cp != nil {
+				// Offspring reference parents that are not saved in checkpoints
+				fmt.Fprintln(os.Stderr, "ERROR: Runs using semantic operators cannot be resumed")
+				return nil
+			}
+			if *islandSpec != "" {
+				// Each island would cache its parents under the same names, and
+				// the parents of migrants would not be found by the receiver
+				fmt.Fprintln(os.Stderr, "ERROR: Semantic operators cannot be used with -islands")
+				return nil
+			}
+			semOps = Semantic(settings.ImgTarget.W, settings.ImgTarget.H, func(maxDep int) *node.Node {
+				return genFuncBit(0, maxDep, fun, ter)
+			}, *semStep)
+			settings.Expand = func(t *node.Node) (*node.Node, error) {
+				return semOps.Expand(t, semMaxSavedSize)
+			}
+			intCountersKeys = append(intCountersKeys, gs_cache_size)
+		}
+		if *fSemCross {
+			settings.CrossOver = makeSemanticCrossover(&settings, semOps)
+			countersKeys = append(countersKeys, gs_cross_event, gs_cross_improv)
+		}
+		if *semStep > 0 {
+			settings.Mutate = makeSemanticMutation(&settings, semOps)
+			countersKeys = append(countersKeys, gs_mut_event, gs_mut_improv)
+		}
 
-			//	pop.BestIndividual().(*base.Individual).Draw(settings.ImgTemp)
-			//	settings.ImgTemp.WritePNG(snapName)
-			// Save pop images
-			pop.Draw(imgTempPop, pImgCols, pImgRows)
-			imgTempPop.WritePNG(snapPopName)
+		// Fitness
+		fitName, fitWeights, err := parseFitness(*fFitness)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: Invalid fitness", *fFitness, err)
+			return nil
+		}
+		if fitWeights != nil && fitName != "rgb" && fitName != "ycbcr" && fitName != "lab" {
+			fmt.Fprintln(os.Stderr, "ERROR: Fitness", fitName, "does not accept channel weights")
+			return nil
+		}
+		if fitName == "mse" {
+			settings.FitFunc = base.MakeFitMSE(settings.ImgTarget)
+		} else if fitName == "rmsed" {
+			statsKeys = append(statsKeys, "fit-delta-rmse")
+			settings.FitFunc = base.MakeFitEdge(settings.ImgTarget, settings.Statistics)
+		} else if fitName == "ssim" {
+			settings.FitFunc = base.MakeFitSSIM(settings.ImgTarget)
+		} else if fitName == "msssim" {
+			settings.FitFunc = base.MakeFitMSSSIM(settings.ImgTarget)
+		} else if fitName == "rgb" {
+			settings.FitFunc = base.MakeFitRGB(settings.ImgTarget, fitWeights)
+		} else if fitName == "ycbcr" {
+			settings.FitFunc = base.MakeFitYCbCr(settings.ImgTarget, fitWeights)
+		} else if fitName == "lab" {
+			settings.FitFunc = base.MakeFitLab(settings.ImgTarget, fitWeights)
+		} else {
+			statsKeys = append(statsKeys, "fit-delta-rmse")
+			settings.FitFunc = base.MakeFitRMSE(settings.ImgTarget)
 		}
 
-		// Setup parallel pipeline
-		selectionSize := len(pop.Pop) // int(float64(len(pop.Pop))*0.3)) if you want to randomly generate new individuals
-		chSel := ga.GenSelect(pop, selectionSize, float32(g)/float32(*numGen), elite)
-		for i := 0; i < pipelineSize; i++ {
-			chXo[i] = ga.GenCrossover(chSel, *pCross)
-			chMut[i] = ga.GenMutate(chXo[i], *pMut)
+		// Selection
+		ts := *tournSize
+		if *fSelect == "rmad" {
+			settings.Select = base.MakeSelectRMAD(ts, ts*ts, settings.BetterThan)
+		} else if *fSelect == "irmad" {
+			settings.Select = base.MakeSelectIRMAD(ts, ts*ts, settings.BetterThan)
+		} else if *fSelect == "nsga2" {
+			// Minimize RMSE, tree size and edge error, best individual is still picked by fitness
+			settings.ObjFunc = base.MakeObjectives(settings.ImgTarget)
+			settings.Select = base.MakeSelectNSGA2()
+		} else if *fSelect == "lexicase" || *fSelect == "elexicase" {
+			if *caseBlock < 1 {
+				fmt.Fprintln(os.Stderr, "ERROR: Invalid tile size", *caseBlock)
+				return nil
+			}
+			settings.CaseFunc = base.MakeCaseErrors(settings.ImgTarget, *caseBlock)
+			settings.Select = base.MakeSelectLexicase(*fSelect == "elexicase")
+		} else {
+			settings.Select = base.MakeSelectTourn(ts, settings.BetterThan)
 		}
-		var sel []ga.PipelineIndividual = ga.Collector(ga.FanIn(chMut...), selectionSize)
 
-		// Replace old population and compute statistics
-		for i := range sel {
-			pop.Pop[i] = sel[i].Ind.(*base.Individual)
-			sta.ObserveCrossoverFitness(sel[i].CrossoverFitness, sel[i].InitialFitness)
-			sta.ObserveMutationFitness(sel[i].MutationFitness, sel[i].CrossoverFitness)
+		// Build population
+		pop := new(base.Population)
+		pop.Set = &settings
+		//pop.TournSize = *tournSize
+		if cp == nil {
+			pop.Initialize(*popSize)
+			if *seedsDir != "" {
+				seeds, err := base.LoadTrees(*seedsDir, gp.DefaultRegistry.Lookup)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "ERROR: Cannot load seeds:", err)
+					return nil
+				}
+				n := pop.Seed(seeds, *seedFrac, *seedMut)
+				fmt.Println("Seeded", n, "individuals from", len(seeds), "trees in", *seedsDir)
+			}
+		} else {
+			if err := pop.Restore(cp.Pop, gp.DefaultRegistry.Lookup); err != nil {
+				fmt.Fprintln(os.Stderr, "ERROR: Cannot restore population:", err)
+				return nil
+			}
+			if err := sta.GobDecode(cp.Stats); err != nil {
+				fmt.Fprintln(os.Stderr, "ERROR: Cannot restore statistics:", err)
+				return nil
+			}
 		}
 
-		// When elitism is activated, get best individual
+		// Save initial population FIXME it's for debugging
+		/*
+			for i := range pop.Pop {
+				pop.Pop[i].Draw(imgTemp)
+				imgTemp.WritePNG(fmt.Sprintf("pop_ind_%v.png", i))
+			}
+		*/
+
+		// Build the engine driving the generational loop
+		engine := ga.NewEngine(pop, settings.BetterThan)
+		engine.PCross, engine.PMut = *pCross, *pMut
+		engine.MaxGen = *numGen
+		engine.PipelineSize = *pipelines
+		engine.Workers = *workers
 		if *fElite {
-			elite = pop.BestIndividual()
+			engine.Elitism = *eliteSize
+		}
+		if *fSelect == "nsga2" {
+			engine.Replace = ga.NSGA2Replacement
+		}
+		if *steadyState > 0 {
+			engine.SteadyState = *steadyState
+			// Every step breeds at least one offspring, but some may not need
+			// an evaluation: steps are bounded in case evaluations stall
+			engine.MaxGen = *numGen * *popSize
+			engine.Terminate = append(engine.Terminate, func(e *ga.Engine) bool {
+				return generationOf(e) >= *numGen
+			})
+			if *steadyReplace == "tourn" {
+				engine.SteadyReplace = ga.MakeReplaceTournamentLoser(*tournSize)
+			} else if *steadyReplace != "worst" {
+				fmt.Fprintln(os.Stderr, "ERROR: Unknown steady-state replacement", *steadyReplace)
+				return nil
+			}
 		}
 
-		// Build new individuals
-		//base.RampedFill(pop, len(sel), len(pop.Pop))
+		// Cached parents are needed only by the current population
+		if semOps != nil {
+			engine.OnGeneration = append(engine.OnGeneration, func(e *ga.Engine) {
+				live := make([]*node.Node, len(pop.Pop))
+				for i := range pop.Pop {
+					live[i] = pop.Pop[i].Node
+				}
+				settings.IntCounter(gs_cache_size).Count(semOps.Prune(live))
+			})
+		}
+
+		engine.OnOffspring = append(engine.OnOffspring, func(e *ga.Engine, sel []ga.PipelineIndividual) {
+			for i := range sel {
+				sta.ObserveCrossoverFitness(sel[i].CrossoverFitness, sel[i].InitialFitness)
+				sta.ObserveMutationFitness(sel[i].MutationFitness, sel[i].CrossoverFitness)
+			}
+		})
+
+		return &island{pop, engine, sta, countersKeys, statsKeys, intCountersKeys, imgTempPop, pImgCols, pImgRows}
+	}
+
+	// Build the islands, restoring the common flags after each one
+	common := flagValues(fs)
+	islands := make([]*island, len(islandArgs))
+	for i, args := range islandArgs {
+		name := basename
+		if len(islandArgs) > 1 {
+			name = fmt.Sprintf("%v-island%v", basename, i)
+		}
+		fs.Parse(args)
+		islands[i] = newIsland(name)
+		for f, val := range common {
+			fs.Set(f, val)
+		}
+		if islands[i] == nil {
+			return
+		}
 	}
-	fitnessEval := pop.Evaluate()
-	fitnessEval = fitnessEval
-	// Population statistics
-	sta.Observe(pop)
 
-	//if !*quiet {
-	//	fmt.Println("Generation", *numGen, "fit evals", fitnessEval)
-	//	fmt.Println("Best individual", pop.BestIndividual())
-	//}
+	var best ga.Individual
+	if len(islands) == 1 {
+		isl := islands[0]
+		engine, pop, sta := isl.engine, isl.pop, isl.sta
 
-	snapName, snapPopName := sta.SaveSnapshot(pop, *quiet, countersKeys, statsKeys, intCountersKeys)
-	// Save best individual
-	if false {
-		pop.BestIndividual().Fitness()
-		pop.BestIndividual().(*base.Individual).ImgTemp.WritePNG(snapName)
+		// Generation restored from checkpoint, if any
+		resumedGen := -1
+		if cp != nil {
+			engine.Generation, engine.Evaluations = cp.Generation, cp.Evaluations
+			resumedGen = cp.Generation
+			rand.Seed(cp.Seed)
+		}
+
+		// Save a checkpoint at every snapshot. The RNG is re-seeded with a value
+		// stored in the checkpoint, so that resumed runs proceed in the same way
+		checkpointGen := makeNewGeneration()
+		engine.OnGeneration = append(engine.OnGeneration, func(e *ga.Engine) {
+			gen, isNew := checkpointGen(e)
+			if !isNew || gen%*saveInterval != 0 || e.Generation == resumedGen {
+				return
+			}
+			popState, err := pop.Checkpoint()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "ERROR: Cannot save checkpoint:", err)
+				return
+			}
+			staState, err := sta.GobEncode()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "ERROR: Cannot save checkpoint:", err)
+				return
+			}
+			seed := rand.Int63()
+			cp := &checkpoint{flagValues(fs), e.Generation, e.Evaluations, seed, staState, popState}
+			if err := saveCheckpoint(checkpointPath(basedir, basename), cp); err != nil {
+				fmt.Fprintln(os.Stderr, "ERROR: Cannot save checkpoint:", err)
+				return
+			}
+			rand.Seed(seed)
+		})
+
+		// Compute various statistics and save snapshots
+		observeGen := makeNewGeneration()
+		engine.OnGeneration = append(engine.OnGeneration, func(e *ga.Engine) {
+			if gen, isNew := observeGen(e); isNew {
+				isl.observe(gen, *saveInterval, *quiet)
+			}
+		})
+
+		// Loop until max number of generation is reached
+		engine.Run()
+		best = pop.BestIndividual()
+	} else {
+		engines := make([]*ga.Engine, len(islands))
+		for i := range islands {
+			engines[i] = islands[i].engine
+		}
+		arch := ga.NewArchipelago(engines...)
+		arch.Topology, arch.Emigrate = topology, emigrate
+		arch.Interval, arch.Migrants = *migrInterval, *migrSize
+
+		// Statistics are computed for every island, in order
+		observeGen := make([]func(*ga.Engine) (int, bool), len(islands))
+		for i := range observeGen {
+			observeGen[i] = makeNewGeneration()
+		}
+		arch.OnGeneration = append(arch.OnGeneration, func(a *ga.Archipelago) {
+			for i, isl := range islands {
+				gen, isNew := observeGen[i](isl.engine)
+				if !isNew {
+					continue
+				}
+				if gen%*saveInterval == 0 && !*quiet {
+					fmt.Println("Island", i, islandArgs[i])
+				}
+				isl.observe(gen, *saveInterval, *quiet)
+			}
+		})
+
+		// Loop until max number of generation is reached
+		arch.Run()
+		best = arch.BestIndividual()
+	}
+
+	// Population statistics
+	for i, isl := range islands {
+		isl.sta.Observe(isl.pop)
+		if len(islands) > 1 && !*quiet {
+			fmt.Println("Island", i, islandArgs[i])
+		}
+		isl.snapshot(*quiet)
 	}
-	//	pop.BestIndividual().(*base.Individual).Draw(settings.ImgTemp)
-	//	settings.ImgTemp.WritePNG(snapName)
-	// Save pop images
-	pop.Draw(imgTempPop, pImgCols, pImgRows)
-	imgTempPop.WritePNG(snapPopName)
 
 	if !*quiet {
 		fmt.Println("Best individual:")
-		fmt.Println(pop.BestIndividual())
+		fmt.Println(best)
 	}
 
 	elapsedTime := time.Since(startTime)
-	fmt.Println("Execution took %s", elapsedTime)
+	fmt.Printf("Execution took %s\n", elapsedTime)
 
 	/*
 		bestName := fmt.Sprintf("%v/best/%v.png", basedir, basename)
@@ -6,6 +6,7 @@ import (
 	"github.com/akiross/gogp/apps/base"
 	"github.com/akiross/gogp/apps/base/repr/rr"
 	"github.com/akiross/gogp/apps/evolve"
+	"github.com/akiross/gogp/gp"
 	"github.com/akiross/gogp/image/draw2d/imgut"
 	rrepr "github.com/akiross/gogp/repr/rr"
 	"math/rand"
@@ -53,12 +54,7 @@ func main() {
 		rr.Terminals = append(rr.Terminals, rrepr.MakeEphimeral("MakeDiagLine", rr.MakeDiagLine))
 	}
 	if *fPalSolid {
-		count := 16 // Number of total colors, from black to white
-		for i := 0; i < count; i++ {
-			c := float64(i) / float64(count-1)
-			name := fmt.Sprintf("G%02X", int(c*255))
-			rr.Terminals = append(rr.Terminals, rrepr.MakeTerminal(name, rrepr.Filler(c, c, c, 1)))
-		}
+		rr.Terminals = append(rr.Terminals, rr.Palette(16)...) // 16 colors, from black to white
 	}
 
 	// Build some shades
@@ -72,30 +68,17 @@ func main() {
 	+-----------+- c    to light gray (E) from position (a, a) to position (b, c)
 	*/
 	if *fPalShade {
-		count := 8
-		reps := 8
 		// TODO we didn't implement the strategy above yet.
-		//sidePoints := 8
-		for i := 0; i <= count; i++ {
-			c := float64(i) / float64(count)
-			for j := i + 1; j <= count; j++ {
-				k := float64(j) / float64(count)
-				// Multiple copie
-				for n := 0; n < reps; n++ {
-					sx, sy, ex, ey := rand.Float64(), rand.Float64(), rand.Float64(), rand.Float64()
-					name := fmt.Sprintf("L_%d-%d_%d-%d_%d-%d", int(c*255), int(k*255), int(sx*100), int(sy*100), int(ex*100), int(ey*100))
-					rr.Terminals = append(rr.Terminals, rrepr.MakeTerminal(name, rrepr.LinShade(c, k, sx, sy, ex, ey)))
-				}
-			}
-		}
-
+		rr.Terminals = append(rr.Terminals, rr.Shades(8, 8)...)
 	}
 
 	// Fallback on black and white
 	if len(rr.Terminals) == 0 {
-		rr.Terminals = append(rr.Terminals, rrepr.MakeTerminal("Black", rrepr.Filler(0, 0, 0, 1)))
-		rr.Terminals = append(rr.Terminals, rrepr.MakeTerminal("White", rrepr.Filler(1, 1, 1, 1)))
+		rr.Terminals = append(rr.Terminals, rr.BlackWhite()...)
 	}
+	// Terminals generated by ephemerals and shades can be loaded from saved trees
+	rr.RegisterEphemerals(gp.DefaultRegistry)
+
 	// Run second phase
 	evolve.Evolve(rr.MakeMaxDepth(*maxDepth), rr.Functionals, rr.Terminals, draw)
 }
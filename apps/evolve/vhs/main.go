@@ -4,6 +4,7 @@ import (
 	"github.com/akiross/gogp/apps/base"
 	"github.com/akiross/gogp/apps/base/repr/vhs"
 	"github.com/akiross/gogp/apps/evolve"
+	"github.com/akiross/gogp/gp"
 	"github.com/akiross/gogp/image/draw2d/imgut"
 )
 
@@ -12,5 +13,7 @@ func draw(ind *base.Individual, img *imgut.Image) {
 }
 
 func main() {
+	// Shaded terminals can be loaded from saved trees
+	vhs.RegisterTerminals(gp.DefaultRegistry)
 	evolve.Evolve(vhs.MaxDepth, vhs.Functionals, vhs.Terminals, draw)
 }
@@ -1,6 +1,8 @@
 package stats
 
 import (
+	"bytes"
+	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	"github.com/akiross/gogp/apps/base"
@@ -36,6 +38,33 @@ func Create(basedir, basename string) *Stats {
 	return &stats
 }
 
+type statsGob struct {
+	SnapCount, ObsCount  int
+	Depth, Size, Fitness *variance.Variance
+	Min                  *min.Min
+	Max                  *max.Max
+	XoImpr, MutImpr      *counter.BoolCounter
+}
+
+// Encode the accumulated statistics, to be saved in a checkpoint.
+// Paths and timing are not saved
+func (stats *Stats) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&statsGob{stats.snapCount, stats.obsCount,
+		&stats.depth, &stats.size, &stats.fitness, &stats.min, &stats.max, &stats.xoImpr, &stats.mutImpr})
+	return buf.Bytes(), err
+}
+
+// Restore the statistics encoded by GobEncode
+func (stats *Stats) GobDecode(data []byte) error {
+	s := statsGob{0, 0, &stats.depth, &stats.size, &stats.fitness, &stats.min, &stats.max, &stats.xoImpr, &stats.mutImpr}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	stats.snapCount, stats.obsCount = s.SnapCount, s.ObsCount
+	return nil
+}
+
 // Returns a slice with depths for every individual in the population
 func (stats *Stats) PopulationDepths(pop *base.Population) []int {
 	depths := make([]int, len(pop.Pop))
@@ -73,7 +102,7 @@ func (stats *Stats) ObserveMutationFitness(newFit, oldFit ga.Fitness) {
 	stats.mutImpr.Count(newFit < oldFit)
 }
 
-func writeIndividual(ind ga.Individual, outFile string) {
+func writeIndividual(ind interface{}, outFile string) {
 	f, err := os.Create(outFile)
 	if err != nil {
 		panic(err)
@@ -87,6 +116,43 @@ func writeIndividual(ind ga.Individual, outFile string) {
 	}
 }
 
+// The tree t as it must be saved, expanding the references to other trees
+func savedTree(pop *base.Population, t *node.Node) (*node.Node, error) {
+	if pop.Set.Expand == nil {
+		return t, nil
+	}
+	return pop.Set.Expand(t)
+}
+
+// Write the Pareto front of the population, with objectives and trees
+func writeParetoFront(pop *base.Population, outFile string) error {
+	inds := make([]ga.Individual, len(pop.Pop))
+	for i := range pop.Pop {
+		inds[i] = pop.Pop[i]
+	}
+	type frontEntry struct {
+		Objectives ga.Objectives `json:"objectives"`
+		Tree       *node.Node    `json:"tree"`
+	}
+	var front []frontEntry
+	for _, ind := range ga.ParetoFront(inds) {
+		tree, err := savedTree(pop, ind.(*base.Individual).Node)
+		if err != nil {
+			return err
+		}
+		front = append(front, frontEntry{ind.(ga.MultiObjective).Objectives(), tree})
+	}
+	f, err := os.Create(outFile)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(front); err != nil {
+		panic(err)
+	}
+	return nil
+}
+
 // Another stat: check for correlation between tree depth and tree fitness (deep are good? short are good? what in between?)
 // In general, we would like to keep some time-series, but we cannot keep them for every individual or it will take way too much memory!
 
@@ -103,7 +169,20 @@ func (stats *Stats) SaveSnapshot(pop *base.Population, quiet bool, cntKeys, staK
 	logPrefix := fmt.Sprintf("%v/log/%v-", stats.basedir, stats.basename)
 	bestTree := fmt.Sprintf(logPrefix+"tree-%v.json", stats.snapCount)
 
-	writeIndividual(pop.BestIndividual(), bestTree)
+	if best, err := savedTree(pop, pop.BestIndividual().(*base.Individual).Node); err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR: Cannot save the best tree", bestTree, err)
+	} else {
+		writeIndividual(best, bestTree)
+		if pop.Set.Simplify != nil {
+			writeIndividual(pop.Set.Simplify(best), fmt.Sprintf(logPrefix+"tree-simple-%v.json", stats.snapCount))
+		}
+	}
+	if pop.Set.ObjFunc != nil {
+		paretoName := fmt.Sprintf(logPrefix+"pareto-%v.json", stats.snapCount)
+		if err := writeParetoFront(pop, paretoName); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: Cannot save the Pareto front", paretoName, err)
+		}
+	}
 
 	const wideField = 40
 
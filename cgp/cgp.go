@@ -0,0 +1,255 @@
+/*
+Package cgp implements Cartesian Genetic Programming.
+
+A genome is a grid of nodes, stored column by column. Each node has a
+function gene, indexing the functionals, and one connection gene for each
+argument of the largest functional, addressing an input or a node in one of
+the LevelsBack previous columns. Inputs are the terminals. Output genes
+address the nodes (or inputs) producing the results.
+
+Only active nodes, i.e. those reachable from the outputs, are compiled:
+the result is the same gp.Primitive call graph that node.CompileTree would
+produce on the equivalent tree, which can be obtained with ToTree.
+*/
+package cgp
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/node"
+	"math/rand"
+)
+
+// Shape and primitives of genomes
+type Params struct {
+	Rows, Cols int
+	LevelsBack int // How many columns back a node can connect to
+	Outputs    int
+	Funcs      []gp.Primitive
+	Terms      []gp.Primitive
+}
+
+// Largest arity of the functionals, i.e. number of connection genes of a node
+func (p *Params) maxArity() int {
+	max := 0
+	for _, f := range p.Funcs {
+		if f.Arity() > max {
+			max = f.Arity()
+		}
+	}
+	return max
+}
+
+// Addresses from which a node in column c can take its inputs: all
+// the inputs and the nodes in [c-LevelsBack, c)
+func (p *Params) randomSource(c int) int {
+	nIn := len(p.Terms)
+	first := c - p.LevelsBack
+	if first < 0 {
+		first = 0
+	}
+	n := rand.Intn(nIn + (c-first)*p.Rows)
+	if n < nIn {
+		return n
+	}
+	return nIn + first*p.Rows + n - nIn
+}
+
+// Random address for an output gene: any input or node
+func (p *Params) randomOutput() int {
+	return rand.Intn(len(p.Terms) + p.Rows*p.Cols)
+}
+
+type Gene struct {
+	Func int   // Index in Params.Funcs
+	Conn []int // Addresses of the arguments, only the first Arity() are used
+}
+
+type Genome struct {
+	Params  *Params
+	Inputs  []gp.Primitive // Terminals, with ephemerals already generated
+	Nodes   []Gene         // Rows*Cols nodes, column by column
+	Outputs []int          // Addresses of the outputs
+}
+
+// Build a random genome
+func NewGenome(p *Params) *Genome {
+	g := &Genome{p, make([]gp.Primitive, len(p.Terms)), make([]Gene, p.Rows*p.Cols), make([]int, p.Outputs)}
+	for i, t := range p.Terms {
+		if t.IsEphemeral() {
+			g.Inputs[i] = t.Run()
+		} else {
+			g.Inputs[i] = t
+		}
+	}
+	arity := p.maxArity()
+	for k := range g.Nodes {
+		g.Nodes[k] = Gene{rand.Intn(len(p.Funcs)), make([]int, arity)}
+		for j := range g.Nodes[k].Conn {
+			g.Nodes[k].Conn[j] = p.randomSource(k / p.Rows)
+		}
+	}
+	for o := range g.Outputs {
+		g.Outputs[o] = p.randomOutput()
+	}
+	return g
+}
+
+func (g *Genome) Copy() *Genome {
+	c := &Genome{g.Params, append([]gp.Primitive{}, g.Inputs...), make([]Gene, len(g.Nodes)), append([]int{}, g.Outputs...)}
+	for k := range g.Nodes {
+		c.Nodes[k] = Gene{g.Nodes[k].Func, append([]int{}, g.Nodes[k].Conn...)}
+	}
+	return c
+}
+
+// Primitive of the node at index k
+func (g *Genome) function(k int) gp.Primitive {
+	return g.Params.Funcs[g.Nodes[k].Func]
+}
+
+// Flag the active nodes, i.e. those reachable from the outputs
+func (g *Genome) Active() []bool {
+	nIn := len(g.Inputs)
+	active := make([]bool, len(g.Nodes))
+	for _, o := range g.Outputs {
+		if o >= nIn {
+			active[o-nIn] = true
+		}
+	}
+	// Connections point only backwards, so a single reverse scan is enough
+	for k := len(g.Nodes) - 1; k >= 0; k-- {
+		if !active[k] {
+			continue
+		}
+		for _, c := range g.Nodes[k].Conn[:g.function(k).Arity()] {
+			if c >= nIn {
+				active[c-nIn] = true
+			}
+		}
+	}
+	return active
+}
+
+// Number of active nodes
+func (g *Genome) ActiveCount() (n int) {
+	for _, a := range g.Active() {
+		if a {
+			n++
+		}
+	}
+	return
+}
+
+// Compile the active nodes, returning the primitive of each output
+func (g *Genome) Compile() []gp.Primitive {
+	nIn := len(g.Inputs)
+	vals := make([]gp.Primitive, nIn+len(g.Nodes))
+	for i, t := range g.Inputs {
+		vals[i] = t.Run()
+	}
+	for k, a := range g.Active() {
+		if !a {
+			continue
+		}
+		f := g.function(k)
+		args := make([]gp.Primitive, f.Arity())
+		for j := range args {
+			args[j] = vals[g.Nodes[k].Conn[j]]
+		}
+		vals[nIn+k] = f.Run(args...)
+	}
+	outs := make([]gp.Primitive, len(g.Outputs))
+	for o, addr := range g.Outputs {
+		outs[o] = vals[addr]
+	}
+	return outs
+}
+
+// Expand the graph of output o in a tree. Nodes used more than once are
+// duplicated, so the tree can be much larger than the active graph
+func (g *Genome) ToTree(o int) *node.Node {
+	var build func(addr int) *node.Node
+	build = func(addr int) *node.Node {
+		nIn := len(g.Inputs)
+		if addr < nIn {
+			return node.New(g.Inputs[addr])
+		}
+		k := addr - nIn
+		f := g.function(k)
+		children := make([]*node.Node, f.Arity())
+		for j := range children {
+			children[j] = build(g.Nodes[k].Conn[j])
+		}
+		return node.New(f, children...)
+	}
+	return build(g.Outputs[o])
+}
+
+// Returns true if the active graphs of g and h are the same, so they compile
+// to the same outputs
+func (g *Genome) SameActive(h *Genome) bool {
+	if len(g.Outputs) != len(h.Outputs) || len(g.Nodes) != len(h.Nodes) {
+		return false
+	}
+	for o := range g.Outputs {
+		if g.Outputs[o] != h.Outputs[o] {
+			return false
+		}
+	}
+	ag, ah := g.Active(), h.Active()
+	for k := range ag {
+		if ag[k] != ah[k] {
+			return false
+		}
+		if !ag[k] {
+			continue
+		}
+		if g.Nodes[k].Func != h.Nodes[k].Func {
+			return false
+		}
+		for j := 0; j < g.function(k).Arity(); j++ {
+			if g.Nodes[k].Conn[j] != h.Nodes[k].Conn[j] {
+				return false
+			}
+		}
+	}
+	for i := range g.Inputs {
+		if g.Inputs[i].Name() != h.Inputs[i].Name() {
+			return false
+		}
+	}
+	return true
+}
+
+// Name of the input or node at address addr
+func (g *Genome) addrName(addr int) string {
+	if addr < len(g.Inputs) {
+		return g.Inputs[addr].Name()
+	}
+	return fmt.Sprintf("n%v", addr-len(g.Inputs))
+}
+
+// Lists the active nodes and the outputs
+func (g *Genome) String() string {
+	var buf bytes.Buffer
+	for k, a := range g.Active() {
+		if !a {
+			continue
+		}
+		f := g.function(k)
+		fmt.Fprintf(&buf, "n%v = %v(", k, f.Name())
+		for j, c := range g.Nodes[k].Conn[:f.Arity()] {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(g.addrName(c))
+		}
+		buf.WriteString(")\n")
+	}
+	for o, addr := range g.Outputs {
+		fmt.Fprintf(&buf, "out%v = %v\n", o, g.addrName(addr))
+	}
+	return buf.String()
+}
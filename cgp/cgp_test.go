@@ -0,0 +1,124 @@
+package cgp
+
+import (
+	"github.com/akiross/gogp/ga"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/node"
+	"math/rand"
+	"testing"
+)
+
+// Integer expressions of x
+type expr struct {
+	name  string
+	arity int
+	eval  func(x int) int
+	op    func(a, b int) int
+}
+
+func (e *expr) IsFunctional() bool { return e.arity > 0 }
+func (e *expr) IsEphemeral() bool  { return false }
+func (e *expr) Arity() int         { return e.arity }
+func (e *expr) Name() string       { return e.name }
+func (e *expr) Run(args ...gp.Primitive) gp.Primitive {
+	if e.arity <= 0 {
+		return e
+	}
+	a, b := args[0].(*expr), args[1].(*expr)
+	return &expr{"", -1, func(x int) int { return e.op(a.eval(x), b.eval(x)) }, nil}
+}
+
+func testParams() *Params {
+	return &Params{
+		Rows: 2, Cols: 10, LevelsBack: 3, Outputs: 1,
+		Funcs: []gp.Primitive{
+			&expr{"Add", 2, nil, func(a, b int) int { return a + b }},
+			&expr{"Sub", 2, nil, func(a, b int) int { return a - b }},
+			&expr{"Mul", 2, nil, func(a, b int) int { return a * b }},
+		},
+		Terms: []gp.Primitive{
+			&expr{"X", -1, func(x int) int { return x }, nil},
+			&expr{"One", -1, func(int) int { return 1 }, nil},
+		},
+	}
+}
+
+func TestActive(t *testing.T) {
+	p := testParams()
+	p.Rows, p.Cols = 1, 3
+	g := &Genome{p, p.Terms, []Gene{
+		{2, []int{0, 0}}, // n0 = X * X
+		{0, []int{1, 1}}, // n1 = One + One (inactive)
+		{0, []int{2, 0}}, // n2 = n0 + X
+	}, []int{4}}
+	active := g.Active()
+	if !active[0] || active[1] || !active[2] || g.ActiveCount() != 2 {
+		t.Error("Wrong active nodes", active)
+	}
+	f := g.Compile()[0].(*expr)
+	for x := -3; x <= 3; x++ {
+		if f.eval(x) != x*x+x {
+			t.Error("Genome computed", f.eval(x), "for x =", x)
+		}
+	}
+}
+
+func TestCompileAsTree(t *testing.T) {
+	p := testParams()
+	mut := MakePointMutation()
+	for i := 0; i < 200; i++ {
+		g := NewGenome(p)
+		mut(0.1, g)
+		// Connections must respect levels back
+		for k := range g.Nodes {
+			for _, c := range g.Nodes[k].Conn {
+				col := (c - len(p.Terms)) / p.Rows
+				if c >= len(p.Terms) && (col >= k/p.Rows || col < k/p.Rows-p.LevelsBack) {
+					t.Fatal("Node", k, "connected to invalid address", c)
+				}
+			}
+		}
+		f := g.Compile()[0].(*expr)
+		ft := node.CompileTree(g.ToTree(0)).(*expr)
+		for x := -3; x <= 3; x++ {
+			if f.eval(x) != ft.eval(x) {
+				t.Fatal("Compiled genome and tree differ for x =", x, g)
+			}
+		}
+	}
+}
+
+func TestES(t *testing.T) {
+	rand.Seed(1)
+	// Symbolic regression of x^2 + x
+	fit := func(g *Genome) ga.Fitness {
+		f := g.Compile()[0].(*expr)
+		var err ga.Fitness
+		for x := -5; x <= 5; x++ {
+			d := f.eval(x) - (x*x + x)
+			err += ga.Fitness(d * d)
+		}
+		return err
+	}
+	lower := func(a, b ga.Fitness) bool { return a < b }
+	es := NewES(testParams(), 4, 0.05, fit, lower)
+	first := es.ParentFit
+	es.Terminate = append(es.Terminate, MaxGenerations(300), TargetFitness(0))
+	es.Run()
+	if es.ParentFit > first {
+		t.Error("Parent fitness got worse:", first, "->", es.ParentFit)
+	}
+	if es.Evaluations > 1+es.Generation*es.Lambda {
+		t.Error("Too many evaluations:", es.Evaluations)
+	}
+	if fit(es.Parent) != es.ParentFit {
+		t.Error("Parent fitness is stale")
+	}
+
+	es.Mutate = MakeActiveMutation()
+	g := es.Parent.Copy()
+	es.Mutate(0, g)
+	if g.SameActive(es.Parent) {
+		t.Error("Active mutation did not change the active graph")
+	}
+}
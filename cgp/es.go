@@ -0,0 +1,161 @@
+package cgp
+
+import (
+	"github.com/akiross/gogp/ga"
+	"math/rand"
+)
+
+// Point mutation: each gene (function, connection and output genes) is
+// changed to a random valid value with probability pMut.
+// Returns the number of mutated genes
+func MakePointMutation() func(float64, *Genome) int {
+	return func(pMut float64, g *Genome) int {
+		p := g.Params
+		mutCount := 0
+		for k := range g.Nodes {
+			if rand.Float64() < pMut {
+				g.Nodes[k].Func = rand.Intn(len(p.Funcs))
+				mutCount++
+			}
+			for j := range g.Nodes[k].Conn {
+				if rand.Float64() < pMut {
+					g.Nodes[k].Conn[j] = p.randomSource(k / p.Rows)
+					mutCount++
+				}
+			}
+		}
+		for o := range g.Outputs {
+			if rand.Float64() < pMut {
+				g.Outputs[o] = p.randomOutput()
+				mutCount++
+			}
+		}
+		return mutCount
+	}
+}
+
+// Active mutation: genes are mutated at random, one at a time, until an
+// active gene is changed. The pMut parameter is ignored
+func MakeActiveMutation() func(float64, *Genome) int {
+	return func(_ float64, g *Genome) int {
+		p := g.Params
+		active := g.Active()
+		nConn := p.maxArity()
+		mutCount := 0
+		for {
+			mutCount++
+			// Pick a gene among functions, connections and outputs
+			i := rand.Intn(len(g.Nodes)*(1+nConn) + len(g.Outputs))
+			if i >= len(g.Nodes)*(1+nConn) {
+				o := i - len(g.Nodes)*(1+nConn)
+				old := g.Outputs[o]
+				if g.Outputs[o] = p.randomOutput(); g.Outputs[o] != old {
+					return mutCount
+				}
+				continue
+			}
+			k, j := i/(1+nConn), i%(1+nConn)
+			if j == 0 {
+				old := g.Nodes[k].Func
+				g.Nodes[k].Func = rand.Intn(len(p.Funcs))
+				if active[k] && g.Nodes[k].Func != old {
+					return mutCount
+				}
+			} else {
+				old := g.Nodes[k].Conn[j-1]
+				g.Nodes[k].Conn[j-1] = p.randomSource(k / p.Rows)
+				// Only connections used by the current function matter
+				if active[k] && j-1 < g.function(k).Arity() && g.Nodes[k].Conn[j-1] != old {
+					return mutCount
+				}
+			}
+		}
+	}
+}
+
+// ES runs a (1+λ) evolution strategy: at each generation λ offspring are
+// produced by mutating the parent, and the best one replaces the parent if
+// it is not worse. Accepting equally good offspring allows neutral drift
+type ES struct {
+	Lambda     int
+	PMut       float64
+	Mutate     func(float64, *Genome) int // Default is point mutation
+	FitFunc    func(*Genome) ga.Fitness   // Fitness of a genome
+	BetterThan func(a, b ga.Fitness) bool // Fitness comparison
+	Terminate  []func(es *ES) bool        // Termination criteria, checked before each generation
+	OnImprove  func(es *ES)               // Called when the parent fitness improves, if not nil
+
+	Parent      *Genome
+	ParentFit   ga.Fitness
+	Generation  int
+	Evaluations int
+}
+
+// Build a strategy starting from a random genome
+func NewES(p *Params, lambda int, pMut float64, fit func(*Genome) ga.Fitness, betterThan func(a, b ga.Fitness) bool) *ES {
+	es := &ES{
+		Lambda:     lambda,
+		PMut:       pMut,
+		Mutate:     MakePointMutation(),
+		FitFunc:    fit,
+		BetterThan: betterThan,
+		Parent:     NewGenome(p),
+	}
+	es.ParentFit = fit(es.Parent)
+	es.Evaluations = 1
+	return es
+}
+
+// Perform one generation. Offspring whose active graph did not change
+// inherit the fitness of the parent without being evaluated
+func (es *ES) Step() {
+	var best *Genome
+	var bestFit ga.Fitness
+	for i := 0; i < es.Lambda; i++ {
+		child := es.Parent.Copy()
+		es.Mutate(es.PMut, child)
+		fit := es.ParentFit
+		if !child.SameActive(es.Parent) {
+			fit = es.FitFunc(child)
+			es.Evaluations++
+		}
+		if best == nil || es.BetterThan(fit, bestFit) {
+			best, bestFit = child, fit
+		}
+	}
+	// Neutral drift: replace the parent also when fitness is equal
+	if best != nil && !es.BetterThan(es.ParentFit, bestFit) {
+		improved := es.BetterThan(bestFit, es.ParentFit)
+		es.Parent, es.ParentFit = best, bestFit
+		if improved && es.OnImprove != nil {
+			es.OnImprove(es)
+		}
+	}
+	es.Generation++
+}
+
+// Run generations until a termination criteria is met, there must be at least one
+func (es *ES) Run() {
+	for {
+		for _, t := range es.Terminate {
+			if t(es) {
+				return
+			}
+		}
+		es.Step()
+	}
+}
+
+// Stop after the given number of generations
+func MaxGenerations(n int) func(*ES) bool {
+	return func(es *ES) bool {
+		return es.Generation >= n
+	}
+}
+
+// Stop when the parent reaches the target fitness
+func TargetFitness(target ga.Fitness) func(*ES) bool {
+	return func(es *ES) bool {
+		return !es.BetterThan(target, es.ParentFit)
+	}
+}
@@ -0,0 +1,270 @@
+package ga
+
+import (
+	"math/rand"
+)
+
+// A Termination returns true when the engine should stop evolving
+type Termination func(e *Engine) bool
+
+// A Replacement builds the next generation of pop using the offspring.
+// Elite individuals are not part of offspring and are placed by the engine
+type Replacement func(pop Population, offspring []Individual, elite int)
+
+// A SteadyReplacement returns the index of the individual of pop replaced by
+// an offspring in steady-state evolution
+type SteadyReplacement func(pop Population, betterThan func(a, b Fitness) bool) int
+
+// A Hook is called by the engine at some point of the generational loop
+type Hook func(e *Engine)
+
+// An OffspringHook receives the individuals produced by the variation pipeline
+type OffspringHook func(e *Engine, offspring []PipelineIndividual)
+
+// Engine drives a Population through the generational loop:
+// evaluate, check termination, select, crossover, mutate and replace.
+type Engine struct {
+	Pop          Population
+	BetterThan   func(a, b Fitness) bool // Fitness comparison, used for elitism and replacement
+	PCross, PMut float64                 // Crossover and mutation probabilities
+	MaxGen       int                     // Number of generations to run, 0 means no limit
+	Elitism      int                     // Number of best individuals copied unchanged in next generation
+	PipelineSize int                     // Number of parallel crossover/mutation stages
+	Workers      int                     // Number of goroutines evaluating the offspring while breeding
+	Terminate    []Termination           // Extra termination criteria, checked after evaluation
+	Replace      Replacement             // How offspring replace the population (default GenerationalReplacement)
+
+	// Steady-state mode: at every step only SteadyState offspring are bred,
+	// and each one replaces the individual picked by SteadyReplace (default
+	// ReplaceWorst). Elitism and Replace are not used. 0 for generational mode
+	SteadyState   int
+	SteadyReplace SteadyReplacement
+
+	OnGeneration []Hook          // Called after evaluation, before breeding
+	OnOffspring  []OffspringHook // Called after variation, before replacement
+
+	Generation  int // Current generation, or step in steady-state mode
+	Evaluations int // Total number of fitness evaluations performed, as counted by Pop
+}
+
+// Build an engine with the default settings for the given population
+func NewEngine(pop Population, betterThan func(a, b Fitness) bool) *Engine {
+	return &Engine{
+		Pop:          pop,
+		BetterThan:   betterThan,
+		PCross:       0.8,
+		PMut:         0.1,
+		PipelineSize: 1,
+		Workers:      1,
+		Replace:      GenerationalReplacement,
+	}
+}
+
+// Stop after the given number of generations
+func MaxGenerations(n int) Termination {
+	return func(e *Engine) bool {
+		return e.Generation >= n
+	}
+}
+
+// Stop after the given number of fitness evaluations
+func MaxEvaluations(n int) Termination {
+	return func(e *Engine) bool {
+		return e.Evaluations >= n
+	}
+}
+
+// Stop when the best individual is at least as good as target
+func TargetFitness(target Fitness) Termination {
+	return func(e *Engine) bool {
+		return !e.BetterThan(target, e.Pop.BestIndividual().Fitness())
+	}
+}
+
+// Stop when the best fitness did not improve for the given number of generations
+func Stagnation(gens int) Termination {
+	var best Fitness
+	var lastImpr, lastGen int = 0, -1
+	return func(e *Engine) bool {
+		fit := e.Pop.BestIndividual().Fitness()
+		if lastGen < 0 || e.BetterThan(fit, best) {
+			best = fit
+			lastImpr = e.Generation
+		}
+		lastGen = e.Generation
+		return e.Generation-lastImpr >= gens
+	}
+}
+
+// Offspring take the place of the parents, in order, after the elite
+func GenerationalReplacement(pop Population, offspring []Individual, elite int) {
+	for i := range offspring {
+		pop.Replace(elite+i, offspring[i])
+	}
+}
+
+// Parents and offspring compete, and the best ones survive (mu+lambda).
+// Elite individuals are already in place and are not replaced
+func MakeTruncationReplacement(betterThan func(a, b Fitness) bool) Replacement {
+	return func(pop Population, offspring []Individual, elite int) {
+		pool := make([]Individual, 0, pop.Size()-elite+len(offspring))
+		for i := elite; i < pop.Size(); i++ {
+			pool = append(pool, pop.Get(i))
+		}
+		pool = append(pool, offspring...)
+		sortIndividuals(pool, betterThan)
+		for i := elite; i < pop.Size(); i++ {
+			pop.Replace(i, pool[i-elite])
+		}
+	}
+}
+
+// The worst individual is replaced
+func ReplaceWorst(pop Population, betterThan func(a, b Fitness) bool) int {
+	w := 0
+	for i := 1; i < pop.Size(); i++ {
+		if betterThan(pop.Get(w).Fitness(), pop.Get(i).Fitness()) {
+			w = i
+		}
+	}
+	return w
+}
+
+// The loser of a tournament among k random individuals is replaced
+func MakeReplaceTournamentLoser(k int) SteadyReplacement {
+	return func(pop Population, betterThan func(a, b Fitness) bool) int {
+		w := rand.Intn(pop.Size())
+		for i := 1; i < k; i++ {
+			if c := rand.Intn(pop.Size()); betterThan(pop.Get(w).Fitness(), pop.Get(c).Fitness()) {
+				w = c
+			}
+		}
+		return w
+	}
+}
+
+// Returns copies of the n best individuals in the population
+func (e *Engine) elite(n int) []Individual {
+	if n <= 0 {
+		return nil
+	}
+	inds := make([]Individual, e.Pop.Size())
+	for i := range inds {
+		inds[i] = e.Pop.Get(i)
+	}
+	sortIndividuals(inds, e.BetterThan)
+	if n > len(inds) {
+		n = len(inds)
+	}
+	elite := make([]Individual, n)
+	for i := range elite {
+		elite[i] = inds[i].Copy()
+	}
+	return elite
+}
+
+// Progress of the evolution, in [0, 1] if MaxGen is known, 0 otherwise
+func (e *Engine) Progress() float32 {
+	if e.MaxGen <= 0 {
+		return 0
+	}
+	return float32(e.Generation) / float32(e.MaxGen)
+}
+
+// Evaluate the population and returns true if evolution should stop
+func (e *Engine) Evaluate() bool {
+	e.Evaluations += e.Pop.Evaluate()
+	if e.MaxGen > 0 && e.Generation >= e.MaxGen {
+		return true
+	}
+	for _, t := range e.Terminate {
+		if t(e) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select n individuals and apply crossover and mutation to them
+func (e *Engine) breed(n int) []PipelineIndividual {
+	pipelineSize := e.PipelineSize
+	if pipelineSize < 1 {
+		pipelineSize = 1
+	}
+	// Variation is done in stages, evaluating the offspring of each one in parallel
+	chCross := make([]<-chan PipelineIndividual, pipelineSize)
+	chSel := GenSelect(e.Pop, n, e.Progress(), nil)
+	for i := range chCross {
+		chCross[i] = GenCrossover(chSel, e.PCross)
+	}
+	sel := Collector(FanIn(chCross...), n)
+	EvaluatePipeline(sel, e.Workers, func(ind *PipelineIndividual, fit Fitness) {
+		ind.CrossoverFitness = fit
+	})
+
+	chMut := make([]<-chan PipelineIndividual, pipelineSize)
+	chCrossed := Emitter(sel)
+	for i := range chMut {
+		chMut[i] = GenMutate(chCrossed, e.PMut)
+	}
+	sel = Collector(FanIn(chMut...), n)
+	EvaluatePipeline(sel, e.Workers, func(ind *PipelineIndividual, fit Fitness) {
+		ind.MutationFitness = fit
+	})
+
+	for _, h := range e.OnOffspring {
+		h(e, sel)
+	}
+	return sel
+}
+
+// Perform one generation of breeding on an evaluated population
+func (e *Engine) Step() {
+	for _, h := range e.OnGeneration {
+		h(e)
+	}
+	if e.SteadyState > 0 {
+		e.steadyStep()
+		return
+	}
+
+	elite := e.elite(e.Elitism)
+	sel := e.breed(e.Pop.Size() - len(elite))
+
+	for i := range elite {
+		e.Pop.Replace(i, elite[i])
+	}
+	offspring := make([]Individual, len(sel))
+	for i := range sel {
+		offspring[i] = sel[i].Ind
+	}
+	replace := e.Replace
+	if replace == nil {
+		replace = GenerationalReplacement
+	}
+	replace(e.Pop, offspring, len(elite))
+
+	e.Generation++
+}
+
+// Breed SteadyState offspring and put them in the population. Offspring are
+// evaluated while breeding, and counted at the next Evaluate
+func (e *Engine) steadyStep() {
+	replace := e.SteadyReplace
+	if replace == nil {
+		replace = ReplaceWorst
+	}
+	sel := e.breed(e.SteadyState)
+	for i := range sel {
+		e.Pop.Replace(replace(e.Pop, e.BetterThan), sel[i].Ind)
+	}
+	e.Generation++
+}
+
+// Run the generational loop until a termination criteria is met.
+// The population is left evaluated
+func (e *Engine) Run() {
+	for !e.Evaluate() {
+		e.Step()
+	}
+}
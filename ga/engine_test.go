@@ -0,0 +1,268 @@
+package ga
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+)
+
+// OneMax individual: fitness is the number of zeros (to be minimized)
+type bits struct {
+	b     []bool
+	fit   Fitness
+	valid bool
+	evals *int64 // Evaluations counter of the population, if any
+}
+
+func (i *bits) Copy() Individual {
+	return &bits{append([]bool{}, i.b...), i.fit, i.valid, i.evals}
+}
+
+func (i *bits) Crossover(p float64, mate Individual) {
+	if rand.Float64() >= p {
+		return
+	}
+	m := mate.(*bits)
+	cut := rand.Intn(len(i.b))
+	for k := cut; k < len(i.b); k++ {
+		i.b[k], m.b[k] = m.b[k], i.b[k]
+	}
+	i.Invalidate()
+	m.Invalidate()
+}
+
+func (i *bits) Evaluate() Fitness {
+	var zeros Fitness
+	for _, v := range i.b {
+		if !v {
+			zeros++
+		}
+	}
+	return zeros
+}
+
+func (i *bits) Invalidate()        { i.valid = false }
+func (i *bits) FitnessValid() bool { return i.valid }
+func (i *bits) Fitness() Fitness {
+	if !i.valid {
+		i.fit, i.valid = i.Evaluate(), true
+		if i.evals != nil {
+			atomic.AddInt64(i.evals, 1)
+		}
+	}
+	return i.fit
+}
+
+func (i *bits) Initialize() {
+	for k := range i.b {
+		i.b[k] = rand.Intn(2) == 0
+	}
+	i.Invalidate()
+}
+
+func (i *bits) Mutate(p float64) {
+	for k := range i.b {
+		if rand.Float64() < p {
+			i.b[k] = !i.b[k]
+			i.Invalidate()
+		}
+	}
+}
+
+func (i *bits) String() string { return fmt.Sprint(i.b) }
+
+type bitsPop struct {
+	pop   []*bits
+	best  *bits
+	evals int64 // Evaluations not yet reported
+}
+
+func (p *bitsPop) Evaluate() int {
+	for _, i := range p.pop {
+		if f := i.Fitness(); p.best == nil || f < p.best.fit {
+			p.best = i
+		}
+	}
+	return int(atomic.SwapInt64(&p.evals, 0))
+}
+
+func (p *bitsPop) Get(i int) Individual          { return p.pop[i] }
+func (p *bitsPop) Replace(i int, ind Individual) { p.pop[i] = ind.(*bits) }
+func (p *bitsPop) Adopt(ind Individual)          { ind.(*bits).evals = &p.evals; ind.Invalidate() }
+func (p *bitsPop) Size() int                     { return len(p.pop) }
+func (p *bitsPop) BestIndividual() Individual    { return p.best }
+func (p *bitsPop) Initialize(n int) {
+	p.pop = make([]*bits, n)
+	for i := range p.pop {
+		p.pop[i] = &bits{b: make([]bool, 32), evals: &p.evals}
+		p.pop[i].Initialize()
+	}
+}
+
+func (p *bitsPop) Select(n int, gen float32) ([]Individual, error) {
+	sel := make([]Individual, n)
+	for i := range sel {
+		a, b := p.pop[rand.Intn(len(p.pop))], p.pop[rand.Intn(len(p.pop))]
+		if b.Fitness() < a.Fitness() {
+			a = b
+		}
+		sel[i] = a.Copy()
+	}
+	return sel, nil
+}
+
+func lower(a, b Fitness) bool { return a < b }
+
+func TestEngine(t *testing.T) {
+	rand.Seed(1)
+	pop := new(bitsPop)
+	pop.Initialize(50)
+
+	e := NewEngine(pop, lower)
+	e.MaxGen = 100
+	e.Elitism = 2
+	e.Terminate = append(e.Terminate, TargetFitness(0))
+
+	hooks := 0
+	e.OnGeneration = append(e.OnGeneration, func(e *Engine) { hooks++ })
+	e.Run()
+
+	if hooks != e.Generation {
+		t.Error("Hook called", hooks, "times, expected", e.Generation)
+	}
+	if pop.Size() != 50 {
+		t.Error("Population size changed to", pop.Size())
+	}
+	if f := pop.BestIndividual().Fitness(); f != 0 && e.Generation != e.MaxGen {
+		t.Error("Engine stopped at generation", e.Generation, "with best fitness", f)
+	}
+}
+
+func TestEvaluations(t *testing.T) {
+	rand.Seed(1)
+	// Every offspring is mutated and evaluated while breeding
+	pop := new(bitsPop)
+	pop.Initialize(50)
+	e := NewEngine(pop, lower)
+	e.PCross, e.PMut = 0, 1
+	e.MaxGen = 20
+	e.Run()
+	if e.Evaluations != 50+50*20 {
+		t.Error("Expected", 50+50*20, "evaluations, got", e.Evaluations)
+	}
+
+	// Offspring evaluated in parallel are counted in the same way
+	pop.Initialize(50)
+	e = NewEngine(pop, lower)
+	e.PCross, e.PMut = 0.5, 1
+	e.MaxGen = 20
+	e.Workers = 4
+	e.OnOffspring = append(e.OnOffspring, func(e *Engine, off []PipelineIndividual) {
+		for _, o := range off {
+			if !o.Ind.FitnessValid() || o.MutationFitness != o.Ind.Fitness() {
+				t.Error("Offspring fitness was not stored before the hooks")
+				return
+			}
+		}
+	})
+	e.Run()
+	if e.Evaluations < 50+50*20 {
+		t.Error("Expected at least", 50+50*20, "evaluations, got", e.Evaluations)
+	}
+
+	// Unmodified copies are not evaluated again
+	pop.Initialize(50)
+	e = NewEngine(pop, lower)
+	e.PCross, e.PMut = 0, 0
+	e.MaxGen = 20
+	e.Run()
+	if e.Evaluations != 50 {
+		t.Error("Expected 50 evaluations, got", e.Evaluations)
+	}
+
+	pop.Initialize(50)
+	e = NewEngine(pop, lower)
+	e.PCross, e.PMut = 0, 1
+	e.Terminate = append(e.Terminate, MaxEvaluations(500))
+	e.Run()
+	if e.Generation != 9 || e.Evaluations != 500 {
+		t.Error("Expected to stop at generation 9 after 500 evaluations, got", e.Generation, e.Evaluations)
+	}
+}
+
+func TestElitism(t *testing.T) {
+	pop := new(bitsPop)
+	pop.Initialize(20)
+
+	e := NewEngine(pop, lower)
+	e.Elitism = 1
+	e.PMut = 1 // Destroy everything but the elite
+	e.Evaluate()
+	best := pop.BestIndividual().Fitness()
+	e.Step()
+	e.Evaluate()
+	if pop.pop[0].Fitness() != best {
+		t.Error("Elite fitness", pop.pop[0].Fitness(), "expected", best)
+	}
+}
+
+func TestTruncationReplacement(t *testing.T) {
+	pop := new(bitsPop)
+	pop.Initialize(10)
+	pop.Evaluate()
+
+	// Offspring are all perfect
+	offspring := make([]Individual, 5)
+	for i := range offspring {
+		b := &bits{b: make([]bool, 32)}
+		for k := range b.b {
+			b.b[k] = true
+		}
+		offspring[i] = b
+	}
+	MakeTruncationReplacement(lower)(pop, offspring, 0)
+	for i := 0; i < 5; i++ {
+		if pop.pop[i].Fitness() != 0 {
+			t.Error("Expected offspring in position", i)
+		}
+	}
+}
+
+func TestSteadyState(t *testing.T) {
+	for _, replace := range []SteadyReplacement{nil, MakeReplaceTournamentLoser(3)} {
+		pop := new(bitsPop)
+		pop.Initialize(30)
+
+		e := NewEngine(pop, lower)
+		e.SteadyState = 2
+		e.MaxGen = 150
+		e.PCross, e.PMut = 0, 1 // Every offspring is evaluated
+		e.SteadyReplace = replace
+		e.Evaluate()
+		first := pop.BestIndividual().Fitness()
+		e.Run()
+
+		if pop.Size() != 30 {
+			t.Error("Population size changed to", pop.Size())
+		}
+		if e.Evaluations != 30+2*150 {
+			t.Error("Expected", 30+2*150, "evaluations, got", e.Evaluations)
+		}
+		if replace == nil && pop.BestIndividual().Fitness() > first {
+			t.Error("Replacing the worst lost the best individual")
+		}
+	}
+
+	// Unmodified copies of the parents are not evaluated again
+	pop := new(bitsPop)
+	pop.Initialize(30)
+	e := NewEngine(pop, lower)
+	e.SteadyState = 2
+	e.MaxGen = 150
+	e.PCross, e.PMut = 0, 0
+	e.Run()
+	if e.Evaluations != 30 {
+		t.Error("Expected 30 evaluations without variation, got", e.Evaluations)
+	}
+}
@@ -1,6 +1,9 @@
 package ga
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 // A Fitness is a real measure
 type Fitness float64
@@ -20,8 +23,10 @@ type Individual interface {
 }
 
 type Population interface {
-	Evaluate() int                                   // Evaluate fitnesses, return evaluated ones
+	Evaluate() int                                   // Evaluate fitnesses, return evaluations since last call, breeding included
 	Get(i int) Individual                            // Get a pointer to ith individual
+	Replace(i int, ind Individual)                   // Replace the ith individual
+	Adopt(ind Individual)                            // Bind an individual of another population to this one, invalidating its fitness
 	Initialize(n int)                                // Build N individuals
 	Size() int                                       // Get the number of individuals
 	Select(n int, gen float32) ([]Individual, error) // Select N individuals at given percentage of evolution process
@@ -48,3 +53,10 @@ func (p *MinProblem) BetterThan(a, b Fitness) bool {
 func (p *MaxProblem) BetterThan(a, b Fitness) bool {
 	return b > a
 }
+
+// Sort individuals from best to worst, keeping the order of equivalent ones
+func sortIndividuals(inds []Individual, betterThan func(a, b Fitness) bool) {
+	sort.SliceStable(inds, func(i, j int) bool {
+		return betterThan(inds[i].Fitness(), inds[j].Fitness())
+	})
+}
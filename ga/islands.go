@@ -0,0 +1,190 @@
+package ga
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// A Topology returns the islands receiving migrants from island i, out of n
+type Topology func(i, n int) []int
+
+// A MigrantSelection returns the indices of n individuals of pop to be sent
+// to another island. The population is evaluated
+type MigrantSelection func(pop Population, n int, betterThan func(a, b Fitness) bool) []int
+
+// Every island sends migrants to the next one
+func RingTopology(i, n int) []int {
+	return []int{(i + 1) % n}
+}
+
+// Every island sends migrants to all the other ones
+func FullTopology(i, n int) []int {
+	dest := make([]int, 0, n-1)
+	for j := 0; j < n; j++ {
+		if j != i {
+			dest = append(dest, j)
+		}
+	}
+	return dest
+}
+
+// Every island sends migrants to k other islands, picked at random at every
+// migration (all the others if they are less than k)
+func MakeRandomTopology(k int) Topology {
+	return func(i, n int) []int {
+		dest := FullTopology(i, n)
+		rand.Shuffle(len(dest), func(a, b int) { dest[a], dest[b] = dest[b], dest[a] })
+		if k < len(dest) {
+			dest = dest[:k]
+		}
+		return dest
+	}
+}
+
+// Indices of the population, from best to worst
+func rankedIndices(pop Population, betterThan func(a, b Fitness) bool) []int {
+	idx := make([]int, pop.Size())
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		return betterThan(pop.Get(idx[a]).Fitness(), pop.Get(idx[b]).Fitness())
+	})
+	return idx
+}
+
+// The n best individuals migrate
+func BestMigrants(pop Population, n int, betterThan func(a, b Fitness) bool) []int {
+	idx := rankedIndices(pop, betterThan)
+	if n < len(idx) {
+		idx = idx[:n]
+	}
+	return idx
+}
+
+// n different individuals, picked at random, migrate
+func RandomMigrants(pop Population, n int, betterThan func(a, b Fitness) bool) []int {
+	idx := rand.Perm(pop.Size())
+	if n < len(idx) {
+		idx = idx[:n]
+	}
+	return idx
+}
+
+// Archipelago evolves some islands, each one a population with its own
+// engine, concurrently. Periodically, some individuals of each island are
+// copied to the neighbour islands, replacing their worst individuals.
+// Islands make a step only when all of them are done with the previous one
+type Archipelago struct {
+	Islands  []*Engine
+	Topology Topology         // Where the migrants are sent
+	Interval int              // Generations between two migrations, 0 to disable them
+	Migrants int              // Number of migrants sent to each neighbour
+	Emigrate MigrantSelection // Which individuals migrate
+
+	OnGeneration []func(a *Archipelago) // Called after the evaluation of all the islands, before migration
+	OnMigration  []func(a *Archipelago) // Called after migration
+
+	Generation int // Current generation
+}
+
+// Build an archipelago where every 10 generations the best individual of
+// each island migrates to the next island in a ring
+func NewArchipelago(islands ...*Engine) *Archipelago {
+	return &Archipelago{
+		Islands:  islands,
+		Topology: RingTopology,
+		Interval: 10,
+		Migrants: 1,
+		Emigrate: BestMigrants,
+	}
+}
+
+// Call f on every island concurrently, waiting for all of them
+func (a *Archipelago) each(f func(i int, e *Engine)) {
+	var wg sync.WaitGroup
+	wg.Add(len(a.Islands))
+	for i, e := range a.Islands {
+		go func(i int, e *Engine) {
+			defer wg.Done()
+			f(i, e)
+		}(i, e)
+	}
+	wg.Wait()
+}
+
+// Total number of fitness evaluations on all the islands
+func (a *Archipelago) Evaluations() int {
+	n := 0
+	for _, e := range a.Islands {
+		n += e.Evaluations
+	}
+	return n
+}
+
+// The best individual among all the islands
+func (a *Archipelago) BestIndividual() Individual {
+	var best Individual
+	for _, e := range a.Islands {
+		b := e.Pop.BestIndividual()
+		if best == nil || e.BetterThan(b.Fitness(), best.Fitness()) {
+			best = b
+		}
+	}
+	return best
+}
+
+// Send migrants between islands, replacing the worst individuals of the
+// receiving ones, which adopt them. Migrants are picked before any island
+// receives its own, so they do not migrate twice. Populations must be evaluated
+func (a *Archipelago) Migrate() {
+	n := len(a.Islands)
+	incoming := make([][]Individual, n)
+	for i, e := range a.Islands {
+		for _, j := range a.Topology(i, n) {
+			for _, k := range a.Emigrate(e.Pop, a.Migrants, e.BetterThan) {
+				incoming[j] = append(incoming[j], e.Pop.Get(k).Copy())
+			}
+		}
+	}
+	for j, e := range a.Islands {
+		worst := rankedIndices(e.Pop, e.BetterThan)
+		for m, ind := range incoming[j] {
+			if m >= len(worst) {
+				break
+			}
+			e.Pop.Adopt(ind)
+			e.Pop.Replace(worst[len(worst)-1-m], ind)
+		}
+	}
+}
+
+// Run the islands until a termination criteria of any of them is met.
+// Populations are left evaluated
+func (a *Archipelago) Run() {
+	for {
+		stop := make([]bool, len(a.Islands))
+		a.each(func(i int, e *Engine) {
+			stop[i] = e.Evaluate()
+		})
+		for _, s := range stop {
+			if s {
+				return
+			}
+		}
+		for _, h := range a.OnGeneration {
+			h(a)
+		}
+		if a.Interval > 0 && a.Generation > 0 && a.Generation%a.Interval == 0 {
+			a.Migrate()
+			for _, h := range a.OnMigration {
+				h(a)
+			}
+		}
+		a.each(func(i int, e *Engine) {
+			e.Step()
+		})
+		a.Generation++
+	}
+}
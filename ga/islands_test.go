@@ -0,0 +1,84 @@
+package ga
+
+import (
+	"testing"
+)
+
+func TestTopologies(t *testing.T) {
+	if d := RingTopology(3, 4); len(d) != 1 || d[0] != 0 {
+		t.Error("Ring topology sends from 3 to", d)
+	}
+	if d := FullTopology(1, 4); len(d) != 3 || d[0] != 0 || d[1] != 2 || d[2] != 3 {
+		t.Error("Full topology sends from 1 to", d)
+	}
+	for i := 0; i < 20; i++ {
+		d := MakeRandomTopology(2)(1, 5)
+		if len(d) != 2 || d[0] == d[1] || d[0] == 1 || d[1] == 1 {
+			t.Fatal("Random topology sends from 1 to", d)
+		}
+	}
+}
+
+func TestMigration(t *testing.T) {
+	pops := []*bitsPop{new(bitsPop), new(bitsPop)}
+	var islands []*Engine
+	for _, p := range pops {
+		p.Initialize(10)
+		islands = append(islands, NewEngine(p, lower))
+	}
+	// Island 0 has a perfect individual
+	for k := range pops[0].pop[4].b {
+		pops[0].pop[4].b[k] = true
+	}
+	pops[0].pop[4].Invalidate()
+	for _, p := range pops {
+		p.Evaluate()
+	}
+	worst := rankedIndices(pops[1], lower)[9]
+
+	a := NewArchipelago(islands...)
+	a.Topology = FullTopology
+	a.Migrate()
+	// The migrant is bound to the receiving island, and evaluated again
+	if m := pops[1].pop[worst]; m.valid || m.evals != &pops[1].evals {
+		t.Error("Migrant was not adopted by the receiving island")
+	}
+	if pops[1].pop[worst].Fitness() != 0 {
+		t.Error("Best individual did not replace the worst of the other island")
+	}
+	if pops[1].pop[worst] == pops[0].pop[4] {
+		t.Error("Migrant was not copied")
+	}
+}
+
+func TestArchipelago(t *testing.T) {
+	var islands []*Engine
+	for i := 0; i < 3; i++ {
+		p := new(bitsPop)
+		p.Initialize(20)
+		e := NewEngine(p, lower)
+		e.MaxGen = 12
+		islands = append(islands, e)
+	}
+	a := NewArchipelago(islands...)
+	a.Interval = 5
+	gens, migrations := 0, 0
+	a.OnGeneration = append(a.OnGeneration, func(a *Archipelago) { gens++ })
+	a.OnMigration = append(a.OnMigration, func(a *Archipelago) { migrations++ })
+	a.Run()
+
+	if gens != 12 || a.Generation != 12 {
+		t.Error("Archipelago ran", gens, "generations, expected 12")
+	}
+	if migrations != 2 {
+		t.Error("Expected 2 migrations, got", migrations)
+	}
+	for i, e := range islands {
+		if e.Generation != 12 {
+			t.Error("Island", i, "is at generation", e.Generation)
+		}
+	}
+	if a.BestIndividual() == nil || a.Evaluations() < 60 {
+		t.Error("Wrong best individual or evaluations")
+	}
+}
@@ -0,0 +1,183 @@
+package ga
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Objectives is a vector fitness, every objective is minimized
+type Objectives []float64
+
+// Individuals that can be used in multi-objective optimization
+type MultiObjective interface {
+	Objectives() Objectives // Return the objectives, evaluating them if necessary
+}
+
+// True if a is not worse than b in every objective, and better in at least one
+func Dominates(a, b Objectives) bool {
+	better := false
+	for i := range a {
+		if a[i] > b[i] {
+			return false
+		}
+		if a[i] < b[i] {
+			better = true
+		}
+	}
+	return better
+}
+
+// Fast non-dominated sorting: returns the indices of objs grouped by front,
+// starting from the Pareto front
+func NonDominatedSort(objs []Objectives) [][]int {
+	dominated := make([][]int, len(objs)) // Who is dominated by i
+	domCount := make([]int, len(objs))    // By how many i is dominated
+	var fronts [][]int
+	var front []int
+	for i := range objs {
+		for j := range objs {
+			if Dominates(objs[i], objs[j]) {
+				dominated[i] = append(dominated[i], j)
+			} else if Dominates(objs[j], objs[i]) {
+				domCount[i]++
+			}
+		}
+		if domCount[i] == 0 {
+			front = append(front, i)
+		}
+	}
+	for len(front) > 0 {
+		fronts = append(fronts, front)
+		var next []int
+		for _, i := range front {
+			for _, j := range dominated[i] {
+				domCount[j]--
+				if domCount[j] == 0 {
+					next = append(next, j)
+				}
+			}
+		}
+		sort.Ints(next)
+		front = next
+	}
+	return fronts
+}
+
+// Crowding distance of the individuals in front (indices of objs).
+// Boundary individuals have infinite distance
+func CrowdingDistance(objs []Objectives, front []int) []float64 {
+	dist := make([]float64, len(front))
+	if len(front) == 0 {
+		return dist
+	}
+	order := make([]int, len(front)) // Positions in front
+	for m := range objs[front[0]] {
+		for k := range order {
+			order[k] = k
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return objs[front[order[a]]][m] < objs[front[order[b]]][m]
+		})
+		lo, hi := objs[front[order[0]]][m], objs[front[order[len(order)-1]]][m]
+		dist[order[0]] = math.Inf(1)
+		dist[order[len(order)-1]] = math.Inf(1)
+		if hi == lo {
+			continue
+		}
+		for k := 1; k < len(order)-1; k++ {
+			prev, next := objs[front[order[k-1]]][m], objs[front[order[k+1]]][m]
+			dist[order[k]] += (next - prev) / (hi - lo)
+		}
+	}
+	return dist
+}
+
+// Pareto rank (0 is the best front) and crowding distance of every objective vector
+func RankAndCrowding(objs []Objectives) (rank []int, crowd []float64) {
+	rank = make([]int, len(objs))
+	crowd = make([]float64, len(objs))
+	for r, front := range NonDominatedSort(objs) {
+		dist := CrowdingDistance(objs, front)
+		for k, i := range front {
+			rank[i] = r
+			crowd[i] = dist[k]
+		}
+	}
+	return
+}
+
+func objectivesOf(inds []Individual) []Objectives {
+	objs := make([]Objectives, len(inds))
+	for i := range inds {
+		objs[i] = inds[i].(MultiObjective).Objectives()
+	}
+	return objs
+}
+
+// The non-dominated individuals, that must implement MultiObjective
+func ParetoFront(inds []Individual) []Individual {
+	if len(inds) == 0 {
+		return nil
+	}
+	front := NonDominatedSort(objectivesOf(inds))[0]
+	res := make([]Individual, len(front))
+	for k, i := range front {
+		res[k] = inds[i]
+	}
+	return res
+}
+
+// NSGA-II selection: n binary tournaments using the crowded comparison,
+// i.e. lower rank wins and, within the same rank, larger crowding distance.
+// Returns copies of the winners, individuals must implement MultiObjective
+func SelectNSGA2(inds []Individual, n int) []Individual {
+	rank, crowd := RankAndCrowding(objectivesOf(inds))
+	sel := make([]Individual, n)
+	for k := range sel {
+		a, b := rand.Intn(len(inds)), rand.Intn(len(inds))
+		if rank[b] < rank[a] || (rank[b] == rank[a] && crowd[b] > crowd[a]) {
+			a = b
+		}
+		sel[k] = inds[a].Copy()
+	}
+	return sel
+}
+
+// NSGA-II survival: parents and offspring are ranked in fronts and the
+// population is filled front by front, breaking the last front by crowding
+// distance. Elite individuals are already in place and are not replaced.
+// Individuals must implement MultiObjective
+func NSGA2Replacement(pop Population, offspring []Individual, elite int) {
+	pool := make([]Individual, 0, pop.Size()-elite+len(offspring))
+	for i := elite; i < pop.Size(); i++ {
+		pool = append(pool, pop.Get(i))
+	}
+	pool = append(pool, offspring...)
+
+	objs := objectivesOf(pool)
+	next := elite
+	for _, front := range NonDominatedSort(objs) {
+		if next >= pop.Size() {
+			break
+		}
+		if next+len(front) > pop.Size() {
+			// Prefer less crowded individuals
+			dist := CrowdingDistance(objs, front)
+			order := make([]int, len(front))
+			for k := range order {
+				order[k] = k
+			}
+			sort.SliceStable(order, func(a, b int) bool { return dist[order[a]] > dist[order[b]] })
+			sorted := make([]int, len(front))
+			for k := range order {
+				sorted[k] = front[order[k]]
+			}
+			front = sorted[:pop.Size()-next]
+		}
+		for _, i := range front {
+			pop.Replace(next, pool[i])
+			next++
+		}
+	}
+}
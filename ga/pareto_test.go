@@ -0,0 +1,99 @@
+package ga
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNonDominatedSort(t *testing.T) {
+	objs := []Objectives{
+		{1, 5}, // 0: front 0
+		{2, 2}, // 1: front 0
+		{5, 1}, // 2: front 0
+		{3, 3}, // 3: front 1
+		{6, 6}, // 4: front 2
+		{2, 4}, // 5: front 1
+	}
+	fronts := NonDominatedSort(objs)
+	exp := [][]int{{0, 1, 2}, {3, 5}, {4}}
+	if !reflect.DeepEqual(fronts, exp) {
+		t.Error("Expected fronts", exp, "got", fronts)
+	}
+	if Dominates(objs[0], objs[0]) {
+		t.Error("A vector cannot dominate itself")
+	}
+
+	dist := CrowdingDistance(objs, fronts[0])
+	if !math.IsInf(dist[0], 1) || !math.IsInf(dist[2], 1) {
+		t.Error("Boundary individuals should have infinite distance", dist)
+	}
+	// (5-1)/(5-1) + (5-1)/(5-1)
+	if dist[1] != 2 {
+		t.Error("Expected crowding distance 2, got", dist[1])
+	}
+}
+
+// Bit strings minimizing both the number of zeros and the number of ones
+// in the first half: the Pareto front has every possible trade-off
+type biBits struct{ bits }
+
+func (i *biBits) Copy() Individual { return &biBits{*i.bits.Copy().(*bits)} }
+func (i *biBits) Objectives() Objectives {
+	ones := 0
+	for _, v := range i.b[:len(i.b)/2] {
+		if v {
+			ones++
+		}
+	}
+	return Objectives{float64(i.Fitness()), float64(ones)}
+}
+
+type biPop struct{ pop []Individual }
+
+func (p *biPop) Evaluate() int                                   { return 0 }
+func (p *biPop) Get(i int) Individual                            { return p.pop[i] }
+func (p *biPop) Replace(i int, ind Individual)                   { p.pop[i] = ind }
+func (p *biPop) Adopt(ind Individual)                            {}
+func (p *biPop) Initialize(n int)                                {}
+func (p *biPop) Size() int                                       { return len(p.pop) }
+func (p *biPop) Select(n int, gen float32) ([]Individual, error) { return SelectNSGA2(p.pop, n), nil }
+func (p *biPop) BestIndividual() Individual                      { return p.pop[0] }
+
+func newBiPop(n int) *biPop {
+	p := &biPop{make([]Individual, n)}
+	for i := range p.pop {
+		b := &biBits{bits{b: make([]bool, 16)}}
+		b.Initialize()
+		p.pop[i] = b
+	}
+	return p
+}
+
+func TestNSGA2Replacement(t *testing.T) {
+	pop := newBiPop(20)
+	offspring := newBiPop(20).pop
+	all := append(append([]Individual{}, pop.pop...), offspring...)
+	front := ParetoFront(all)
+
+	NSGA2Replacement(pop, offspring, 0)
+	if pop.Size() != 20 {
+		t.Fatal("Population size changed to", pop.Size())
+	}
+	// The Pareto front of parents and offspring must survive (if it fits)
+	if len(front) <= 20 {
+		for _, f := range front {
+			found := false
+			for _, ind := range pop.pop {
+				found = found || ind == f
+			}
+			if !found {
+				t.Error("Non-dominated individual", f, "did not survive")
+			}
+		}
+	}
+	sel := SelectNSGA2(pop.pop, 10)
+	if len(sel) != 10 {
+		t.Error("Expected 10 selected individuals, got", len(sel))
+	}
+}
@@ -55,6 +55,8 @@ func GenSelect(pop Population, selectionSize int, generation float32, elite Indi
 	return out
 }
 
+// Crossover stage of a pipeline. CrossoverFitness is not computed here, so that
+// offspring can be evaluated in parallel with EvaluatePipeline
 func GenCrossover(in <-chan PipelineIndividual, pCross float64) <-chan PipelineIndividual {
 	out := make(chan PipelineIndividual)
 	go func() {
@@ -67,8 +69,6 @@ func GenCrossover(in <-chan PipelineIndividual, pCross float64) <-chan PipelineI
 				if ok {
 					// We got two items! Crossover
 					i1.Ind.Crossover(pCross, i2.Ind)
-					i1.CrossoverFitness = i1.Ind.Fitness()
-					i2.CrossoverFitness = i2.Ind.Fitness()
 					out <- i1
 					out <- i2
 				} else {
@@ -84,12 +84,12 @@ func GenCrossover(in <-chan PipelineIndividual, pCross float64) <-chan PipelineI
 	return out
 }
 
+// Mutation stage of a pipeline. MutationFitness is not computed here
 func GenMutate(in <-chan PipelineIndividual, pMut float64) <-chan PipelineIndividual {
 	out := make(chan PipelineIndividual)
 	go func() {
 		for ind := range in {
 			ind.Ind.Mutate(pMut)
-			ind.MutationFitness = ind.Ind.Fitness()
 			out <- ind
 		}
 		close(out)
@@ -124,6 +124,45 @@ func FanIn(in ...<-chan PipelineIndividual) <-chan PipelineIndividual {
 	return out
 }
 
+// Send the individuals of a slice over a channel
+func Emitter(inds []PipelineIndividual) <-chan PipelineIndividual {
+	out := make(chan PipelineIndividual, len(inds))
+	for i := range inds {
+		out <- inds[i]
+	}
+	close(out)
+	return out
+}
+
+// Compute the fitness of the individuals using the given number of goroutines,
+// then call store on each of them with the result
+func EvaluatePipeline(inds []PipelineIndividual, workers int, store func(ind *PipelineIndividual, fit Fitness)) {
+	if workers < 1 {
+		workers = 1
+	}
+	idx := make(chan int, len(inds))
+	for i := range inds {
+		if !inds[i].Ind.FitnessValid() {
+			idx <- i
+		}
+	}
+	close(idx)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range idx {
+				inds[i].Ind.Fitness()
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	for i := range inds {
+		store(&inds[i], inds[i].Ind.Fitness())
+	}
+}
+
 // Collects all the individuals from a channel and return a slice. Size is a hint for performances
 func Collector(in <-chan PipelineIndividual, size int) []PipelineIndividual {
 	pop := make([]PipelineIndividual, 0, size)
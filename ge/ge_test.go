@@ -0,0 +1,148 @@
+package ge
+
+import (
+	"github.com/akiross/gogp/ga"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/node"
+	"math/rand"
+	"testing"
+)
+
+type prim struct {
+	name  string
+	arity int
+}
+
+func (p *prim) IsFunctional() bool                 { return p.arity > 0 }
+func (p *prim) IsEphemeral() bool                  { return false }
+func (p *prim) Arity() int                         { return p.arity }
+func (p *prim) Name() string                       { return p.name }
+func (p *prim) Run(a ...gp.Primitive) gp.Primitive { return p }
+
+func testRegistry() *gp.Registry {
+	reg := gp.NewRegistry()
+	reg.Register(&prim{"Sum", 2}, &prim{"Neg", 1}, &prim{"X", -1}, &prim{"Y", -1})
+	return reg
+}
+
+const testGrammar = `
+# Sums of variables, negated only at the root
+<start> ::= Neg(<sum>) | <sum>
+<sum>   ::= Sum(<sum>, <sum>)
+          | <var>
+<var>   ::= X | Y
+`
+
+func TestParseGrammar(t *testing.T) {
+	g, err := ParseGrammar(testGrammar, testRegistry().Lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Start != "start" || len(g.Rules) != 3 || len(g.Rules["sum"]) != 2 {
+		t.Error("Wrong grammar", g)
+	}
+	bad := []string{
+		"<a> ::= Sum(<a>)",     // Wrong arity
+		"<a> ::= X(<a>)",       // Terminal with arguments
+		"<a> ::= Mul(<a>)",     // Unknown primitive
+		"<a> ::= Neg(<b>)",     // Undefined non-terminal
+		"<a> ::= Neg(X)",       // Argument is not a non-terminal
+		"| X",                  // Continuation without rule
+		"a ::= X",              // Invalid rule name
+		"<a> ::= X\n<a> ::= Y", // Duplicate rule
+	}
+	for _, src := range bad {
+		if _, err := ParseGrammar(src, testRegistry().Lookup); err == nil {
+			t.Error("Expected an error parsing", src)
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	g, _ := ParseGrammar(testGrammar, testRegistry().Lookup)
+	m := NewMapper(g, 1)
+
+	// Neg, Sum, var, X, var, Y
+	tr, info, err := m.Map([]int{0, 0, 1, 0, 1, 1}, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := tr.String(); s != "F{Neg}(F{Sum}(T{X}, T{Y}))" {
+		t.Error("Wrong tree", s)
+	}
+	if info.Codons != 6 || info.Wraps != 0 {
+		t.Error("Wrong mapping info", info)
+	}
+
+	// Wrapping: the last codon is read again from the start
+	tr, info, err = m.Map([]int{1, 0, 1, 0, 1}, -1)
+	if err != nil || info.Wraps != 1 || info.Codons != 6 || tr.String() != "F{Sum}(T{X}, T{Y})" {
+		t.Error("Wrong wrapped mapping", tr, info, err)
+	}
+	// Always choosing Sum never terminates
+	if _, _, err = m.Map([]int{0}, -1); err != ErrTooManyWraps {
+		t.Error("Expected too many wraps, got", err)
+	}
+	if _, _, err = m.Map([]int{1, 0, 0, 0, 0, 0, 0}, 1); err != ErrTooDeep {
+		t.Error("Expected a tree too deep, got", err)
+	}
+
+	gen, err := m.MakeGenFunc(20, 256, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		tr := gen(4)
+		if d := node.Depth(tr); d > 4 {
+			t.Fatal("Generated tree too deep", d, tr)
+		}
+	}
+
+	// When the limit cannot be met, the shallowest tree is generated
+	g, _ = ParseGrammar("<a> ::= Neg(<b>) | Sum(<a>, <b>)\n<b> ::= <a> | X", testRegistry().Lookup)
+	gen, err = NewMapper(g, 1).MakeGenFunc(20, 256, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr := gen(0); tr.String() != "F{Neg}(T{X})" {
+		t.Error("Expected the shallowest tree, got", tr)
+	}
+	g, _ = ParseGrammar("<a> ::= Neg(<a>)", testRegistry().Lookup)
+	if _, err = NewMapper(g, 1).MakeGenFunc(20, 256, nil); err == nil {
+		t.Error("Expected an error for a grammar without finite trees")
+	}
+}
+
+func TestIndividual(t *testing.T) {
+	rand.Seed(1)
+	g, _ := ParseGrammar(testGrammar, testRegistry().Lookup)
+	invalid := 0
+	set := &Settings{
+		Mapper:    NewMapper(g, 1),
+		GenomeLen: 10,
+		MaxCodon:  256,
+		MaxDepth:  5,
+		FitFunc:   func(t *node.Node) ga.Fitness { return ga.Fitness(node.Size(t)) },
+		Invalid:   1000,
+		Report: func(info MapInfo, err error) {
+			if err != nil {
+				invalid++
+			}
+		},
+	}
+	for i := 0; i < 100; i++ {
+		ind := NewIndividual(set)
+		ind.Initialize()
+		f := ind.Fitness()
+		if (ind.Tree == nil) != (f == set.Invalid) {
+			t.Error("Fitness", f, "does not match tree", ind.Tree)
+		}
+		c := ind.Copy().(*Individual)
+		c.Mutate(1)
+		ind.Crossover(1, c)
+		if ind.FitnessValid() || c.FitnessValid() || len(ind.Genome) != 10 {
+			t.Error("Variation should invalidate the fitness")
+		}
+	}
+	t.Log("Invalid individuals:", invalid)
+}
@@ -0,0 +1,137 @@
+/*
+Package ge implements grammatical evolution: genomes are sequences of integer
+codons, that choose the productions of a BNF grammar to derive a node.Node tree.
+
+Grammars describe how primitives can be combined, e.g.
+
+	<expr> ::= Sum(<expr>, <expr>) | Neg(<expr>) | <var>
+	<var>  ::= X | Y
+
+Each production is either a single non-terminal, a terminal primitive or a
+functional primitive whose arguments are non-terminals. Primitives are
+resolved by name, so they must be registered (e.g. in gp.DefaultRegistry).
+The first rule defines the start symbol. Lines starting with | continue the
+previous rule, lines starting with # are comments.
+*/
+package ge
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/node"
+	"strings"
+)
+
+// A production of a rule. When Prim is nil, the production is a single
+// non-terminal, stored in Args
+type Production struct {
+	Prim gp.Primitive
+	Args []string // Non-terminals of the arguments of Prim
+}
+
+type Grammar struct {
+	Start string
+	Rules map[string][]Production
+}
+
+// Parse a non-terminal like <expr>, returning its name
+func parseNonTerminal(s string) (string, bool) {
+	if len(s) > 2 && s[0] == '<' && s[len(s)-1] == '>' {
+		return s[1 : len(s)-1], true
+	}
+	return "", false
+}
+
+func parseProduction(s string, lookup node.Lookup) (Production, error) {
+	s = strings.TrimSpace(s)
+	if nt, ok := parseNonTerminal(s); ok {
+		return Production{nil, []string{nt}}, nil
+	}
+	name, args := s, []string{}
+	if i := strings.Index(s, "("); i >= 0 {
+		if !strings.HasSuffix(s, ")") {
+			return Production{}, fmt.Errorf("missing ) in %q", s)
+		}
+		name = strings.TrimSpace(s[:i])
+		for _, a := range strings.Split(s[i+1:len(s)-1], ",") {
+			nt, ok := parseNonTerminal(strings.TrimSpace(a))
+			if !ok {
+				return Production{}, fmt.Errorf("argument %q of %v is not a non-terminal", a, name)
+			}
+			args = append(args, nt)
+		}
+	}
+	if name == "" {
+		return Production{}, fmt.Errorf("empty production")
+	}
+	p, err := lookup(name)
+	if err != nil {
+		return Production{}, err
+	}
+	if p.IsFunctional() && p.Arity() != len(args) {
+		return Production{}, fmt.Errorf("%v has arity %v, but %v arguments are given", name, p.Arity(), len(args))
+	}
+	if !p.IsFunctional() && len(args) > 0 {
+		return Production{}, fmt.Errorf("terminal %v cannot have arguments", name)
+	}
+	return Production{p, args}, nil
+}
+
+// Parse a grammar in BNF, resolving primitives with lookup
+func ParseGrammar(src string, lookup node.Lookup) (*Grammar, error) {
+	g := &Grammar{Rules: make(map[string][]Production)}
+	var current string
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var alts string
+		if strings.HasPrefix(line, "|") {
+			if current == "" {
+				return nil, fmt.Errorf("line %v: continuation without a rule", lineNo)
+			}
+			alts = line[1:]
+		} else {
+			parts := strings.SplitN(line, "::=", 2)
+			nt, ok := parseNonTerminal(strings.TrimSpace(parts[0]))
+			if len(parts) != 2 || !ok {
+				return nil, fmt.Errorf("line %v: expected <name> ::= productions", lineNo)
+			}
+			if _, dup := g.Rules[nt]; dup {
+				return nil, fmt.Errorf("line %v: rule <%v> defined twice", lineNo, nt)
+			}
+			if g.Start == "" {
+				g.Start = nt
+			}
+			current, alts = nt, parts[1]
+			g.Rules[nt] = nil
+		}
+		for _, a := range strings.Split(alts, "|") {
+			p, err := parseProduction(a, lookup)
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %v", lineNo, err)
+			}
+			g.Rules[current] = append(g.Rules[current], p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if g.Start == "" {
+		return nil, fmt.Errorf("empty grammar")
+	}
+	// Every non-terminal must be defined
+	for nt, prods := range g.Rules {
+		for _, p := range prods {
+			for _, a := range p.Args {
+				if _, ok := g.Rules[a]; !ok {
+					return nil, fmt.Errorf("rule <%v> uses undefined non-terminal <%v>", nt, a)
+				}
+			}
+		}
+	}
+	return g, nil
+}
@@ -0,0 +1,126 @@
+package ge
+
+import (
+	"fmt"
+	"github.com/akiross/gogp/ga"
+	"github.com/akiross/gogp/node"
+	"math/rand"
+)
+
+// Each codon is replaced with a random one with probability pMut.
+// Returns the number of mutated codons
+func MakeCodonMutation(maxCodon int) func(float64, []int) int {
+	return func(pMut float64, genome []int) int {
+		mutCount := 0
+		for i := range genome {
+			if rand.Float64() < pMut {
+				genome[i] = rand.Intn(maxCodon)
+				mutCount++
+			}
+		}
+		return mutCount
+	}
+}
+
+// Swap the tails of two genomes after a random cut point
+func OnePointCrossover(g1, g2 []int) {
+	n := len(g1)
+	if len(g2) < n {
+		n = len(g2)
+	}
+	if n == 0 {
+		return
+	}
+	for i := rand.Intn(n); i < n; i++ {
+		g1[i], g2[i] = g2[i], g1[i]
+	}
+}
+
+type Settings struct {
+	Mapper    *Mapper
+	GenomeLen int
+	MaxCodon  int // Codons are in [0, MaxCodon)
+	MaxDepth  int // Max depth of derived trees, negative for no limit
+
+	FitFunc func(*node.Node) ga.Fitness // Fitness of valid trees
+	Invalid ga.Fitness                  // Fitness assigned to invalid individuals
+	Report  func(MapInfo, error)        // Called after each mapping, if not nil
+
+	ga.MinProblem
+}
+
+// Individual adapts a genome to ga.Individual. The tree is derived when the
+// fitness is evaluated
+type Individual struct {
+	Genome     []int
+	Tree       *node.Node // Nil if the individual is invalid or not yet mapped
+	fitness    ga.Fitness
+	fitIsValid bool
+	set        *Settings
+}
+
+func NewIndividual(set *Settings) *Individual {
+	return &Individual{set: set}
+}
+
+func (ind *Individual) String() string {
+	if ind.Tree == nil {
+		return fmt.Sprint("Invalid", ind.Genome)
+	}
+	return fmt.Sprint(ind.Tree)
+}
+
+func (ind *Individual) Copy() ga.Individual {
+	return &Individual{append([]int{}, ind.Genome...), ind.Tree, ind.fitness, ind.fitIsValid, ind.set}
+}
+
+func (ind *Individual) Crossover(pCross float64, mate ga.Individual) {
+	if rand.Float64() >= pCross {
+		return
+	}
+	m := mate.(*Individual)
+	OnePointCrossover(ind.Genome, m.Genome)
+	ind.Invalidate()
+	m.Invalidate()
+}
+
+// Map the genome and evaluate the tree, without caching the result
+func (ind *Individual) Evaluate() ga.Fitness {
+	t, info, err := ind.set.Mapper.Map(ind.Genome, ind.set.MaxDepth)
+	if ind.set.Report != nil {
+		ind.set.Report(info, err)
+	}
+	if err != nil {
+		ind.Tree = nil
+		return ind.set.Invalid
+	}
+	ind.Tree = t
+	return ind.set.FitFunc(t)
+}
+
+func (ind *Individual) Invalidate() {
+	ind.fitIsValid = false
+}
+
+func (ind *Individual) FitnessValid() bool {
+	return ind.fitIsValid
+}
+
+func (ind *Individual) Fitness() ga.Fitness {
+	if !ind.fitIsValid {
+		ind.fitness, ind.fitIsValid = ind.Evaluate(), true
+	}
+	return ind.fitness
+}
+
+func (ind *Individual) Initialize() {
+	ind.Genome = RandomGenome(ind.set.GenomeLen, ind.set.MaxCodon)
+	ind.Tree = nil
+	ind.Invalidate()
+}
+
+func (ind *Individual) Mutate(pMut float64) {
+	if MakeCodonMutation(ind.set.MaxCodon)(pMut, ind.Genome) > 0 {
+		ind.Invalidate()
+	}
+}
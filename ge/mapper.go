@@ -0,0 +1,206 @@
+package ge
+
+import (
+	"errors"
+	"fmt"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/node"
+	"math/rand"
+	"sort"
+)
+
+var (
+	ErrTooManyWraps = errors.New("genome wrapped too many times")
+	ErrTooDeep      = errors.New("derivation tree too deep")
+)
+
+// Information on the mapping of a genome
+type MapInfo struct {
+	Codons int // Number of codons read, including wraps
+	Wraps  int // Times the reading restarted from the first codon
+}
+
+// Maps genomes to trees using a grammar
+type Mapper struct {
+	Grammar  *Grammar
+	MaxWraps int // Wraps allowed before the individual is invalid
+}
+
+func NewMapper(g *Grammar, maxWraps int) *Mapper {
+	return &Mapper{g, maxWraps}
+}
+
+// Derivation state of a single genome
+type derivation struct {
+	m        *Mapper
+	genome   []int
+	pos      int
+	maxDepth int
+	info     MapInfo
+}
+
+// Read the next codon to pick one of n productions
+func (d *derivation) choose(n int) (int, error) {
+	if n == 1 {
+		return 0, nil // No codon is used when there is no choice
+	}
+	if len(d.genome) == 0 {
+		return 0, ErrTooManyWraps
+	}
+	if d.pos == len(d.genome) {
+		d.pos = 0
+		d.info.Wraps++
+		if d.info.Wraps > d.m.MaxWraps {
+			return 0, ErrTooManyWraps
+		}
+	}
+	c := d.genome[d.pos]
+	d.pos++
+	d.info.Codons++
+	return (c%n + n) % n, nil
+}
+
+// Derive a tree from non-terminal nt, placing its root at given depth.
+// hops counts the non-terminals expanded without building nodes, to stop
+// on cycles of rules without primitives
+func (d *derivation) expand(nt string, depth, hops int) (*node.Node, error) {
+	if d.maxDepth >= 0 && depth > d.maxDepth {
+		return nil, ErrTooDeep
+	}
+	if hops > len(d.m.Grammar.Rules) {
+		return nil, fmt.Errorf("rule <%v> derives itself without primitives", nt)
+	}
+	prods := d.m.Grammar.Rules[nt]
+	k, err := d.choose(len(prods))
+	if err != nil {
+		return nil, err
+	}
+	p := prods[k]
+	if p.Prim == nil {
+		return d.expand(p.Args[0], depth, hops+1)
+	}
+	if !p.Prim.IsFunctional() {
+		return leaf(p.Prim), nil
+	}
+	children := make([]*node.Node, len(p.Args))
+	for i, a := range p.Args {
+		if children[i], err = d.expand(a, depth+1, 0); err != nil {
+			return nil, err
+		}
+	}
+	return node.New(p.Prim, children...), nil
+}
+
+// Derive the tree encoded by genome, with depth at most maxDepth (negative for
+// no limit). Codons are read from the start, in a depth-first, left-most
+// derivation. An error is returned for invalid individuals, i.e. when the
+// genome wraps more than MaxWraps times or the tree is too deep
+func (m *Mapper) Map(genome []int, maxDepth int) (*node.Node, MapInfo, error) {
+	d := &derivation{m: m, genome: genome, maxDepth: maxDepth}
+	t, err := d.expand(m.Grammar.Start, 0, 0)
+	return t, d.info, err
+}
+
+// Build a random genome with codons in [0, maxCodon)
+func RandomGenome(length, maxCodon int) []int {
+	genome := make([]int, length)
+	for i := range genome {
+		genome[i] = rand.Intn(maxCodon)
+	}
+	return genome
+}
+
+// Height of the trees derived by p, given the heights of the non-terminals.
+// False if some argument derives no finite tree
+func (p Production) height(heights map[string]int) (int, bool) {
+	if p.Prim == nil {
+		h, ok := heights[p.Args[0]]
+		return h, ok
+	}
+	max := -1
+	for _, a := range p.Args {
+		h, ok := heights[a]
+		if !ok {
+			return 0, false
+		}
+		if h > max {
+			max = h
+		}
+	}
+	return max + 1, true
+}
+
+// Find the productions deriving the shallowest trees from each non-terminal.
+// Non-terminals deriving no finite tree are missing
+func (g *Grammar) shallowest() map[string]Production {
+	names := make([]string, 0, len(g.Rules))
+	for nt := range g.Rules {
+		names = append(names, nt)
+	}
+	sort.Strings(names) // Ties are broken in the same way at every run
+	prods := make(map[string]Production)
+	heights := make(map[string]int)
+	for changed := true; changed; {
+		changed = false
+		for _, nt := range names {
+			for _, p := range g.Rules[nt] {
+				h, ok := p.height(heights)
+				if old, found := heights[nt]; ok && (!found || h < old) {
+					prods[nt], heights[nt] = p, h
+					changed = true
+				}
+			}
+		}
+	}
+	return prods
+}
+
+// Build the node of a terminal primitive
+func leaf(p gp.Primitive) *node.Node {
+	if p.IsEphemeral() {
+		return node.New(p.Run())
+	}
+	return node.New(p)
+}
+
+// Derive the shallowest tree from nt using the given productions
+func deriveShallowest(nt string, prods map[string]Production) *node.Node {
+	p := prods[nt]
+	if p.Prim == nil {
+		return deriveShallowest(p.Args[0], prods)
+	}
+	if !p.Prim.IsFunctional() {
+		return leaf(p.Prim)
+	}
+	children := make([]*node.Node, len(p.Args))
+	for i, a := range p.Args {
+		children[i] = deriveShallowest(a, prods)
+	}
+	return node.New(p.Prim, children...)
+}
+
+// Build a tree generator suitable for base.Settings.GenFunc: random genomes
+// are mapped until a valid tree, within the height limit, is found.
+// When no valid tree is found in a number of attempts, the shallowest tree of
+// the grammar is returned, even if it is higher than the limit.
+// If report is not nil, it is called after each mapping.
+// An error is returned if the grammar derives no finite tree
+func (m *Mapper) MakeGenFunc(genomeLen, maxCodon int, report func(MapInfo, error)) (func(maxH int) *node.Node, error) {
+	const maxAttempts = 10000
+	prods := m.Grammar.shallowest()
+	if _, ok := prods[m.Grammar.Start]; !ok {
+		return nil, fmt.Errorf("grammar derives no finite tree from <%v>", m.Grammar.Start)
+	}
+	return func(maxH int) *node.Node {
+		for i := 0; i < maxAttempts; i++ {
+			t, info, err := m.Map(RandomGenome(genomeLen, maxCodon), maxH)
+			if report != nil {
+				report(info, err)
+			}
+			if err == nil {
+				return t
+			}
+		}
+		return deriveShallowest(m.Grammar.Start, prods)
+	}, nil
+}
@@ -0,0 +1,90 @@
+package gp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Parse the name of a primitive generated by an ephemeral, rebuilding it
+type EphemeralParser func(name string) (Primitive, error)
+
+// A Registry resolves primitives by their Name(). Primitives generated by
+// ephemerals are rebuilt by parsers registered for a prefix of their names,
+// so the values of ephemeral constants must be encoded in their names.
+type Registry struct {
+	mu      sync.RWMutex
+	prims   map[string]Primitive
+	parsers map[string]EphemeralParser
+}
+
+// The registry used when no other is specified, e.g. by node.UnmarshalJSON
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{prims: make(map[string]Primitive), parsers: make(map[string]EphemeralParser)}
+}
+
+// Add primitives to the registry. Names must be unique: if a different
+// primitive has the same name of a registered one, nothing is registered and
+// an error is returned. Registering again the same primitive is allowed only
+// if primitives can be compared (e.g. pointers): functions cannot, so they
+// must be registered once
+func (r *Registry) Register(prims ...Primitive) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make(map[string]Primitive)
+	for _, p := range prims {
+		name := p.Name()
+		if q, ok := r.prims[name]; ok && !samePrimitive(p, q) {
+			return fmt.Errorf("primitive %q is already registered", name)
+		}
+		if q, ok := names[name]; ok && !samePrimitive(p, q) {
+			return fmt.Errorf("primitive %q is registered twice", name)
+		}
+		names[name] = p
+	}
+	for name, p := range names {
+		r.prims[name] = p
+	}
+	return nil
+}
+
+// Tell if a and b are the same primitive. Primitives that cannot be compared
+// are considered different
+func samePrimitive(a, b Primitive) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
+
+// Use parse to rebuild primitives whose name starts with prefix
+func (r *Registry) RegisterEphemeral(prefix string, parse EphemeralParser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parsers[prefix] = parse
+}
+
+// Get the primitive with given name. Registered primitives are searched first,
+// then the parser with the longest matching prefix is used
+func (r *Registry) Lookup(name string) (Primitive, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, ok := r.prims[name]; ok {
+		return p, nil
+	}
+	best := ""
+	for prefix := range r.parsers {
+		if strings.HasPrefix(name, prefix) && len(prefix) >= len(best) {
+			best = prefix
+		}
+	}
+	if parse, ok := r.parsers[best]; ok && strings.HasPrefix(name, best) {
+		p, err := parse(name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse ephemeral %q: %v", name, err)
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown primitive %q", name)
+}
@@ -0,0 +1,41 @@
+package gp
+
+// Type of the values produced and consumed by primitives
+type Type string
+
+// Type of primitives that do not declare one: it is compatible with any type,
+// so untyped primitives can be mixed with typed ones
+const Any Type = ""
+
+// TypedPrimitive is an optional interface for primitives that declare the
+// type of their output and of their arguments. Typed tree generators and
+// variation operators (in package node) use it to build only trees where
+// each child returns the type expected by its parent
+type TypedPrimitive interface {
+	Primitive
+	ReturnType() Type // Type of the primitive returned by Run
+	ArgTypes() []Type // Type of each argument, len must equal Arity (nil for terminals)
+}
+
+// Returns true if a value of type t can be used where type u is expected
+func Compatible(t, u Type) bool {
+	return t == Any || u == Any || t == u
+}
+
+// Get the return type of p, or Any if p is not typed
+func ReturnType(p Primitive) Type {
+	if tp, ok := p.(TypedPrimitive); ok {
+		return tp.ReturnType()
+	}
+	return Any
+}
+
+// Get the type of the i-th argument of p, or Any if p is not typed
+func ArgType(p Primitive, i int) Type {
+	if tp, ok := p.(TypedPrimitive); ok {
+		if args := tp.ArgTypes(); i < len(args) {
+			return args[i]
+		}
+	}
+	return Any
+}
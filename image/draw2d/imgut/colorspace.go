@@ -0,0 +1,58 @@
+package imgut
+
+import (
+	"math"
+)
+
+// Convert sRGB values in [0, 255] to CIELAB (D65 white point)
+func RGBToLab(r, g, b float64) (float64, float64, float64) {
+	// Linearize sRGB
+	lin := func(c float64) float64 {
+		c /= 255
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	}
+	rl, gl, bl := lin(r), lin(g), lin(b)
+	// To XYZ, normalized on the white point
+	x := (0.4124564*rl + 0.3575761*gl + 0.1804375*bl) / 0.95047
+	y := (0.2126729*rl + 0.7151522*gl + 0.0721750*bl) / 1.00000
+	z := (0.0193339*rl + 0.1191920*gl + 0.9503041*bl) / 1.08883
+	f := func(t float64) float64 {
+		if t > 216.0/24389.0 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27.0*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// Convert RGB values in [0, 255] to full range YCbCr (ITU-R BT.601), in [0, 255]
+func RGBToYCbCr(r, g, b float64) (float64, float64, float64) {
+	y := 0.299*r + 0.587*g + 0.114*b
+	cb := 128 - 0.168736*r - 0.331264*g + 0.5*b
+	cr := 128 + 0.5*r - 0.418688*g - 0.081312*b
+	return y, cb, cr
+}
+
+// Convert the image to a slice of interleaved values, using conv on every
+// pixel to transform the RGB components
+func toSliceConv(img *Image, conv func(r, g, b float64) (float64, float64, float64)) []float64 {
+	data := ToSliceChans(img, "RGB")
+	for i := 0; i < len(data); i += 3 {
+		data[i], data[i+1], data[i+2] = conv(data[i], data[i+1], data[i+2])
+	}
+	return data
+}
+
+// Convert the image to a slice of interleaved L*, a*, b* values
+func ToSliceLab(img *Image) []float64 {
+	return toSliceConv(img, RGBToLab)
+}
+
+// Convert the image to a slice of interleaved Y, Cb, Cr values
+func ToSliceYCbCr(img *Image) []float64 {
+	return toSliceConv(img, RGBToYCbCr)
+}
@@ -9,12 +9,11 @@ import (
 	"image/png"
 	"math"
 	"os"
+	"strings"
 	"sync"
 	"unsafe"
 )
 
-// BUG(akiross) Basically, only RGBA is supported (and has been shallowly tested)
-
 // #cgo CFLAGS: -O2 -Wall -fopenmp
 // #cgo LDFLAGS: -lgomp
 // #include "linearShading.h"
@@ -23,6 +22,9 @@ import "C"
 
 type ColorSpace int
 
+// Color spaces are backed by different surfaces: A8 by image.Alpha, G8 by
+// image.Gray, RGB and RGBA by image.RGBA (RGB images are kept opaque).
+// Single channel surfaces have the same value in every channel when read
 const (
 	MODE_A8 ColorSpace = iota
 	MODE_G8
@@ -37,24 +39,81 @@ type Image struct {
 	ColorSpace ColorSpace                // What colors are we considering
 }
 
-// Create an image of the given size
+// Create an image of the given size, with a surface suitable for mode
 func Create(w, h int, mode ColorSpace) *Image {
 	var img Image
-	img.Surf = image.NewRGBA(image.Rect(0, 0, w, h))
-	img.Ctx = draw2dimg.NewGraphicContext(img.Surf)
+	rect := image.Rect(0, 0, w, h)
+	switch mode {
+	case MODE_A8:
+		surf := image.NewAlpha(rect)
+		img.Surf = surf
+		img.Ctx = draw2dimg.NewGraphicContextWithPainter(surf, &alphaPainter{img: surf})
+	case MODE_G8:
+		surf := image.NewGray(rect)
+		img.Surf = surf
+		img.Ctx = draw2dimg.NewGraphicContextWithPainter(surf, &grayPainter{img: surf})
+	default:
+		img.Surf = image.NewRGBA(rect)
+		img.Ctx = draw2dimg.NewGraphicContext(img.Surf)
+	}
+	img.ColorSpace = mode
 	img.W, img.H = w, h
+	img.Clear()
 	return &img
 }
 
+// Pick the color space that preserves the format of a decoded image
+func colorSpaceOf(img image.Image) ColorSpace {
+	switch img.(type) {
+	case *image.Alpha, *image.Alpha16:
+		return MODE_A8
+	case *image.Gray, *image.Gray16:
+		return MODE_G8
+	}
+	if o, ok := img.(interface {
+		Opaque() bool
+	}); ok && o.Opaque() {
+		return MODE_RGB
+	}
+	return MODE_RGBA
+}
+
+// Read the pixel as RGBA. Single channel surfaces have the same value in all
+// the channels (alpha included, for A8)
+func (img *Image) at(x, y int) color.RGBA {
+	switch surf := img.Surf.(type) {
+	case *image.RGBA:
+		i := surf.PixOffset(x, y)
+		return color.RGBA{surf.Pix[i], surf.Pix[i+1], surf.Pix[i+2], surf.Pix[i+3]}
+	case *image.Gray:
+		v := surf.Pix[surf.PixOffset(x, y)]
+		return color.RGBA{v, v, v, 0xff}
+	case *image.Alpha:
+		v := surf.Pix[surf.PixOffset(x, y)]
+		return color.RGBA{v, v, v, v}
+	}
+	return color.RGBAModel.Convert(img.Surf.At(x, y)).(color.RGBA)
+}
+
+// Write the pixel. Single channel surfaces take the value of the red channel
+func (img *Image) set(x, y int, c color.RGBA) {
+	switch surf := img.Surf.(type) {
+	case *image.Gray:
+		surf.Pix[surf.PixOffset(x, y)] = c.R
+	case *image.Alpha:
+		surf.Pix[surf.PixOffset(x, y)] = c.R
+	default:
+		img.Surf.Set(x, y, c)
+	}
+}
+
 func getDataPointer(img *Image) (*C.uchar, C.int) {
 	rgbaImage := img.Surf.(*image.RGBA)
 	return (*C.uchar)(unsafe.Pointer(&rgbaImage.Pix[0])), C.int(rgbaImage.Stride)
 }
 
-// Load an image from a PNG file
+// Load an image from a PNG file, preserving its color space
 func Load(path string) (*Image, error) {
-	var img Image
-
 	file, err := os.Open(path)
 	if err != nil {
 		fmt.Println("ERROR: Cannot open file", path)
@@ -75,22 +134,26 @@ func Load(path string) (*Image, error) {
 
 	// Copy to surface
 	b := pngImage.Bounds()
-	img.Surf = image.NewRGBA(image.Rect(0, 0, b.Max.X-b.Min.X, b.Max.Y-b.Min.Y))
-	draw.Draw(img.Surf, img.Surf.Bounds(), pngImage, pngImage.Bounds().Min, draw.Src)
+	img := Create(b.Dx(), b.Dy(), colorSpaceOf(pngImage))
+	draw.Draw(img.Surf, img.Surf.Bounds(), pngImage, b.Min, draw.Src)
 
-	img.Ctx = draw2dimg.NewGraphicContext(img.Surf)
-	br := img.Surf.Bounds()
-	img.W, img.H = br.Max.X-br.Min.X, br.Max.Y-br.Min.Y
-
-	return &img, nil
+	return img, nil
 }
 
+// Set the drawing color: a single value is a gray level (the alpha, for A8
+// images), otherwise RGB. The color is converted to the image color space
 func (i *Image) SetColor(col ...float64) {
-	if len(col) < 3 {
-		fmt.Println("ERROR: SetColor works only in RGB mode right now, you need to pass 3 parameters")
-		panic("ERROR: SetColor requires 3 parameters")
+	switch {
+	case len(col) == 1 && i.ColorSpace == MODE_A8:
+		i.Ctx.SetFillColor(color.Alpha{uint8(col[0] * 0xff)})
+	case len(col) == 1:
+		i.Ctx.SetFillColor(color.Gray{uint8(col[0] * 0xff)})
+	case len(col) >= 3:
+		i.Ctx.SetFillColor(color.RGBA{uint8(col[0] * 0xff), uint8(col[1] * 0xff), uint8(col[2] * 0xff), 0xff})
+	default:
+		fmt.Println("ERROR: SetColor requires 1 (gray) or 3 (RGB) parameters")
+		panic("ERROR: SetColor requires 1 or 3 parameters")
 	}
-	i.Ctx.SetFillColor(color.RGBA{uint8(col[0] * 0xff), uint8(col[1] * 0xff), uint8(col[2] * 0xff), 0xff})
 }
 
 // Stroke the current path with the given color
@@ -139,6 +202,10 @@ func (i *Image) FillSurface(col ...float64) {
 }
 
 func (img *Image) LinearShade(x1, y1, x2, y2, sx, sy, ex, ey, startCol, endCol float64) {
+	if _, ok := img.Surf.(*image.RGBA); !ok {
+		img.linearShade(int(x1), int(y1), int(x2), int(y2), sx, sy, ex, ey, startCol, endCol)
+		return
+	}
 	// Get a pointer to image data
 	pixPtr, stride := getDataPointer(img)
 	//rgbaImage := img.Surf.(*image.RGBA)
@@ -147,6 +214,27 @@ func (img *Image) LinearShade(x1, y1, x2, y2, sx, sy, ex, ey, startCol, endCol f
 	C.linearShading(pixPtr, stride, C.int(x1), C.int(y1), C.int(x2), C.int(y2), C.double(startCol), C.double(endCol), C.double(sx), C.double(sy), C.double(ex), C.double(ey))
 }
 
+// Same as linearShading in linearShading.h, for single channel surfaces
+func (img *Image) linearShade(x1, y1, x2, y2 int, sx, sy, ex, ey, startCol, endCol float64) {
+	width, height := float64(x2-x1), float64(y2-y1)
+	xd, yd := ex-sx, ey-sy
+	c1, c2 := xd*sx+yd*sy, xd*ex+yd*ey
+	cd := c2 - c1
+	for y := y1; y < y2; y++ {
+		for x := x1; x < x2; x++ {
+			c := xd*float64(x)/width + yd*float64(y)/height
+			col := (startCol*(c2-c) + endCol*(c-c1)) / cd
+			if c <= c1 {
+				col = startCol
+			} else if c >= c2 {
+				col = endCol
+			}
+			v := uint8(col * 0xff)
+			img.set(x, y, color.RGBA{v, v, v, 0xff})
+		}
+	}
+}
+
 func (img *Image) CircularShade(cx, cy, inRad, outRad, startCol, endCol float64) {
 	//	pixPtr, stride := getDataPointer(img)
 	//	C.circularShading(pixPtr, stride, C.int(cx), C.int(cy))
@@ -159,9 +247,13 @@ func (i *Image) Blit(x, y int, target *Image) {
 	draw.Draw(target.Surf, destRect, i.Surf, image.ZP, draw.Src)
 }
 
-// Clear the image filling with black
+// Clear the image filling with black (transparent, if there is alpha)
 func (i *Image) Clear() {
-	draw.Draw(i.Surf, i.Surf.Bounds(), image.Transparent, image.ZP, draw.Src)
+	if i.ColorSpace == MODE_RGB {
+		draw.Draw(i.Surf, i.Surf.Bounds(), image.Black, image.ZP, draw.Src)
+	} else {
+		draw.Draw(i.Surf, i.Surf.Bounds(), image.Transparent, image.ZP, draw.Src)
+	}
 }
 
 type PixelFunc func(x, y float64) float64
@@ -170,7 +262,9 @@ type PixelFunc func(x, y float64) float64
 // You can specify one function per channel, or one function for all the channels
 // The values will be normalized
 // BUG(akiross) this should return an error
-// Coordinates are relative to image boundary sizes
+// Coordinates are relative to image boundary sizes: x is the column, divided by
+// the width, and y the row, divided by the height. Rows used to be iterated as
+// columns, so images that were not square were drawn transposed and clipped
 func (img *Image) FillMath(minX, minY, maxX, maxY float64, chanFuncs ...PixelFunc) {
 	b := img.Surf.Bounds()
 	bx, by := int(float64(b.Min.X)*minX), int(float64(b.Min.Y)*minY)
@@ -182,16 +276,16 @@ func (img *Image) FillMath(minX, minY, maxX, maxY float64, chanFuncs ...PixelFun
 	// Number of goroutines to wait for
 	wg.Add(ey - by)
 
-	// Start goroutines
-	for i := by; i < ey; i++ {
-		go func(i int) {
-			for j := bx; j < ex; j++ {
-				val := uint8(chanFuncs[0](float64(i)/float64(ey-by), float64(j)/float64(ex-bx)) * 0xff)
+	// Start goroutines, one per row
+	for y := by; y < ey; y++ {
+		go func(y int) {
+			for x := bx; x < ex; x++ {
+				val := uint8(chanFuncs[0](float64(x)/float64(ex-bx), float64(y)/float64(ey-by)) * 0xff)
 				col := color.RGBA{val, val, val, 0xff}
-				img.Surf.Set(i, j, col)
+				img.set(x, y, col)
 			}
 			wg.Done()
-		}(i)
+		}(y)
 	}
 
 	// Wait for them to finish
@@ -251,12 +345,10 @@ func PixelDistance(i1, i2 *Image) (rmse float64) {
 	var count int
 	rmse = 0
 	b := im1.Bounds()
-	cm1, cm2 := im1.ColorModel(), im2.ColorModel()
 	for i := b.Min.Y; i < b.Max.Y; i++ {
 		for j := b.Min.X; j < b.Max.X; j++ {
 			// In the python version this is not normalized, but I think it should be for precision issues
-			px1, px2 := im1.At(j, i), im2.At(j, i)
-			gr1, gr2 := cm1.Convert(px1).(color.RGBA), cm2.Convert(px2).(color.RGBA)
+			gr1, gr2 := i1.at(j, i), i2.at(j, i)
 			diff := (float64(gr1.R) - float64(gr2.R)) // / 255.0
 			rmse += diff * diff
 			count++
@@ -285,9 +377,11 @@ func FromSlice(img *Image, data []float64) {
 	FromSliceChans(img, "RGBA", 255.0, data)
 }
 
+// Convert an image to a []float64, with the channels specified in chans
+// (e.g. RGBA, R, BGR). On single channel images (A8, G8) every channel
+// has the same value
 func ToSliceChans(img *Image, chans string) []float64 {
 	surf := img.Surf
-	cm := surf.ColorModel()
 	b := surf.Bounds()
 	minX, maxX := b.Min.X, b.Max.X
 	minY, maxY := b.Min.Y, b.Max.Y
@@ -296,8 +390,7 @@ func ToSliceChans(img *Image, chans string) []float64 {
 	k := 0
 	for i := b.Min.Y; i < b.Max.Y; i++ {
 		for j := b.Min.X; j < b.Max.X; j++ {
-			pix := surf.At(j, i)
-			col := cm.Convert(pix).(color.RGBA)
+			col := img.at(j, i)
 			for c := 0; c < nc; c++ {
 				switch chans[c] {
 				default:
@@ -318,9 +411,10 @@ func ToSliceChans(img *Image, chans string) []float64 {
 
 // Convert a []float64 to an image, reading data with the format specified in
 // chans (e.g. RGBA, BGRA, BRG, RRR). If a component is not specified in the
+// chans, fill is used. G8 images take the first of R, G, B in chans,
+// A8 images take A or, if missing, the first of R, G, B
 func FromSliceChans(img *Image, chans string, fill float64, data []float64) {
 	surf := img.Surf
-	cm := surf.ColorModel()
 	b := surf.Bounds()
 	minX, maxX := b.Min.X, b.Max.X
 	minY, maxY := b.Min.Y, b.Max.Y
@@ -330,6 +424,29 @@ func FromSliceChans(img *Image, chans string, fill float64, data []float64) {
 	const mk = 0xff
 	dfill := clamp8(fill) & mk
 
+	// Single channel surfaces read one of the channels
+	src := -1
+	if img.ColorSpace == MODE_A8 {
+		src = strings.IndexAny(chans, "aA")
+	}
+	if src < 0 {
+		src = strings.IndexAny(chans, "rRgGbB")
+	}
+	switch img.Surf.(type) {
+	case *image.Gray, *image.Alpha:
+		for i := minY; i < maxY; i++ {
+			for j := minX; j < maxX; j++ {
+				v := dfill
+				if src >= 0 {
+					v = clamp8(data[k+src]) & mk
+				}
+				k += nc
+				img.set(j, i, color.RGBA{v, v, v, v})
+			}
+		}
+		return
+	}
+
 	for i := minY; i < maxY; i++ {
 		for j := minX; j < maxX; j++ {
 			outCol := color.RGBA{dfill, dfill, dfill, dfill}
@@ -348,7 +465,10 @@ func FromSliceChans(img *Image, chans string, fill float64, data []float64) {
 				}
 			}
 			k += nc
-			img.Surf.Set(j, i, cm.Convert(outCol))
+			if img.ColorSpace == MODE_RGB {
+				outCol.A = mk
+			}
+			img.set(j, i, outCol)
 		}
 	}
 }
@@ -365,12 +485,10 @@ func PixelRMSE(i1, i2 *Image) (rmse float64) {
 	var count int
 	rmse = 0
 	b := im1.Bounds()
-	cm1, cm2 := im1.ColorModel(), im2.ColorModel()
 	for i := b.Min.Y; i < b.Max.Y; i++ {
 		for j := b.Min.X; j < b.Max.X; j++ {
 			// In the python version this is not normalized, but I think it should be for precision issues
-			px1, px2 := im1.At(j, i), im2.At(j, i)
-			gr1, gr2 := cm1.Convert(px1).(color.RGBA), cm2.Convert(px2).(color.RGBA)
+			gr1, gr2 := i1.at(j, i), i2.at(j, i)
 			diff := (float64(gr1.R) - float64(gr2.R)) // / 255.0
 			rmse += diff * diff
 			count++
@@ -389,6 +507,9 @@ func Average(images []*Image) *Image {
 	}
 
 	width, height := images[0].W, images[0].H
+	if _, ok := images[0].Surf.(*image.RGBA); !ok {
+		return average(images)
+	}
 
 	// Build storage for accumulated image
 	accumulator := make([]float32, width*height*4)
@@ -412,6 +533,26 @@ func Average(images []*Image) *Image {
 	return avgImg
 }
 
+// Average for single channel surfaces
+func average(images []*Image) *Image {
+	avgImg := Create(images[0].W, images[0].H, images[0].ColorSpace)
+	acc := make([]float64, avgImg.W*avgImg.H)
+	for i := range images {
+		if images[i].W != avgImg.W || images[i].H != avgImg.H {
+			continue
+		}
+		data := ToSliceChans(images[i], "R")
+		for k := range acc {
+			acc[k] += data[k]
+		}
+	}
+	for k := range acc {
+		acc[k] /= float64(len(images))
+	}
+	FromSliceChans(avgImg, "R", 0xff, acc)
+	return avgImg
+}
+
 type ConvolutionMatrix struct {
 	Size int
 	Data []float64
@@ -445,7 +586,7 @@ func (cm *ConvolutionMatrix) Multiply(img *Image, x, y int) color.RGBA {
 			x2 = x
 		}
 
-		rgba8 := color.RGBAModel.Convert(img.Surf.At(x2, y2)).(color.RGBA)
+		rgba8 := img.at(x2, y2)
 
 		nr := float64(rgba8.R) * cm.Data[k]
 		ng := float64(rgba8.G) * cm.Data[k]
@@ -461,7 +602,7 @@ func (cm *ConvolutionMatrix) Multiply(img *Image, x, y int) color.RGBA {
 		clamp8(racc) & mk,
 		clamp8(gacc) & mk,
 		clamp8(bacc) & mk,
-		img.Surf.At(x, y).(color.RGBA).A, // XXX original alpha value
+		img.at(x, y).A, // XXX original alpha value
 	}
 	return outCol
 }
@@ -473,7 +614,7 @@ func ApplyConvolution(cm *ConvolutionMatrix, img *Image) *Image {
 	bs := img.Surf.Bounds()
 	for i := bs.Min.Y; i < bs.Max.Y; i++ {
 		for j := bs.Min.X; j < bs.Max.X; j++ {
-			dest.Surf.Set(j, i, cm.Multiply(img, j, i))
+			dest.set(j, i, cm.Multiply(img, j, i))
 		}
 	}
 
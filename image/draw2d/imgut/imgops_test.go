@@ -2,7 +2,9 @@ package imgut
 
 import (
 	"github.com/gonum/floats"
+	"image"
 	"image/color"
+	"math"
 	"math/rand"
 	"testing"
 	"time"
@@ -152,6 +154,107 @@ func TestSliceConversionChans(t *testing.T) {
 	img.WritePNG("cicciac.png")
 }
 
+func TestSSIM(t *testing.T) {
+	img := Create(32, 32, MODE_RGBA)
+	img.FillMathBounds(func(x, y float64) float64 { return x * y })
+	if v := SSIM(img, img, "RGB"); math.Abs(v-1) > 1e-9 {
+		t.Error("SSIM of an image with itself should be 1, got", v)
+	}
+	msssim := MakeMSSSIM(img, "R")
+	if v := msssim(img); math.Abs(v-1) > 1e-9 {
+		t.Error("MS-SSIM of an image with itself should be 1, got", v)
+	}
+
+	// A noisy copy is similar, a flat image is not
+	noisy := Create(32, 32, MODE_RGBA)
+	data := ToSliceChans(img, "RGBA")
+	for i := range data {
+		if i%4 != 3 {
+			data[i] += rand.Float64()*40 - 20
+		}
+	}
+	FromSliceChans(noisy, "RGBA", 255, data)
+	flat := Create(32, 32, MODE_RGBA)
+	flat.FillSurface(0.5, 0.5, 0.5)
+	vn, vf := SSIM(img, noisy, "R"), SSIM(img, flat, "R")
+	if !(vn < 1 && vf < vn) {
+		t.Error("Expected SSIM of flat image", vf, "< noisy", vn, "< 1")
+	}
+	if vn, vf := msssim(noisy), msssim(flat); !(vn < 1 && vf < vn) {
+		t.Error("Expected MS-SSIM of flat image", vf, "< noisy", vn, "< 1")
+	}
+}
+
+func TestColorSpaces(t *testing.T) {
+	near := func(a, b float64) bool { return math.Abs(a-b) < 0.01 }
+	if l, a, b := RGBToLab(255, 255, 255); !near(l, 100) || !near(a, 0) || !near(b, 0) {
+		t.Error("White should be (100, 0, 0) in Lab, got", l, a, b)
+	}
+	if l, a, b := RGBToLab(0, 0, 0); !near(l, 0) || !near(a, 0) || !near(b, 0) {
+		t.Error("Black should be (0, 0, 0) in Lab, got", l, a, b)
+	}
+	if y, cb, cr := RGBToYCbCr(255, 255, 255); !near(y, 255) || !near(cb, 128) || !near(cr, 128) {
+		t.Error("White should be (255, 128, 128) in YCbCr, got", y, cb, cr)
+	}
+}
+
+func TestColorSpaceSurfaces(t *testing.T) {
+	dir := t.TempDir()
+	for _, mode := range []ColorSpace{MODE_A8, MODE_G8, MODE_RGB, MODE_RGBA} {
+		img := Create(8, 8, mode)
+		if img.ColorSpace != mode {
+			t.Error("Image created with mode", mode, "has color space", img.ColorSpace)
+		}
+		if mode == MODE_A8 {
+			img.FillRect(0, 0, 4, 8, 0.5)
+		} else {
+			img.FillRect(0, 0, 4, 8, 0.5, 0.5, 0.5)
+		}
+		data := ToSliceChans(img, "R")
+		if data[0] != 127 || data[7] != 0 {
+			t.Error("Wrong values drawn in mode", mode, data[:8])
+		}
+		// Slices are written back on the right channel
+		FromSliceChans(img, "R", 255, data)
+		if back := ToSliceChans(img, "R"); !floats.Equal(back, data) {
+			t.Error("Slice conversion changed values in mode", mode)
+		}
+		if mode == MODE_A8 {
+			continue
+		}
+		if mode == MODE_RGBA {
+			img.set(7, 7, color.RGBA{})
+		}
+		path := dir + "/img.png"
+		img.WritePNG(path)
+		img2, err := Load(path)
+		if err != nil {
+			t.Fatal("Cannot load image:", err)
+		}
+		if img2.ColorSpace != mode {
+			t.Error("Image saved in mode", mode, "loaded as", img2.ColorSpace)
+		}
+		if PixelRMSE(img, img2) != 0 {
+			t.Error("Loaded image differs in mode", mode)
+		}
+	}
+	if _, ok := Create(8, 8, MODE_G8).Surf.(*image.Gray); !ok {
+		t.Error("G8 images should use a gray surface")
+	}
+}
+
+func TestFillMathRect(t *testing.T) {
+	img := Create(6, 3, MODE_G8)
+	img.FillMathBounds(func(x, y float64) float64 { return x })
+	data := ToSliceChans(img, "R")
+	for i := range data {
+		x := i % img.W
+		if want := float64(uint8(float64(x) / 6 * 0xff)); data[i] != want {
+			t.Fatal("Pixel", x, i/img.W, "is", data[i], "expected", want)
+		}
+	}
+}
+
 // Bah, not working
 /*
 func TestSobel(t *testing.T) {
@@ -0,0 +1,76 @@
+package imgut
+
+import (
+	"github.com/golang/freetype/raster"
+	"image"
+	"image/color"
+)
+
+// Paints spans over a grayscale image, blending with the current color
+type grayPainter struct {
+	img  *image.Gray
+	y, a uint32 // Gray level (premultiplied) and alpha of the color, 16 bits
+}
+
+func (p *grayPainter) SetColor(c color.Color) {
+	_, _, _, p.a = c.RGBA()
+	p.y = uint32(color.Gray16Model.Convert(c).(color.Gray16).Y)
+}
+
+func (p *grayPainter) Paint(ss []raster.Span, done bool) {
+	b := p.img.Bounds()
+	for _, s := range ss {
+		if s.Y < b.Min.Y || s.Y >= b.Max.Y {
+			continue
+		}
+		x0, x1 := intMax(s.X0, b.Min.X), intMin(s.X1, b.Max.X)
+		ma := s.Alpha * p.a / 0xffff // Alpha of the span with this color
+		for x := x0; x < x1; x++ {
+			i := p.img.PixOffset(x, s.Y)
+			dst := uint32(p.img.Pix[i]) * 0x101
+			v := (dst*(0xffff-ma) + p.y*s.Alpha) / 0xffff
+			p.img.Pix[i] = uint8(v >> 8)
+		}
+	}
+}
+
+// Paints spans over an alpha image, compositing the alpha of the color
+type alphaPainter struct {
+	img *image.Alpha
+	a   uint32 // Alpha of the color, 16 bits
+}
+
+func (p *alphaPainter) SetColor(c color.Color) {
+	_, _, _, p.a = c.RGBA()
+}
+
+func (p *alphaPainter) Paint(ss []raster.Span, done bool) {
+	b := p.img.Bounds()
+	for _, s := range ss {
+		if s.Y < b.Min.Y || s.Y >= b.Max.Y {
+			continue
+		}
+		x0, x1 := intMax(s.X0, b.Min.X), intMin(s.X1, b.Max.X)
+		ma := s.Alpha * p.a / 0xffff
+		for x := x0; x < x1; x++ {
+			i := p.img.PixOffset(x, s.Y)
+			dst := uint32(p.img.Pix[i]) * 0x101
+			v := ma + dst*(0xffff-ma)/0xffff
+			p.img.Pix[i] = uint8(v >> 8)
+		}
+	}
+}
+
+func intMax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func intMin(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,228 @@
+package imgut
+
+import (
+	"math"
+)
+
+// Parameters of SSIM, as in Wang et al. "Image quality assessment: from
+// error visibility to structural similarity" (2004)
+const (
+	ssimWindow = 11  // Size of the gaussian window
+	ssimSigma  = 1.5 // Standard deviation of the gaussian window
+	ssimK1     = 0.01
+	ssimK2     = 0.03
+	ssimRange  = 255.0 // Dynamic range of pixel values
+)
+
+// Weights of the scales used by MS-SSIM, from finest to coarsest
+var msssimWeights = []float64{0.0448, 0.2856, 0.3001, 0.2363, 0.1333}
+
+// A single channel of an image
+type plane struct {
+	w, h int
+	data []float64
+}
+
+// Split interleaved data (as returned by ToSliceChans) in nc planes
+func splitPlanes(data []float64, w, h, nc int) []plane {
+	planes := make([]plane, nc)
+	for c := range planes {
+		planes[c] = plane{w, h, make([]float64, w*h)}
+		for i := range planes[c].data {
+			planes[c].data[i] = data[i*nc+c]
+		}
+	}
+	return planes
+}
+
+// Pixel-wise product of two planes of the same size
+func (p plane) mul(q plane) plane {
+	r := plane{p.w, p.h, make([]float64, len(p.data))}
+	for i := range r.data {
+		r.data[i] = p.data[i] * q.data[i]
+	}
+	return r
+}
+
+// Halve the size of the plane, averaging 2x2 blocks
+func (p plane) downsample() plane {
+	r := plane{p.w / 2, p.h / 2, make([]float64, (p.w/2)*(p.h/2))}
+	for y := 0; y < r.h; y++ {
+		for x := 0; x < r.w; x++ {
+			i := 2*y*p.w + 2*x
+			r.data[y*r.w+x] = (p.data[i] + p.data[i+1] + p.data[i+p.w] + p.data[i+p.w+1]) / 4
+		}
+	}
+	return r
+}
+
+// Normalized 1D gaussian kernel
+func gaussianKernel(size int, sigma float64) []float64 {
+	k := make([]float64, size)
+	sum := 0.0
+	for i := range k {
+		d := float64(i - size/2)
+		k[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += k[i]
+	}
+	for i := range k {
+		k[i] /= sum
+	}
+	return k
+}
+
+// Separable convolution with kernel k, only where the window fits the plane
+func (p plane) filter(k []float64) plane {
+	n := len(k)
+	// Horizontal pass
+	tmp := plane{p.w - n + 1, p.h, make([]float64, (p.w-n+1)*p.h)}
+	for y := 0; y < tmp.h; y++ {
+		for x := 0; x < tmp.w; x++ {
+			s := 0.0
+			for i := range k {
+				s += k[i] * p.data[y*p.w+x+i]
+			}
+			tmp.data[y*tmp.w+x] = s
+		}
+	}
+	// Vertical pass
+	r := plane{tmp.w, p.h - n + 1, make([]float64, tmp.w*(p.h-n+1))}
+	for y := 0; y < r.h; y++ {
+		for x := 0; x < r.w; x++ {
+			s := 0.0
+			for i := range k {
+				s += k[i] * tmp.data[(y+i)*tmp.w+x]
+			}
+			r.data[y*r.w+x] = s
+		}
+	}
+	return r
+}
+
+// Statistics of one channel of the target at one scale
+type ssimTarget struct {
+	t       plane
+	kernel  []float64
+	mu, sig plane // Local mean and variance
+}
+
+func newSSIMTarget(t plane) *ssimTarget {
+	// Window is shrinked on small images, keeping it odd
+	n := ssimWindow
+	if t.w < n {
+		n = t.w
+	}
+	if t.h < n {
+		n = t.h
+	}
+	if n%2 == 0 {
+		n--
+	}
+	st := &ssimTarget{t: t, kernel: gaussianKernel(n, ssimSigma)}
+	st.mu = t.filter(st.kernel)
+	st.sig = t.mul(t).filter(st.kernel)
+	for i, m := range st.mu.data {
+		st.sig.data[i] -= m * m
+	}
+	return st
+}
+
+// Mean SSIM and mean contrast-structure term of x against the target
+func (st *ssimTarget) compare(x plane) (ssim, cs float64) {
+	c1, c2 := (ssimK1*ssimRange)*(ssimK1*ssimRange), (ssimK2*ssimRange)*(ssimK2*ssimRange)
+	mux := x.filter(st.kernel)
+	ex2 := x.mul(x).filter(st.kernel)
+	ext := x.mul(st.t).filter(st.kernel)
+	for i, mx := range mux.data {
+		mt := st.mu.data[i]
+		sx2 := ex2.data[i] - mx*mx
+		sxt := ext.data[i] - mx*mt
+		l := (2*mx*mt + c1) / (mx*mx + mt*mt + c1)
+		c := (2*sxt + c2) / (sx2 + st.sig.data[i] + c2)
+		ssim += l * c
+		cs += c
+	}
+	n := float64(len(mux.data))
+	return ssim / n, cs / n
+}
+
+// Target statistics for every channel and scale
+type ssimComparer struct {
+	chans   string
+	scales  [][]*ssimTarget // [scale][channel]
+	weights []float64
+}
+
+func newSSIMComparer(target *Image, chans string, maxScales int) *ssimComparer {
+	sc := &ssimComparer{chans: chans}
+	planes := splitPlanes(ToSliceChans(target, chans), target.W, target.H, len(chans))
+	for s := 0; s < maxScales; s++ {
+		// Use coarser scales only while they are large enough
+		if s > 0 && (planes[0].w < 2*ssimWindow/3 || planes[0].h < 2*ssimWindow/3) {
+			break
+		}
+		targets := make([]*ssimTarget, len(planes))
+		for c := range planes {
+			targets[c] = newSSIMTarget(planes[c])
+			planes[c] = planes[c].downsample()
+		}
+		sc.scales = append(sc.scales, targets)
+	}
+	// Normalize weights of the used scales
+	sc.weights = make([]float64, len(sc.scales))
+	sum := 0.0
+	for s := range sc.weights {
+		sc.weights[s] = msssimWeights[s]
+		sum += sc.weights[s]
+	}
+	for s := range sc.weights {
+		sc.weights[s] /= sum
+	}
+	return sc
+}
+
+// SSIM (using only the first scale) or MS-SSIM, averaged on the channels
+func (sc *ssimComparer) compare(img *Image) float64 {
+	planes := splitPlanes(ToSliceChans(img, sc.chans), img.W, img.H, len(sc.chans))
+	total := 0.0
+	for c := range planes {
+		if len(sc.scales) == 1 {
+			ssim, _ := sc.scales[0][c].compare(planes[c])
+			total += ssim
+			continue
+		}
+		ms := 1.0
+		for s := range sc.scales {
+			ssim, cs := sc.scales[s][c].compare(planes[c])
+			v := cs
+			if s == len(sc.scales)-1 {
+				v = ssim
+			}
+			// Negative values would produce NaN
+			ms *= math.Pow(math.Max(v, 0), sc.weights[s])
+			planes[c] = planes[c].downsample()
+		}
+		total += ms
+	}
+	return total / float64(len(planes))
+}
+
+// Build a function computing the windowed SSIM of images against the target,
+// considering the channels in chans (e.g. "R" or "RGB"), averaged.
+// Target statistics are computed once
+func MakeSSIM(target *Image, chans string) func(*Image) float64 {
+	sc := newSSIMComparer(target, chans, 1)
+	return sc.compare
+}
+
+// Like MakeSSIM, but computes the multi-scale SSIM (up to 5 scales,
+// less on small images)
+func MakeMSSSIM(target *Image, chans string) func(*Image) float64 {
+	sc := newSSIMComparer(target, chans, len(msssimWeights))
+	return sc.compare
+}
+
+// Windowed SSIM between two images of the same size
+func SSIM(i1, i2 *Image, chans string) float64 {
+	return MakeSSIM(i1, chans)(i2)
+}
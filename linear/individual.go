@@ -0,0 +1,181 @@
+package linear
+
+import (
+	"fmt"
+	"github.com/akiross/gogp/ga"
+	"math/rand"
+	"sync/atomic"
+)
+
+type Settings struct {
+	Machine        *Machine
+	MinLen, MaxLen int // Bounds on the number of instructions
+
+	// Fitness of a program, usually evaluating the result of Run
+	FitFunc func(*Program) ga.Fitness
+
+	// Operators used in evolution. Defaults are set by NewSettings
+	CrossOver func(float64, *Program, *Program) bool
+	Mutate    func(float64, *Program) bool
+
+	// Evaluations not yet reported by Population.Evaluate, updated atomically
+	evaluations int64
+
+	ga.MinProblem
+}
+
+// Settings using two-point crossover, instruction (micro) mutation and
+// insertion/deletion (macro) mutation
+func NewSettings(m *Machine, minLen, maxLen int, fit func(*Program) ga.Fitness) *Settings {
+	cross := MakeTwoPointCrossover(minLen, maxLen)
+	micro := MakeInstructionMutation()
+	macro := MakeMacroMutation(minLen, maxLen)
+	return &Settings{
+		Machine: m,
+		MinLen:  minLen,
+		MaxLen:  maxLen,
+		FitFunc: fit,
+		CrossOver: func(pCross float64, p1, p2 *Program) bool {
+			if rand.Float64() >= pCross {
+				return false
+			}
+			cross(p1, p2)
+			return true
+		},
+		Mutate: func(pMut float64, p *Program) bool {
+			changed := micro(pMut, p) > 0
+			return macro(pMut, p) || changed
+		},
+	}
+}
+
+// Individual adapts a Program to ga.Individual
+type Individual struct {
+	Prog       *Program
+	fitness    ga.Fitness
+	fitIsValid bool
+	set        *Settings
+}
+
+func NewIndividual(set *Settings) *Individual {
+	return &Individual{set: set}
+}
+
+func (ind *Individual) String() string {
+	return fmt.Sprint(ind.Prog)
+}
+
+func (ind *Individual) Copy() ga.Individual {
+	return &Individual{ind.Prog.Copy(), ind.fitness, ind.fitIsValid, ind.set}
+}
+
+func (ind *Individual) Crossover(pCross float64, mate ga.Individual) {
+	m := mate.(*Individual)
+	if ind.set.CrossOver(pCross, ind.Prog, m.Prog) {
+		ind.Invalidate()
+		m.Invalidate()
+	}
+}
+
+// Evaluate the program, without caching the result
+func (ind *Individual) Evaluate() ga.Fitness {
+	return ind.set.FitFunc(ind.Prog)
+}
+
+func (ind *Individual) Invalidate() {
+	ind.fitIsValid = false
+}
+
+func (ind *Individual) FitnessValid() bool {
+	return ind.fitIsValid
+}
+
+func (ind *Individual) Fitness() ga.Fitness {
+	if !ind.fitIsValid {
+		ind.fitness, ind.fitIsValid = ind.Evaluate(), true
+		atomic.AddInt64(&ind.set.evaluations, 1)
+	}
+	return ind.fitness
+}
+
+func (ind *Individual) Initialize() {
+	ind.Prog = MakeProgram(ind.set.Machine, ind.set.MinLen, ind.set.MaxLen)
+	ind.Invalidate()
+}
+
+func (ind *Individual) Mutate(pMut float64) {
+	if ind.set.Mutate(pMut, ind.Prog) {
+		ind.Invalidate()
+	}
+}
+
+// Population of programs using tournament selection, implementing ga.Population
+type Population struct {
+	Set       *Settings
+	Pop       []*Individual
+	TournSize int
+	best      *Individual
+}
+
+func NewPopulation(set *Settings, tournSize int) *Population {
+	return &Population{Set: set, TournSize: tournSize}
+}
+
+func (pop *Population) Initialize(n int) {
+	pop.Pop = make([]*Individual, n)
+	for i := range pop.Pop {
+		pop.Pop[i] = NewIndividual(pop.Set)
+		pop.Pop[i].Initialize()
+	}
+	pop.best = nil
+}
+
+// Evaluate the population, returning the evaluations since the last call,
+// including the ones done while breeding
+func (pop *Population) Evaluate() int {
+	pop.best = nil
+	for _, ind := range pop.Pop {
+		if pop.best == nil || pop.Set.BetterThan(ind.Fitness(), pop.best.Fitness()) {
+			pop.best = ind
+		}
+	}
+	return int(atomic.SwapInt64(&pop.Set.evaluations, 0))
+}
+
+func (pop *Population) Get(i int) ga.Individual {
+	return pop.Pop[i]
+}
+
+func (pop *Population) Replace(i int, ind ga.Individual) {
+	pop.Pop[i] = ind.(*Individual)
+}
+
+// Bind an individual of another population to the settings of this one,
+// invalidating its fitness
+func (pop *Population) Adopt(ind ga.Individual) {
+	i := ind.(*Individual)
+	i.set = pop.Set
+	i.Invalidate()
+}
+
+func (pop *Population) Size() int {
+	return len(pop.Pop)
+}
+
+func (pop *Population) BestIndividual() ga.Individual {
+	return pop.best
+}
+
+func (pop *Population) Select(n int, gen float32) ([]ga.Individual, error) {
+	sel := make([]ga.Individual, n)
+	for i := range sel {
+		best := pop.Pop[rand.Intn(len(pop.Pop))]
+		for t := 1; t < pop.TournSize; t++ {
+			if c := pop.Pop[rand.Intn(len(pop.Pop))]; pop.Set.BetterThan(c.Fitness(), best.Fitness()) {
+				best = c
+			}
+		}
+		sel[i] = best.Copy()
+	}
+	return sel, nil
+}
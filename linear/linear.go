@@ -0,0 +1,132 @@
+/*
+Package linear implements linear genetic programming, where solutions are
+sequences of register machine instructions instead of trees.
+
+Each instruction applies a functional gp.Primitive to some registers and
+writes the result in a destination register, e.g. r2 = Sum(r0, i1).
+Registers hold gp.Primitive values, exactly like the arguments passed to Run
+when compiling a tree: running a program returns the Primitive left in the
+output register, which can be evaluated as a compiled tree would be.
+
+There are two kinds of registers: calculation registers, that can be read
+and written, and input registers, that are read-only and hold the terminals.
+Calculation registers are initialized with the terminals, cyclically.
+*/
+package linear
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/akiross/gogp/gp"
+)
+
+// A Machine describes the registers and the instruction set of programs
+type Machine struct {
+	Registers int            // Number of calculation registers
+	Funcs     []gp.Primitive // Functionals used as instructions
+	Terms     []gp.Primitive // Terminals stored in the input registers
+	Output    int            // Calculation register holding the result
+}
+
+// Total number of registers, calculation registers come first
+func (m *Machine) NumRegisters() int {
+	return m.Registers + len(m.Terms)
+}
+
+// A single instruction: Dst = Op(Src...)
+type Instruction struct {
+	Op  gp.Primitive
+	Dst int   // Index of the calculation register to write
+	Src []int // Index of the registers to read, one for each argument of Op
+}
+
+func (in *Instruction) copy() Instruction {
+	return Instruction{in.Op, in.Dst, append([]int{}, in.Src...)}
+}
+
+// A Program is a sequence of instructions running on a Machine
+type Program struct {
+	Machine *Machine
+	Code    []Instruction
+	Inputs  []gp.Primitive // Terminals in input registers, with ephemerals already generated
+}
+
+func (p *Program) Copy() *Program {
+	c := &Program{p.Machine, make([]Instruction, len(p.Code)), append([]gp.Primitive{}, p.Inputs...)}
+	for i := range p.Code {
+		c.Code[i] = p.Code[i].copy()
+	}
+	return c
+}
+
+// Name of the register with given index
+func (p *Program) regName(r int) string {
+	if r < p.Machine.Registers {
+		return fmt.Sprintf("r%v", r)
+	}
+	return p.Inputs[r-p.Machine.Registers].Name()
+}
+
+func (p *Program) String() string {
+	var buf bytes.Buffer
+	for i := range p.Code {
+		in := &p.Code[i]
+		fmt.Fprintf(&buf, "r%v = %v(", in.Dst, in.Op.Name())
+		for j, s := range in.Src {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(p.regName(s))
+		}
+		buf.WriteString(")\n")
+	}
+	return buf.String()
+}
+
+// Get the indices of the effective instructions, i.e. those that contribute to
+// the output register. The other instructions are structural introns
+func (p *Program) Effective() []int {
+	needed := make([]bool, p.Machine.NumRegisters())
+	needed[p.Machine.Output] = true
+	eff := make([]int, 0, len(p.Code))
+	for i := len(p.Code) - 1; i >= 0; i-- {
+		in := &p.Code[i]
+		if !needed[in.Dst] {
+			continue
+		}
+		needed[in.Dst] = false
+		for _, s := range in.Src {
+			needed[s] = true
+		}
+		eff = append(eff, i)
+	}
+	// Restore the execution order
+	for i, j := 0, len(eff)-1; i < j; i, j = i+1, j-1 {
+		eff[i], eff[j] = eff[j], eff[i]
+	}
+	return eff
+}
+
+// Execute the program, returning the primitive in the output register.
+// Only effective instructions are executed
+func (p *Program) Run() gp.Primitive {
+	m := p.Machine
+	regs := make([]gp.Primitive, m.NumRegisters())
+	for r := range regs {
+		if r < m.Registers {
+			regs[r] = p.Inputs[r%len(p.Inputs)]
+		} else {
+			regs[r] = p.Inputs[r-m.Registers]
+		}
+	}
+	args := make([]gp.Primitive, 0, 4)
+	for _, i := range p.Effective() {
+		in := &p.Code[i]
+		args = args[:0]
+		for _, s := range in.Src {
+			args = append(args, regs[s])
+		}
+		regs[in.Dst] = in.Op.Run(args...)
+	}
+	return regs[m.Output]
+}
@@ -0,0 +1,130 @@
+package linear
+
+import (
+	"github.com/akiross/gogp/ga"
+	"github.com/akiross/gogp/gp"
+	"math/rand"
+	"testing"
+)
+
+// Integer expressions of x
+type expr struct {
+	name  string
+	arity int
+	eval  func(x int) int
+	op    func(a, b int) int
+}
+
+func (e *expr) IsFunctional() bool { return e.arity > 0 }
+func (e *expr) IsEphemeral() bool  { return false }
+func (e *expr) Arity() int         { return e.arity }
+func (e *expr) Name() string       { return e.name }
+func (e *expr) Run(args ...gp.Primitive) gp.Primitive {
+	if e.arity <= 0 {
+		return e
+	}
+	a, b := args[0].(*expr), args[1].(*expr)
+	return &expr{"", -1, func(x int) int { return e.op(a.eval(x), b.eval(x)) }, nil}
+}
+
+func testMachine() *Machine {
+	return &Machine{
+		Registers: 3,
+		Funcs: []gp.Primitive{
+			&expr{"Add", 2, nil, func(a, b int) int { return a + b }},
+			&expr{"Sub", 2, nil, func(a, b int) int { return a - b }},
+			&expr{"Mul", 2, nil, func(a, b int) int { return a * b }},
+		},
+		Terms: []gp.Primitive{
+			&expr{"X", -1, func(x int) int { return x }, nil},
+			&expr{"One", -1, func(int) int { return 1 }, nil},
+		},
+	}
+}
+
+func TestRunAndEffective(t *testing.T) {
+	m := testMachine()
+	add, mul := m.Funcs[0], m.Funcs[2]
+	p := &Program{m, []Instruction{
+		{mul, 0, []int{3, 3}}, // r0 = X * X
+		{add, 1, []int{0, 4}}, // r1 = r0 + One (intron)
+		{add, 0, []int{0, 3}}, // r0 = r0 + X
+	}, m.Terms}
+	eff := p.Effective()
+	if len(eff) != 2 || eff[0] != 0 || eff[1] != 2 {
+		t.Error("Expected effective instructions [0 2], got", eff)
+	}
+	f := p.Run().(*expr)
+	for x := -3; x <= 3; x++ {
+		if f.eval(x) != x*x+x {
+			t.Error("Program computed", f.eval(x), "for x =", x, "expected", x*x+x)
+		}
+	}
+}
+
+func TestVariation(t *testing.T) {
+	m := testMachine()
+	cross := MakeTwoPointCrossover(2, 10)
+	micro := MakeInstructionMutation()
+	macro := MakeMacroMutation(2, 10)
+	for i := 0; i < 1000; i++ {
+		p1, p2 := MakeProgram(m, 2, 10), MakeProgram(m, 2, 10)
+		n1, n2 := len(p1.Code), len(p2.Code)
+		cross(p1, p2)
+		if len(p1.Code)+len(p2.Code) != n1+n2 {
+			t.Fatal("Crossover changed the total number of instructions")
+		}
+		micro(0.5, p1)
+		macro(1, p2)
+		for _, p := range []*Program{p1, p2} {
+			if len(p.Code) < 2 || len(p.Code) > 10 {
+				t.Fatal("Program length out of bounds:", len(p.Code))
+			}
+			for _, in := range p.Code {
+				if in.Dst >= m.Registers || len(in.Src) != in.Op.Arity() {
+					t.Fatal("Invalid instruction", in)
+				}
+			}
+		}
+	}
+}
+
+func TestLengthBounds(t *testing.T) {
+	for _, b := range [][2]int{{0, 10}, {-1, 10}, {5, 4}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected a panic for lengths", b)
+				}
+			}()
+			MakeTwoPointCrossover(b[0], b[1])
+		}()
+	}
+}
+
+func TestEngine(t *testing.T) {
+	rand.Seed(1)
+	// Symbolic regression of x^2 + x
+	fit := func(p *Program) ga.Fitness {
+		f := p.Run().(*expr)
+		var err ga.Fitness
+		for x := -5; x <= 5; x++ {
+			d := f.eval(x) - (x*x + x)
+			err += ga.Fitness(d * d)
+		}
+		return err
+	}
+	pop := NewPopulation(NewSettings(testMachine(), 2, 20, fit), 3)
+	pop.Initialize(50)
+	pop.Evaluate()
+	first := pop.BestIndividual().Fitness()
+
+	e := ga.NewEngine(pop, pop.Set.BetterThan)
+	e.MaxGen = 30
+	e.Elitism = 1
+	e.Terminate = append(e.Terminate, ga.TargetFitness(0))
+	e.Run()
+	if last := pop.BestIndividual().Fitness(); last > first {
+		t.Error("Best fitness got worse:", first, "->", last)
+	}
+}
@@ -0,0 +1,150 @@
+package linear
+
+import (
+	"fmt"
+	"github.com/akiross/gogp/gp"
+	"math/rand"
+)
+
+// Build a random instruction for machine m
+func randomInstruction(m *Machine) Instruction {
+	op := m.Funcs[rand.Intn(len(m.Funcs))]
+	in := Instruction{op, rand.Intn(m.Registers), make([]int, op.Arity())}
+	for i := range in.Src {
+		in.Src[i] = rand.Intn(m.NumRegisters())
+	}
+	return in
+}
+
+// Fill the input registers, generating the ephemerals
+func randomInputs(m *Machine) []gp.Primitive {
+	inputs := make([]gp.Primitive, len(m.Terms))
+	for i, t := range m.Terms {
+		if t.IsEphemeral() {
+			inputs[i] = t.Run()
+		} else {
+			inputs[i] = t
+		}
+	}
+	return inputs
+}
+
+// Programs must have at least one instruction, or there would be no segment
+// to pick for crossover
+func checkLengths(minLen, maxLen int) {
+	if minLen < 1 || maxLen < minLen {
+		panic(fmt.Sprintf("ERROR! Program lengths must satisfy 1 <= minLen (%v) <= maxLen (%v)", minLen, maxLen))
+	}
+}
+
+// Builds a random program with a number of instructions in [minLen, maxLen]
+func MakeProgram(m *Machine, minLen, maxLen int) *Program {
+	checkLengths(minLen, maxLen)
+	p := &Program{m, make([]Instruction, minLen+rand.Intn(maxLen-minLen+1)), randomInputs(m)}
+	for i := range p.Code {
+		p.Code[i] = randomInstruction(m)
+	}
+	return p
+}
+
+// Micro mutation: each instruction is mutated with probability pMut, changing
+// either its operation (with another of same arity), its destination or one
+// of its sources. Returns the number of mutated instructions
+func MakeInstructionMutation() func(float64, *Program) int {
+	return func(pMut float64, p *Program) int {
+		m := p.Machine
+		mutCount := 0
+		for i := range p.Code {
+			if rand.Float64() >= pMut {
+				continue
+			}
+			in := &p.Code[i]
+			switch rand.Intn(3) {
+			case 0:
+				same := make([]gp.Primitive, 0, len(m.Funcs))
+				for _, f := range m.Funcs {
+					if f.Arity() == in.Op.Arity() {
+						same = append(same, f)
+					}
+				}
+				in.Op = same[rand.Intn(len(same))]
+			case 1:
+				in.Dst = rand.Intn(m.Registers)
+			case 2:
+				in.Src[rand.Intn(len(in.Src))] = rand.Intn(m.NumRegisters())
+			}
+			mutCount++
+		}
+		return mutCount
+	}
+}
+
+// Macro mutation: with probability pMut, inserts a random instruction or
+// deletes one (50-50), keeping the length in [minLen, maxLen].
+// Returns true if the program was changed
+func MakeMacroMutation(minLen, maxLen int) func(float64, *Program) bool {
+	checkLengths(minLen, maxLen)
+	return func(pMut float64, p *Program) bool {
+		if rand.Float64() >= pMut {
+			return false
+		}
+		if rand.Intn(2) == 0 && len(p.Code) < maxLen {
+			i := rand.Intn(len(p.Code) + 1)
+			p.Code = append(p.Code, Instruction{})
+			copy(p.Code[i+1:], p.Code[i:])
+			p.Code[i] = randomInstruction(p.Machine)
+			return true
+		} else if len(p.Code) > minLen {
+			i := rand.Intn(len(p.Code))
+			p.Code = append(p.Code[:i], p.Code[i+1:]...)
+			return true
+		}
+		return false
+	}
+}
+
+// Two-point crossover: a random segment of each program is swapped with the
+// other. Segments are picked so that lengths stay in [minLen, maxLen]
+func MakeTwoPointCrossover(minLen, maxLen int) func(_, _ *Program) {
+	checkLengths(minLen, maxLen)
+	return func(p1, p2 *Program) {
+		l1, l2 := len(p1.Code), len(p2.Code)
+		// Pick a segment in p1
+		s1 := rand.Intn(l1)
+		n1 := 1 + rand.Intn(l1-s1)
+		// The segment in p2 must have a length that keeps both in bounds:
+		// minLen <= l1-n1+n2 <= maxLen and minLen <= l2-n2+n1 <= maxLen
+		lo := intMax(1, minLen-l1+n1, l2+n1-maxLen)
+		hi := intMin(l2, maxLen-l1+n1, l2+n1-minLen)
+		if lo > hi {
+			return
+		}
+		n2 := lo + rand.Intn(hi-lo+1)
+		s2 := rand.Intn(l2 - n2 + 1)
+
+		seg1 := append([]Instruction{}, p1.Code[s1:s1+n1]...)
+		seg2 := append([]Instruction{}, p2.Code[s2:s2+n2]...)
+		p1.Code = append(append(append([]Instruction{}, p1.Code[:s1]...), seg2...), p1.Code[s1+n1:]...)
+		p2.Code = append(append(append([]Instruction{}, p2.Code[:s2]...), seg1...), p2.Code[s2+n2:]...)
+	}
+}
+
+func intMax(n ...int) int {
+	m := n[0]
+	for _, v := range n[1:] {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
+
+func intMin(n ...int) int {
+	m := n[0]
+	for _, v := range n[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,166 @@
+package node
+
+// Automatically defined functions: a program has a result-producing branch
+// and some function-defining branches. The result-producing branch calls the
+// ADFs as functionals, ADF bodies read their arguments from Param terminals
+// and can call the ADFs defined before them
+
+import (
+	"fmt"
+	"github.com/akiross/gogp/gp"
+	"math/rand"
+)
+
+// A parameter of an ADF, used as terminal in its body
+type Param struct {
+	index int
+}
+
+func (p *Param) IsFunctional() bool { return false }
+func (p *Param) IsEphemeral() bool  { return false }
+func (p *Param) Arity() int         { return -1 }
+func (p *Param) Name() string       { return fmt.Sprintf("ARG%v", p.index) }
+func (p *Param) Run(args ...gp.Primitive) gp.Primitive {
+	panic(fmt.Sprint("ERROR! Parameter ", p.Name(), " used outside of an ADF"))
+}
+
+// Call to an ADF. ADFs in the trees of a program are bound to its bodies,
+// so CompileTree on the result-producing branch compiles the ADFs as well
+type ADF struct {
+	index int
+	arity int
+	body  *Node // Nil until bound to a program
+}
+
+func (a *ADF) IsFunctional() bool { return true }
+func (a *ADF) IsEphemeral() bool  { return false }
+func (a *ADF) Arity() int         { return a.arity }
+func (a *ADF) Name() string       { return fmt.Sprintf("ADF%v", a.index) }
+
+// Compile the body with the parameters replaced by args
+func (a *ADF) Run(args ...gp.Primitive) gp.Primitive {
+	if a.body == nil {
+		panic(fmt.Sprint("ERROR! ", a.Name(), " is not bound to a program"))
+	}
+	return compileTree(a.body, args)
+}
+
+// Primitives of the branches of programs with ADFs
+type ADFSet struct {
+	Funcs, Terms []gp.Primitive // Used in every branch
+	Arities      []int          // Number of parameters of each ADF
+}
+
+// Functionals and terminals that can be used in branch b, where branch 0 is
+// the result-producing one and branch i+1 is the i-th ADF
+func (s *ADFSet) Branch(b int) (funcs, terms []gp.Primitive) {
+	funcs = append([]gp.Primitive{}, s.Funcs...)
+	terms = append([]gp.Primitive{}, s.Terms...)
+	nCalls := len(s.Arities)
+	if b > 0 {
+		// ADFs can call only the previous ones, and read their parameters
+		nCalls = b - 1
+		for i := 0; i < s.Arities[b-1]; i++ {
+			terms = append(terms, &Param{i})
+		}
+	}
+	for i := 0; i < nCalls; i++ {
+		funcs = append(funcs, &ADF{i, s.Arities[i], nil})
+	}
+	return
+}
+
+// A program with a result-producing branch and function-defining branches
+type ADFProgram struct {
+	Main *Node
+	ADFs []*Node
+}
+
+// Generate a program using genFunction for each branch (e.g. MakeTreeGrow)
+func MakeADFProgram(minH, maxH int, set *ADFSet, genFunction func(minH, maxH int, funcs, terms []gp.Primitive) *Node) *ADFProgram {
+	p := &ADFProgram{ADFs: make([]*Node, len(set.Arities))}
+	for b := range p.ADFs {
+		funcs, terms := set.Branch(b + 1)
+		p.ADFs[b] = genFunction(minH, maxH, funcs, terms)
+	}
+	funcs, terms := set.Branch(0)
+	p.Main = genFunction(minH, maxH, funcs, terms)
+	p.bind()
+	return p
+}
+
+// Get the root of branch b, 0 is the result-producing branch
+func (p *ADFProgram) Branch(b int) *Node {
+	if b == 0 {
+		return p.Main
+	}
+	return p.ADFs[b-1]
+}
+
+// Make every ADF call in the program refer to the bodies of this program
+func (p *ADFProgram) bind() {
+	defs := make([]*ADF, len(p.ADFs))
+	for i := range defs {
+		defs[i] = &ADF{i, 0, p.ADFs[i]}
+	}
+	for b := 0; b <= len(p.ADFs); b++ {
+		nodes, _, _ := p.Branch(b).Enumerate()
+		for _, n := range nodes {
+			if a, ok := n.value.(*ADF); ok {
+				if defs[a.index].arity == 0 {
+					defs[a.index].arity = a.arity
+				}
+				n.value = defs[a.index]
+			}
+		}
+	}
+}
+
+func (p *ADFProgram) Copy() *ADFProgram {
+	c := &ADFProgram{p.Main.Copy(), make([]*Node, len(p.ADFs))}
+	for i := range p.ADFs {
+		c.ADFs[i] = p.ADFs[i].Copy()
+	}
+	c.bind()
+	return c
+}
+
+// Compile the result-producing branch, calling the ADFs
+func (p *ADFProgram) Compile() gp.Primitive {
+	return CompileTree(p.Main)
+}
+
+func (p *ADFProgram) String() string {
+	s := fmt.Sprint(p.Main)
+	for i, a := range p.ADFs {
+		s += fmt.Sprintf("; ADF%v = %v", i, a)
+	}
+	return s
+}
+
+// Branch-typed crossover: a branch is picked at random and the same branch of
+// both programs is crossed with MakeTree1pCrossover, so subtrees are moved
+// only between branches with the same primitives
+func MakeADFCrossover(maxDepth int) func(_, _ *ADFProgram) {
+	cross := MakeTree1pCrossover(maxDepth)
+	return func(p1, p2 *ADFProgram) {
+		b := rand.Intn(len(p1.ADFs) + 1)
+		cross(p1.Branch(b), p2.Branch(b))
+		p1.bind()
+		p2.bind()
+	}
+}
+
+// Subtree mutation of a random branch, generating subtrees with the
+// primitives of that branch. genFunction is used as in MakeADFProgram
+func MakeADFSubtreeMutation(maxH int, set *ADFSet, genFunction func(minH, maxH int, funcs, terms []gp.Primitive) *Node, statRecord StatRecorder) func(*ADFProgram) {
+	return func(p *ADFProgram) {
+		b := rand.Intn(len(p.ADFs) + 1)
+		funcs, terms := set.Branch(b)
+		mut := MakeSubtreeMutation(maxH, func(h int) *Node {
+			return genFunction(0, h, funcs, terms)
+		}, statRecord)
+		mut(p.Branch(b))
+		p.bind()
+	}
+}
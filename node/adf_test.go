@@ -0,0 +1,81 @@
+package node
+
+import (
+	"github.com/akiross/gogp/gp"
+	"testing"
+)
+
+func TestADFCompile(t *testing.T) {
+	one, id := Terminal1(Constant1(1)), Terminal1(Identity1)
+	sum, sub := Functional2(Sum), Functional2(Sub)
+	set := &ADFSet{[]gp.Primitive{sum, sub}, []gp.Primitive{one, id}, []int{2, 1}}
+	mfuncs, _ := set.Branch(0)
+	_, aterms := set.Branch(2)
+	adf0, adf1 := mfuncs[2], mfuncs[3]
+	arg0 := aterms[2]
+
+	// ADF0(a, b) = a - b, ADF1(a) = ADF0(a, 1) + a
+	p := &ADFProgram{
+		Main: mt(adf1, mt(sum, mt(id), mt(id))),
+		ADFs: []*Node{
+			mt(sub, mt(&Param{0}), mt(&Param{1})),
+			mt(sum, mt(adf0, mt(arg0), mt(one)), mt(arg0)),
+		},
+	}
+	p.bind()
+	for _, c := range []*ADFProgram{p, p.Copy()} {
+		f := c.Compile().(Terminal1)
+		for x := -3; x <= 3; x++ {
+			if v := f(x); v != 4*x-1 {
+				t.Error("Program", c, "computed", v, "for x =", x, "expected", 4*x-1)
+			}
+		}
+	}
+}
+
+// Check that each branch uses only its primitives and calls the ADFs of p
+func checkBranches(t *testing.T, p *ADFProgram, set *ADFSet) {
+	for b := 0; b <= len(p.ADFs); b++ {
+		nodes, _, _ := p.Branch(b).Enumerate()
+		for _, n := range nodes {
+			switch v := n.value.(type) {
+			case *ADF:
+				if v.body != p.ADFs[v.index] {
+					t.Fatal("ADF call in branch", b, "not bound to the program")
+				}
+				if b > 0 && v.index >= b-1 {
+					t.Fatal("Branch", b, "calls", v.Name())
+				}
+			case *Param:
+				if b == 0 || v.index >= set.Arities[b-1] {
+					t.Fatal("Branch", b, "uses", v.Name())
+				}
+			}
+		}
+	}
+}
+
+func TestADFVariation(t *testing.T) {
+	set := &ADFSet{
+		[]gp.Primitive{Functional2(Sum), Functional2(Sub), Functional1(Abs)},
+		[]gp.Primitive{Terminal1(c_zero), Terminal1(c_one), Terminal1(Identity1)},
+		[]int{2, 1, 3},
+	}
+	cross := MakeADFCrossover(5)
+	mut := MakeADFSubtreeMutation(5, set, MakeTreeHalfAndHalf, nil)
+	for i := 0; i < 200; i++ {
+		p1 := MakeADFProgram(0, 3, set, MakeTreeHalfAndHalf)
+		p2 := MakeADFProgram(0, 3, set, MakeTreeFull)
+		checkBranches(t, p1, set)
+		cross(p1, p2)
+		mut(p1)
+		c := p2.Copy()
+		checkBranches(t, p1, set)
+		checkBranches(t, p2, set)
+		checkBranches(t, c, set)
+		p1.Compile().(Terminal1)(1)
+		if c.Compile().(Terminal1)(2) != p2.Compile().(Terminal1)(2) {
+			t.Fatal("Copy computes a different value")
+		}
+	}
+}
@@ -27,8 +27,11 @@ package node
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/binary"
 	"fmt"
 	"github.com/akiross/gogp/gp"
+	"hash"
+	"hash/fnv"
 	"os/exec"
 	"strings"
 )
@@ -38,6 +41,21 @@ type Node struct {
 	children []*Node
 }
 
+// Build a node holding value, with the given children
+func New(value gp.Primitive, children ...*Node) *Node {
+	return &Node{value, children}
+}
+
+// The primitive held by the node
+func (n *Node) Value() gp.Primitive {
+	return n.value
+}
+
+// The children of the node, nil for terminals
+func (n *Node) Children() []*Node {
+	return n.children
+}
+
 func (n *Node) ChiCo() int {
 	return len(n.children)
 }
@@ -72,6 +90,81 @@ func (root *Node) MarshalJSON() ([]byte, error) {
 	}
 }
 
+// Lookup resolves the name of a primitive, as written by MarshalJSON,
+// to the primitive itself
+type Lookup func(name string) (gp.Primitive, error)
+
+// The JSON structure written by MarshalJSON
+type jsonNode struct {
+	Terminal   *string     `json:"terminal"`
+	Functional *string     `json:"functional"`
+	Children   []*jsonNode `json:"children"`
+}
+
+func (jn *jsonNode) build(lookup Lookup) (*Node, error) {
+	var name string
+	switch {
+	case jn.Terminal != nil:
+		name = *jn.Terminal
+	case jn.Functional != nil:
+		name = *jn.Functional
+	default:
+		return nil, fmt.Errorf("node is neither a terminal nor a functional")
+	}
+	p, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if p.IsFunctional() != (jn.Functional != nil) || (p.IsFunctional() && p.Arity() != len(jn.Children)) {
+		return nil, fmt.Errorf("primitive %q does not match the node (%v children)", name, len(jn.Children))
+	}
+	n := &Node{p, make([]*Node, len(jn.Children))}
+	for i := range jn.Children {
+		if n.children[i], err = jn.Children[i].build(lookup); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// Rebuild a tree written by MarshalJSON, resolving primitives with lookup
+func Unmarshal(data []byte, lookup Lookup) (*Node, error) {
+	var jn jsonNode
+	if err := json.Unmarshal(data, &jn); err != nil {
+		return nil, err
+	}
+	return jn.build(lookup)
+}
+
+// Rebuild a tree written by MarshalJSON, using gp.DefaultRegistry
+func (root *Node) UnmarshalJSON(data []byte) error {
+	n, err := Unmarshal(data, gp.DefaultRegistry.Lookup)
+	if err != nil {
+		return err
+	}
+	*root = *n
+	return nil
+}
+
+// Structural hash of the tree, computed on primitive names and tree shape.
+// Trees that print the same have the same hash, so names must identify the
+// primitives, as checked by gp.Registry.Register
+func (root *Node) Hash() uint64 {
+	h := fnv.New64a()
+	root.hash(h)
+	return h.Sum64()
+}
+
+func (root *Node) hash(h hash.Hash64) {
+	// Name and number of children, in pre-order, identify the tree
+	var buf [binary.MaxVarintLen64]byte
+	h.Write([]byte(root.value.Name()))
+	h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(root.children))+1)])
+	for _, c := range root.children {
+		c.hash(h)
+	}
+}
+
 // Full copy of the tree
 func (root *Node) Copy() *Node {
 	childr := make([]*Node, len(root.children))
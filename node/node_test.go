@@ -1,6 +1,8 @@
 package node
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/akiross/gogp/gp"
 	"testing"
 )
@@ -199,3 +201,121 @@ func TestEvaluation(t *testing.T) {
 		t.Error("expression 'Sum(Sum(1, 1), Abs(Sub(0, 0)))' should have value 2 but had", v)
 	}
 }
+
+func TestUnmarshal(t *testing.T) {
+	funcs := []gp.Primitive{Functional2(Sum), Functional2(Sub), Functional1(Abs)}
+	terms := []gp.Primitive{Terminal1(c_zero), Terminal1(c_one), Terminal1(Identity1)}
+	reg := gp.NewRegistry()
+	reg.Register(funcs...)
+	reg.Register(terms...)
+	lookup := reg.Lookup
+
+	for i := 0; i < 20; i++ {
+		tree := genBalTree(5)
+		data, err := tree.MarshalJSON()
+		if err != nil {
+			t.Fatal("Cannot marshal tree:", err)
+		}
+		back, err := Unmarshal(data, lookup)
+		if err != nil {
+			t.Fatal("Cannot unmarshal tree:", err)
+		}
+		if back.String() != tree.String() {
+			t.Error("Unmarshaled tree", back, "differs from", tree)
+		}
+		for x := -2; x <= 2; x++ {
+			if CompileTree(back).(Terminal1)(x) != CompileTree(tree).(Terminal1)(x) {
+				t.Error("Unmarshaled tree", back, "evaluates differently in", x)
+			}
+		}
+	}
+
+	if _, err := Unmarshal([]byte(`{"functional": "Sum", "children": [{"terminal": "c_one"}]}`), lookup); err == nil {
+		t.Error("Expected error for wrong arity")
+	}
+	if _, err := Unmarshal([]byte(`{"terminal": "Nope"}`), lookup); err == nil {
+		t.Error("Expected error for unknown primitive")
+	}
+
+	// Names must identify primitives. Functions cannot be compared, so they
+	// cannot be registered again
+	if err := reg.Register(funcs...); err == nil {
+		t.Error("Expected error registering again the same functions")
+	}
+	param := &Param{0}
+	if err := reg.Register(param, param); err != nil {
+		t.Error("Cannot register again the same primitive:", err)
+	}
+	if err := reg.Register(&Param{0}); err == nil {
+		t.Error("Expected error for a different parameter with the same name")
+	}
+	if err := reg.Register(Functional1(Sum)); err == nil {
+		t.Error("Expected error for a different primitive with the same name")
+	}
+	if p, _ := lookup("Sum"); p.Arity() != 2 {
+		t.Error("Registered primitive was replaced")
+	}
+	// Closures built by the same function have the same name
+	if err := gp.NewRegistry().Register(Terminal1(Constant1(0)), Terminal1(Constant1(1))); err == nil {
+		t.Error("Expected error for closures with the same name")
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	gp.DefaultRegistry.Register(Functional2(Sum), Functional1(Abs), Terminal1(c_one), Terminal1(Identity1))
+	// Ephemeral constants have their value in the name
+	gp.DefaultRegistry.RegisterEphemeral("K", func(name string) (gp.Primitive, error) {
+		var c int
+		if _, err := fmt.Sscanf(name, "K%d", &c); err != nil {
+			return nil, err
+		}
+		return namedConst{name, c}, nil
+	})
+
+	var tree Node
+	src := `{"functional": "Sum", "children": [{"terminal": "K42"}, {"functional": "Abs", "children": [{"terminal": "Identity1"}]}]}`
+	if err := json.Unmarshal([]byte(src), &tree); err != nil {
+		t.Fatal("Cannot unmarshal:", err)
+	}
+	if v := CompileTree(&tree).(Terminal1)(-3); v != 45 {
+		t.Error("Expected 42 + |-3| = 45, got", v)
+	}
+	data, _ := json.Marshal(&tree)
+	var back Node
+	if err := json.Unmarshal(data, &back); err != nil || back.String() != tree.String() {
+		t.Error("Round trip failed:", err, back.String(), tree.String())
+	}
+}
+
+// A named constant, compiled to a Terminal1
+type namedConst struct {
+	name string
+	c    int
+}
+
+func (k namedConst) IsFunctional() bool { return false }
+func (k namedConst) IsEphemeral() bool  { return false }
+func (k namedConst) Arity() int         { return -1 }
+func (k namedConst) Name() string       { return k.name }
+func (k namedConst) Run(p ...gp.Primitive) gp.Primitive {
+	return Terminal1(func(_ int) int { return k.c })
+}
+
+func TestHash(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		tree := genBalTree(4)
+		if tree.Hash() != tree.Copy().Hash() {
+			t.Error("Copy of", tree, "has a different hash")
+		}
+		other := genBalTree(4)
+		if (tree.String() == other.String()) != (tree.Hash() == other.Hash()) {
+			t.Error("Hash does not match structure for", tree, "and", other)
+		}
+	}
+	// Same names in pre-order, but different shape
+	a := &Node{Functional2(Sum), []*Node{{Functional1(Abs), []*Node{{Terminal1(c_one), nil}}}, {Terminal1(c_one), nil}}}
+	b := &Node{Functional2(Sum), []*Node{{Functional1(Abs), []*Node{{Terminal1(c_one), nil}, {Terminal1(c_one), nil}}}}}
+	if a.Hash() == b.Hash() {
+		t.Error("Trees with different shape have the same hash")
+	}
+}
@@ -59,51 +59,54 @@ func makeTree(depth int, funcs, terms []gp.Primitive, strategy func(int, int, in
 
 // Builds a tree using the grow method
 func MakeTreeGrow(minH, maxH int, funcs, terms []gp.Primitive) *Node {
-	growStrategy := func(depth, nFuncs, nTerms int) (isFunc bool, k int) {
-		if depth == 0 {
-			return false, rand.Intn(nTerms)
+	return makeTree(maxH, funcs, terms, growStrategy)
+}
+
+func growStrategy(depth, nFuncs, nTerms int) (isFunc bool, k int) {
+	if depth == 0 {
+		return false, rand.Intn(nTerms)
+	} else {
+		k := rand.Intn(nFuncs + nTerms)
+		if k < nFuncs {
+			return true, k
 		} else {
-			k := rand.Intn(nFuncs + nTerms)
-			if k < nFuncs {
-				return true, k
-			} else {
-				return false, k - nFuncs
-			}
+			return false, k - nFuncs
 		}
 	}
-	return makeTree(maxH, funcs, terms, growStrategy)
 }
 
 // Builds a tree using the grow method, but pick 50-50 funcs and terms
 func MakeTreeGrowBalanced(minH, maxH int, funcs, terms []gp.Primitive) *Node {
-	growBalStrategy := func(depth, nFuncs, nTerms int) (isFunc bool, k int) {
-		if depth == 0 {
-			return false, rand.Intn(nTerms)
+	return makeTree(maxH, funcs, terms, growBalStrategy)
+}
+
+func growBalStrategy(depth, nFuncs, nTerms int) (isFunc bool, k int) {
+	if depth == 0 {
+		return false, rand.Intn(nTerms)
+	} else {
+		if rand.Intn(2) == 0 {
+			// Pick a random functional
+			return true, rand.Intn(nFuncs)
 		} else {
-			if rand.Intn(2) == 0 {
-				// Pick a random functional
-				return true, rand.Intn(nFuncs)
-			} else {
-				// Pick a random terminal
-				return false, rand.Intn(nTerms)
-			}
+			// Pick a random terminal
+			return false, rand.Intn(nTerms)
 		}
 	}
-	return makeTree(maxH, funcs, terms, growBalStrategy)
 }
 
 // Builds a tree using the full method
 func MakeTreeFull(minH, maxH int, funcs, terms []gp.Primitive) *Node {
-	fullStrategy := func(depth, nFuncs, nTerms int) (isFunc bool, k int) {
-		if depth == 0 {
-			return false, rand.Intn(nTerms)
-		} else {
-			return true, rand.Intn(nFuncs)
-		}
-	}
 	return makeTree(maxH, funcs, terms, fullStrategy)
 }
 
+func fullStrategy(depth, nFuncs, nTerms int) (isFunc bool, k int) {
+	if depth == 0 {
+		return false, rand.Intn(nTerms)
+	} else {
+		return true, rand.Intn(nFuncs)
+	}
+}
+
 // Make a tree using the half and half method.
 // It's not the ramped version: it just uses grow or full with 50% chances
 func MakeTreeHalfAndHalf(minH, maxH int, funcs, terms []gp.Primitive) *Node {
@@ -117,11 +120,22 @@ func MakeTreeHalfAndHalf(minH, maxH int, funcs, terms []gp.Primitive) *Node {
 // Compiles a tree returning a gp.Primitive, resulting
 // from the execution of the Run method
 func CompileTree(root *Node) gp.Primitive {
+	return compileTree(root, nil)
+}
+
+// Compile the tree replacing ADF parameters with args
+func compileTree(root *Node, args []gp.Primitive) gp.Primitive {
+	if p, ok := root.value.(*Param); ok {
+		if p.index >= len(args) {
+			panic(fmt.Sprint("ERROR! Parameter ", p.Name(), " is not bound"))
+		}
+		return args[p.index]
+	}
 	if root.value.IsFunctional() {
 		// If it's a functional, compile each children and return
 		terms := make([]gp.Primitive, len(root.children))
 		for i := 0; i < len(root.children); i++ {
-			terms[i] = CompileTree(root.children[i])
+			terms[i] = compileTree(root.children[i], args)
 		}
 		if root.value.Arity() != len(terms) {
 			fmt.Println("ERROR! Trying to call a Functional with Arity", root.value.Arity(), "passing", len(terms), "arguments")
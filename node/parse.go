@@ -0,0 +1,220 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// A syntax error found while parsing a tree, with its position in the source
+type ParseError struct {
+	Pos       int // Byte offset
+	Line, Col int // Both starting from 1
+	Msg       string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %v, column %v: %v", e.Line, e.Col, e.Msg)
+}
+
+type parser struct {
+	src    string
+	pos    int
+	lookup Lookup
+}
+
+func (p *parser) errorAt(pos int, format string, args ...interface{}) error {
+	line := 1 + strings.Count(p.src[:pos], "\n")
+	col := pos - strings.LastIndex(p.src[:pos], "\n")
+	return &ParseError{pos, line, col, fmt.Sprintf(format, args...)}
+}
+
+// Skip spaces and comments, which start with ; and end with the line
+func (p *parser) skip() {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ';':
+			if end := strings.IndexByte(p.src[p.pos:], '\n'); end >= 0 {
+				p.pos += end
+			} else {
+				p.pos = len(p.src)
+			}
+		case unicode.IsSpace(rune(c)):
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+// Skip spaces and check that the next character is c
+func (p *parser) expect(c byte) error {
+	p.skip()
+	if p.pos >= len(p.src) {
+		return p.errorAt(p.pos, "expected %q, found end of input", c)
+	}
+	if p.src[p.pos] != c {
+		return p.errorAt(p.pos, "expected %q, found %q", c, p.src[p.pos])
+	}
+	p.pos++
+	return nil
+}
+
+// Resolve name, found at pos, and build a node with the given children
+func (p *parser) build(pos int, name string, functional bool, children []*Node) (*Node, error) {
+	prim, err := p.lookup(name)
+	if err != nil {
+		return nil, p.errorAt(pos, "%v", err)
+	}
+	if prim.IsFunctional() != functional {
+		if functional {
+			return nil, p.errorAt(pos, "%q is a terminal, but it has children", name)
+		}
+		return nil, p.errorAt(pos, "%q is a functional, but it has no children", name)
+	}
+	if functional && prim.Arity() != len(children) {
+		return nil, p.errorAt(pos, "%q takes %v arguments, found %v", name, prim.Arity(), len(children))
+	}
+	return &Node{prim, children}, nil
+}
+
+// Parse T{name} or F{name}(child, ...), as written by String
+func (p *parser) infix() (*Node, error) {
+	p.skip()
+	start := p.pos
+	if p.pos >= len(p.src) {
+		return nil, p.errorAt(p.pos, "expected a node, found end of input")
+	}
+	kind := p.src[p.pos]
+	if kind != 'T' && kind != 'F' {
+		return nil, p.errorAt(p.pos, "expected 'T' or 'F', found %q", kind)
+	}
+	p.pos++
+	if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+		return nil, p.errorAt(p.pos, "expected '{' after %q", kind)
+	}
+	p.pos++
+	end := strings.IndexByte(p.src[p.pos:], '}')
+	if end < 0 {
+		return nil, p.errorAt(start, "missing '}' closing the name")
+	}
+	name := p.src[p.pos : p.pos+end]
+	if name == "" {
+		return nil, p.errorAt(p.pos, "empty name")
+	}
+	p.pos += end + 1
+	if kind == 'T' {
+		return p.build(start, name, false, nil)
+	}
+	if err := p.expect('('); err != nil {
+		return nil, err
+	}
+	var children []*Node
+	for {
+		child, err := p.infix()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		p.skip()
+		if p.pos < len(p.src) && p.src[p.pos] == ',' {
+			p.pos++
+			continue
+		}
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		return p.build(start, name, true, children)
+	}
+}
+
+// An atom of an S-expression, ending at spaces, parenthesis or comments
+func (p *parser) atom() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '(' || c == ')' || c == ';' || unicode.IsSpace(rune(c)) {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// Parse a terminal name or (name child ...)
+func (p *parser) sexpr() (*Node, error) {
+	p.skip()
+	start := p.pos
+	if p.pos >= len(p.src) {
+		return nil, p.errorAt(p.pos, "expected a node, found end of input")
+	}
+	switch p.src[p.pos] {
+	case ')':
+		return nil, p.errorAt(p.pos, "unexpected ')'")
+	case '(':
+		p.pos++
+		p.skip()
+		namePos := p.pos
+		name := p.atom()
+		if name == "" {
+			return nil, p.errorAt(namePos, "expected a functional name")
+		}
+		var children []*Node
+		for {
+			p.skip()
+			if p.pos >= len(p.src) {
+				return nil, p.errorAt(start, "missing ')' closing the list")
+			}
+			if p.src[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			child, err := p.sexpr()
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, child)
+		}
+		return p.build(namePos, name, true, children)
+	}
+	return p.build(start, p.atom(), false, nil)
+}
+
+// Build a tree from its text representation, resolving primitives with lookup.
+// Two forms are accepted: the one written by String, e.g.
+//
+//	F{Sum}(T{X}, F{Abs}(T{Y}))
+//
+// and S-expressions, e.g.
+//
+//	(Sum X (Abs Y))
+//
+// In both forms, text from ; to the end of the line is a comment
+func Parse(src string, lookup Lookup) (*Node, error) {
+	p := &parser{src: src, lookup: lookup}
+	p.skip()
+	var root *Node
+	var err error
+	if p.pos < len(src) && (src[p.pos] == 'T' || src[p.pos] == 'F') && strings.HasPrefix(src[p.pos+1:], "{") {
+		root, err = p.infix()
+	} else {
+		root, err = p.sexpr()
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.skip()
+	if p.pos < len(src) {
+		return nil, p.errorAt(p.pos, "unexpected %q after the tree", src[p.pos])
+	}
+	return root, nil
+}
+
+// Like Parse, but panics on errors. Useful to write trees in tests
+func MustParse(src string, lookup Lookup) *Node {
+	n, err := Parse(src, lookup)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
@@ -0,0 +1,66 @@
+package node
+
+import (
+	"github.com/akiross/gogp/gp"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	reg := gp.NewRegistry()
+	reg.Register(Functional2(Sum), Functional2(Sub), Functional1(Abs))
+	reg.Register(Terminal1(c_zero), Terminal1(c_one), Terminal1(Identity1))
+
+	// The output of String can be read back
+	for i := 0; i < 20; i++ {
+		tree := genBalTree(5)
+		back, err := Parse(tree.String(), reg.Lookup)
+		if err != nil {
+			t.Fatal("Cannot parse", tree, err)
+		}
+		if back.String() != tree.String() {
+			t.Error("Parsed tree", back, "differs from", tree)
+		}
+	}
+
+	// Both forms build the same tree: 1 + |0 - x|
+	infix := MustParse("F{Sum}(T{c_one}, F{Abs}(F{Sub}(T{c_zero}, T{Identity1})))", reg.Lookup)
+	sexpr := MustParse(`
+		; Comments and spaces are ignored
+		(Sum c_one
+		     (Abs (Sub c_zero Identity1)))`, reg.Lookup)
+	if infix.String() != sexpr.String() {
+		t.Error("S-expression", sexpr, "differs from", infix)
+	}
+	if v := CompileTree(sexpr).(Terminal1)(3); v != 4 {
+		t.Error("Expected 1 + |0 - 3| = 4, got", v)
+	}
+
+	errors := []struct {
+		src       string
+		line, col int
+	}{
+		{"(Sum c_one)", 1, 2},                    // Wrong arity
+		{"(Sum c_one c_zero", 1, 1},              // Unclosed list
+		{"(Abs (Nope))", 1, 7},                   // Unknown primitive
+		{"(c_one c_zero)", 1, 2},                 // Terminal with children
+		{"\n  Abs", 2, 3},                        // Functional without children
+		{"c_one c_zero", 1, 7},                   // Trailing input
+		{"F{Abs}(T{c_one}", 1, 16},               // Unclosed arguments
+		{"F{Sum}(T{c_one} T{c_one})", 1, 17},     // Missing comma
+		{"F{Abs}(X{c_one})", 1, 8},               // Bad node kind
+		{"F{Abs}(T{c_one)", 1, 8},                // Unclosed name
+		{"F{Abs}(\n\tT{c_one}, T{c_one})", 1, 1}, // Wrong arity
+		{"", 1, 1},
+	}
+	for _, e := range errors {
+		_, err := Parse(e.src, reg.Lookup)
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("Expected a ParseError for %q, got %v", e.src, err)
+			continue
+		}
+		if pe.Line != e.line || pe.Col != e.col {
+			t.Errorf("Error for %q at %v:%v, expected %v:%v (%v)", e.src, pe.Line, pe.Col, e.line, e.col, pe)
+		}
+	}
+}
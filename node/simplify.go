@@ -0,0 +1,75 @@
+package node
+
+import (
+	"github.com/akiross/gogp/gp"
+)
+
+// A Rule rewrites a node, whose children are already simplified, returning
+// the replacement or nil if the rule does not apply. It must not modify n
+type Rule func(n *Node) *Node
+
+// Simplifier removes dead code from trees, folding constant subtrees and
+// applying rewrite rules registered for primitive names
+type Simplifier struct {
+	isConst func(gp.Primitive) bool                 // True for terminals that do not depend on the inputs
+	fold    func(gp.Primitive) (gp.Primitive, bool) // Terminal equivalent to a compiled constant subtree
+	rules   map[string][]Rule
+}
+
+// Build a simplifier. isConst tells which terminals are constants, and fold
+// converts the result of CompileTree on a subtree of constants in a terminal.
+// If fold is nil, constant subtrees are not folded
+func NewSimplifier(isConst func(gp.Primitive) bool, fold func(gp.Primitive) (gp.Primitive, bool)) *Simplifier {
+	return &Simplifier{isConst, fold, make(map[string][]Rule)}
+}
+
+// Register rules for the primitive with given name. Rules are tried in order
+func (s *Simplifier) AddRule(name string, rules ...Rule) {
+	s.rules[name] = append(s.rules[name], rules...)
+}
+
+// Returns true if n is a constant terminal
+func (s *Simplifier) IsConstant(n *Node) bool {
+	return len(n.children) == 0 && s.isConst != nil && s.isConst(n.value)
+}
+
+// Build a simplified copy of the tree, t is not modified
+func (s *Simplifier) Simplify(t *Node) *Node {
+	return s.simplify(t.Copy())
+}
+
+// Simplify in place, bottom-up
+func (s *Simplifier) simplify(n *Node) *Node {
+	if len(n.children) == 0 {
+		return n
+	}
+	allConst := true
+	for i, c := range n.children {
+		n.children[i] = s.simplify(c)
+		allConst = allConst && s.IsConstant(n.children[i])
+	}
+	if allConst && s.fold != nil {
+		if p, ok := s.fold(CompileTree(n)); ok {
+			return &Node{p, nil}
+		}
+	}
+	for _, r := range s.rules[n.value.Name()] {
+		if repl := r(n); repl != nil {
+			return s.simplify(repl)
+		}
+	}
+	return n
+}
+
+// Structural equality of trees, comparing primitive names
+func Equal(a, b *Node) bool {
+	if a.value.Name() != b.value.Name() || len(a.children) != len(b.children) {
+		return false
+	}
+	for i := range a.children {
+		if !Equal(a.children[i], b.children[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,259 @@
+package node
+
+// Strongly typed GP: generators and variation operators that only produce
+// trees where every child returns the type expected by its parent.
+// Primitives not implementing gp.TypedPrimitive have type gp.Any and are
+// compatible with anything, so these operators work on untyped sets as well
+
+import (
+	"fmt"
+	"github.com/akiross/gogp/gp"
+	"math/rand"
+)
+
+// Return type of the primitive in the root of the tree
+func (root *Node) Type() gp.Type {
+	return gp.ReturnType(root.value)
+}
+
+// Check that the tree returns type t and that every node gets arguments of
+// the expected types
+func TypeCheck(root *Node, t gp.Type) error {
+	if !gp.Compatible(root.Type(), t) {
+		return fmt.Errorf("%v returns %q, expected %q", root.value.Name(), root.Type(), t)
+	}
+	for i, c := range root.children {
+		if err := TypeCheck(c, gp.ArgType(root.value, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get the primitives returning a type compatible with t
+func filterType(prims []gp.Primitive, t gp.Type) []gp.Primitive {
+	sel := make([]gp.Primitive, 0, len(prims))
+	for _, p := range prims {
+		if gp.Compatible(gp.ReturnType(p), t) {
+			sel = append(sel, p)
+		}
+	}
+	return sel
+}
+
+// Check that every type reachable from t, i.e. t and the argument types of
+// the functionals that can be used for it, has a terminal. Otherwise trees of
+// that type could not be closed and generation would never end
+func checkTerminals(t gp.Type, funcs, terms []gp.Primitive) {
+	seen := map[gp.Type]bool{}
+	var visit func(t gp.Type)
+	visit = func(t gp.Type) {
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		if len(filterType(terms, t)) == 0 {
+			panic(fmt.Sprintf("ERROR! No terminal returns type %q", t))
+		}
+		for _, f := range filterType(funcs, t) {
+			for i := 0; i < f.Arity(); i++ {
+				visit(gp.ArgType(f, i))
+			}
+		}
+	}
+	visit(t)
+}
+
+// Like makeTree, but picks only primitives returning type t. Every reachable
+// type must have a terminal, as checked by checkTerminals
+func makeTypedTree(depth int, t gp.Type, funcs, terms []gp.Primitive, strategy func(int, int, int) (bool, int)) *Node {
+	tFuncs, tTerms := filterType(funcs, t), filterType(terms, t)
+	var isFunc bool
+	var k int
+	switch {
+	case len(tTerms) == 0:
+		panic(fmt.Sprintf("ERROR! No terminal returns type %q", t))
+	case len(tFuncs) == 0:
+		isFunc, k = false, rand.Intn(len(tTerms))
+	default:
+		if depth < 0 {
+			depth = 0
+		}
+		isFunc, k = strategy(depth, len(tFuncs), len(tTerms))
+	}
+
+	if isFunc {
+		root := &Node{tFuncs[k], make([]*Node, tFuncs[k].Arity())}
+		for i := range root.children {
+			root.children[i] = makeTypedTree(depth-1, gp.ArgType(tFuncs[k], i), funcs, terms, strategy)
+		}
+		return root
+	}
+	if tTerms[k].IsEphemeral() {
+		return &Node{tTerms[k].Run(), nil}
+	}
+	return &Node{tTerms[k], nil}
+}
+
+// Builds a tree returning type t using the grow method
+func MakeTypedTreeGrow(minH, maxH int, t gp.Type, funcs, terms []gp.Primitive) *Node {
+	checkTerminals(t, funcs, terms)
+	return makeTypedTree(maxH, t, funcs, terms, growStrategy)
+}
+
+// Builds a tree returning type t using the grow method, picking 50-50 funcs and terms
+func MakeTypedTreeGrowBalanced(minH, maxH int, t gp.Type, funcs, terms []gp.Primitive) *Node {
+	checkTerminals(t, funcs, terms)
+	return makeTypedTree(maxH, t, funcs, terms, growBalStrategy)
+}
+
+// Builds a tree returning type t using the full method. Branches may be
+// shorter when no functional returns the required type
+func MakeTypedTreeFull(minH, maxH int, t gp.Type, funcs, terms []gp.Primitive) *Node {
+	checkTerminals(t, funcs, terms)
+	return makeTypedTree(maxH, t, funcs, terms, fullStrategy)
+}
+
+// Typed version of MakeTreeHalfAndHalf
+func MakeTypedTreeHalfAndHalf(minH, maxH int, t gp.Type, funcs, terms []gp.Primitive) *Node {
+	if rand.Intn(2) == 0 {
+		return MakeTypedTreeGrowBalanced(minH, maxH, t, funcs, terms)
+	} else {
+		return MakeTypedTreeFull(minH, maxH, t, funcs, terms)
+	}
+}
+
+// Associate to each node the type expected by its parent. The root is expected
+// to keep its own type
+func slotTypes(root *Node) map[*Node]gp.Type {
+	slots := map[*Node]gp.Type{root: root.Type()}
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		for i, c := range n.children {
+			slots[c] = gp.ArgType(n.value, i)
+			visit(c)
+		}
+	}
+	visit(root)
+	return slots
+}
+
+// Get the primitives that can replace the value of node n, placed where type
+// t is expected: same arity, compatible return type and arguments compatible
+// with the types returned by the children of n
+func replacements(n *Node, t gp.Type, funcs, terms []gp.Primitive) []gp.Primitive {
+	prims := terms
+	if n.value.IsFunctional() {
+		prims = funcs
+	}
+	sel := make([]gp.Primitive, 0, len(prims))
+	for _, p := range prims {
+		if p.IsFunctional() && p.Arity() != len(n.children) {
+			continue
+		}
+		ok := gp.Compatible(gp.ReturnType(p), t)
+		for i := 0; ok && i < len(n.children); i++ {
+			ok = gp.Compatible(n.children[i].Type(), gp.ArgType(p, i))
+		}
+		if ok {
+			sel = append(sel, p)
+		}
+	}
+	return sel
+}
+
+// Replace the value of node n with a random type-compatible primitive.
+// Returns false if no replacement is available
+func mutateTyped(n *Node, t gp.Type, funcs, terms []gp.Primitive) bool {
+	prims := replacements(n, t, funcs, terms)
+	if len(prims) == 0 {
+		return false
+	}
+	p := prims[rand.Intn(len(prims))]
+	if p.IsEphemeral() {
+		n.value = p.Run()
+	} else {
+		n.value = p
+	}
+	return true
+}
+
+// Typed version of MakeTreeSingleMutation: the picked node is replaced with a
+// primitive of same arity and compatible types
+func MakeTypedTreeSingleMutation(funcs, terms []gp.Primitive, statRecord StatRecorder) func(*Node) {
+	return func(t *Node) {
+		nodes, depths, _ := t.Enumerate()
+		slots := slotTypes(t)
+		nid := rand.Intn(len(nodes))
+		mutateTyped(nodes[nid], slots[nodes[nid]], funcs, terms)
+		if statRecord != nil {
+			statRecord(depths[nid], 0, len(nodes[nid].children) == 0)
+		}
+	}
+}
+
+// Typed version of MakeTreeNodeMutation: each node is mutated with
+// probability pMut. Returns the number of mutated nodes
+func MakeTypedTreeNodeMutation(funcs, terms []gp.Primitive, statRecord StatRecorder) func(float64, *Node) int {
+	return func(pMut float64, t *Node) int {
+		nodes, _, _ := t.Enumerate()
+		slots := slotTypes(t)
+		mutCount := 0
+		for _, n := range nodes {
+			if rand.Float64() >= pMut {
+				continue
+			}
+			if mutateTyped(n, slots[n], funcs, terms) {
+				mutCount++
+			}
+		}
+		return mutCount
+	}
+}
+
+// Typed version of MakeSubtreeMutation: genFunction must build a tree
+// returning the given type with height at most maxH, e.g. using MakeTypedTreeGrow
+func MakeTypedSubtreeMutation(maxH int, genFunction func(maxH int, t gp.Type) *Node, statRecord StatRecorder) func(*Node) {
+	return func(t *Node) {
+		tNodes, tDepths, _ := t.Enumerate()
+		slots := slotTypes(t)
+		nid := rand.Intn(len(tNodes))
+		slot := slots[tNodes[nid]]
+		rd := generateHLimitedAndSwap(tNodes, tDepths, maxH, nid, func(h int) *Node {
+			return genFunction(h, slot)
+		})
+		if statRecord != nil {
+			statRecord(tDepths[nid], rd, len(tNodes[nid].children) == 0)
+		}
+	}
+}
+
+// Typed version of MakeTree1pCrossover: the subtree picked in the second tree
+// must fit the place of the one picked in the first, and vice versa.
+// If there is no such subtree, trees are left unchanged
+func MakeTypedTree1pCrossover(maxDepth int) func(_, _ *Node) {
+	return func(t1, t2 *Node) {
+		t1Nodes, t1Depths, t1Heights := t1.Enumerate()
+		t2Nodes, t2Depths, t2Heights := t2.Enumerate()
+		if maxDepth >= 0 && (t1Heights[0] > maxDepth || t2Heights[0] > maxDepth) {
+			panic(fmt.Sprintf("ERROR! maxHeight (%v) is lower than tree depth(s) (%v and %v)", maxDepth, t1Heights[0], t2Heights[0]))
+		}
+		s1, s2 := slotTypes(t1), slotTypes(t2)
+
+		rn1 := rand.Intn(len(t1Nodes))
+		n1 := t1Nodes[rn1]
+		allowed := make([]int, 0, len(t2Nodes))
+		for i, n2 := range t2Nodes {
+			if maxDepth >= 0 && (t1Depths[rn1]+t2Heights[i] > maxDepth || t2Depths[i]+t1Heights[rn1] > maxDepth) {
+				continue
+			}
+			if gp.Compatible(n2.Type(), s1[n1]) && gp.Compatible(n1.Type(), s2[n2]) {
+				allowed = append(allowed, i)
+			}
+		}
+		if len(allowed) == 0 {
+			return
+		}
+		swapNodes(n1, t2Nodes[allowed[rand.Intn(len(allowed))]])
+	}
+}
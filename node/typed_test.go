@@ -0,0 +1,100 @@
+package node
+
+import (
+	"github.com/akiross/gogp/gp"
+	"testing"
+)
+
+// A primitive with declared types, running to itself
+type typedPrim struct {
+	name string
+	ret  gp.Type
+	args []gp.Type
+}
+
+func (p *typedPrim) IsFunctional() bool                 { return len(p.args) > 0 }
+func (p *typedPrim) IsEphemeral() bool                  { return false }
+func (p *typedPrim) Run(a ...gp.Primitive) gp.Primitive { return p }
+func (p *typedPrim) Name() string                       { return p.name }
+func (p *typedPrim) ReturnType() gp.Type                { return p.ret }
+func (p *typedPrim) ArgTypes() []gp.Type                { return p.args }
+
+// Terminals have arity -1
+func (p *typedPrim) Arity() int {
+	if len(p.args) == 0 {
+		return -1
+	}
+	return len(p.args)
+}
+
+func typedSet() (funcs, terms []gp.Primitive) {
+	funcs = []gp.Primitive{
+		&typedPrim{"if", "num", []gp.Type{"bool", "num", "num"}},
+		&typedPrim{"add", "num", []gp.Type{"num", "num"}},
+		&typedPrim{"less", "bool", []gp.Type{"num", "num"}},
+		&typedPrim{"not", "bool", []gp.Type{"bool"}},
+	}
+	terms = []gp.Primitive{
+		&typedPrim{"x", "num", nil},
+		&typedPrim{"one", "num", nil},
+		&typedPrim{"true", "bool", nil},
+	}
+	return
+}
+
+func TestTypedOperators(t *testing.T) {
+	funcs, terms := typedSet()
+	gen := func(maxH int, ty gp.Type) *Node {
+		return MakeTypedTreeGrow(0, maxH, ty, funcs, terms)
+	}
+	single := MakeTypedTreeSingleMutation(funcs, terms, nil)
+	nodeMut := MakeTypedTreeNodeMutation(funcs, terms, nil)
+	subMut := MakeTypedSubtreeMutation(6, gen, nil)
+	cross := MakeTypedTree1pCrossover(6)
+
+	check := func(what string, tr *Node) {
+		if err := TypeCheck(tr, "num"); err != nil {
+			t.Fatal(what, "produced an ill-typed tree:", err, tr)
+		}
+		if d := Depth(tr); d > 6 {
+			t.Fatal(what, "produced a tree too deep:", d)
+		}
+	}
+	for i := 0; i < 500; i++ {
+		t1 := MakeTypedTreeHalfAndHalf(0, 4, "num", funcs, terms)
+		t2 := MakeTypedTreeFull(0, 4, "num", funcs, terms)
+		check("generation", t1)
+		check("generation", t2)
+		single(t1)
+		check("single mutation", t1)
+		nodeMut(0.3, t2)
+		check("node mutation", t2)
+		subMut(t1)
+		check("subtree mutation", t1)
+		cross(t1, t2)
+		check("crossover", t1)
+		check("crossover", t2)
+	}
+
+	// Ill-typed trees are detected
+	bad := mt(funcs[1], mt(terms[0]), mt(terms[2]))
+	if TypeCheck(bad, "num") == nil {
+		t.Error("Expected a type error for", bad)
+	}
+	// Untyped primitives are compatible with anything
+	if err := TypeCheck(mt(funcs[1], mt(Terminal1(c_one)), mt(terms[0])), "num"); err != nil {
+		t.Error("Unexpected type error", err)
+	}
+
+	// Types reachable without a terminal are rejected, instead of recursing
+	// forever: "list" is reachable from "num" through len
+	lists := append(funcs, &typedPrim{"len", "num", []gp.Type{"list"}}, &typedPrim{"cons", "list", []gp.Type{"num", "list"}})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Expected a panic for a type without terminals")
+			}
+		}()
+		MakeTypedTreeGrow(0, 4, "num", lists, terms)
+	}()
+}
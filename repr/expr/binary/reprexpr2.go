@@ -3,6 +3,8 @@ package binary
 import (
 	"fmt"
 	"github.com/akiross/gogp/gp"
+	"strconv"
+	"strings"
 	//	"math"
 )
 
@@ -63,6 +65,24 @@ func MakeConstant(c NumericOut) *Primitive {
 	}, nil, nil}
 }
 
+// Rebuild a constant from its name, e.g. C_0.5. Names written by
+// MakeConstant keep the full precision of the value
+func ParseConstant(name string) (gp.Primitive, error) {
+	if !strings.HasPrefix(name, "C_") {
+		return nil, fmt.Errorf("%q is not a constant", name)
+	}
+	c, err := strconv.ParseFloat(name[2:], 64)
+	if err != nil {
+		return nil, err
+	}
+	return MakeConstant(NumericOut(c)), nil
+}
+
+// Allow reg to rebuild constants generated by ephemerals
+func RegisterEphemerals(reg *gp.Registry) {
+	reg.RegisterEphemeral("C_", ParseConstant)
+}
+
 func MakeEphimeral(name string, gen func() *Primitive) *Primitive {
 	return &Primitive{name, false, -1, nil, gen, nil}
 }
@@ -0,0 +1,282 @@
+package binary
+
+// Geometric semantic operators: offspring are built combining the parents
+// through random trees, so that their semantics (the values computed on the
+// fitness cases) lie in the segment between the parents' ones, or near the
+// parent for mutations. Offspring grow exponentially if parents are copied
+// in them, so parents are replaced by references to a SemanticCache, which
+// stores their semantics and keeps the offspring small.
+
+import (
+	"fmt"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/node"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Values computed by a tree on the points of a SemanticCache
+type Semantics []NumericOut
+
+// A tree stored in the cache, with its semantics
+type semEntry struct {
+	ref  *Primitive
+	tree *node.Node
+	sem  Semantics // Nil when pruned
+	once sync.Once
+	eval func(x, y NumericIn) NumericOut // Compiled tree, for points not in cache
+}
+
+// SemanticCache stores trees and their semantics. Every stored tree has a
+// reference terminal, named GS_<id>, computing the same values of the tree
+type SemanticCache struct {
+	points  [][2]NumericIn
+	index   map[[2]NumericIn]int
+	mu      sync.Mutex
+	entries map[int]*semEntry // By id
+	nextID  int
+	refs    map[gp.Primitive]*semEntry
+}
+
+// Points where the expressions are evaluated when filling a w x h image with
+// FillMathBounds, in the same order
+func GridPoints(w, h int) [][2]NumericIn {
+	points := make([][2]NumericIn, 0, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			points = append(points, [2]NumericIn{NumericIn(float64(x) / float64(w)), NumericIn(float64(y) / float64(h))})
+		}
+	}
+	return points
+}
+
+// Build a cache storing the semantics on the given points
+func NewSemanticCache(points [][2]NumericIn) *SemanticCache {
+	c := &SemanticCache{points: points, index: make(map[[2]NumericIn]int), entries: make(map[int]*semEntry), refs: make(map[gp.Primitive]*semEntry)}
+	for i, p := range points {
+		c.index[p] = i
+	}
+	return c
+}
+
+// Number of stored semantics
+func (c *SemanticCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, e := range c.entries {
+		if e.sem != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Get the entry referenced by the terminal p, nil if p is not a reference
+func (c *SemanticCache) entry(p gp.Primitive) *semEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refs[p]
+}
+
+// Evaluate the tree on the points of the cache
+func (c *SemanticCache) Semantics(t *node.Node) Semantics {
+	if len(t.Children()) == 0 {
+		if e := c.entry(t.Value()); e != nil && e.sem != nil {
+			return e.sem
+		}
+	}
+	eval := node.CompileTree(t).(*Primitive).Eval
+	sem := make(Semantics, len(c.points))
+	for i, p := range c.points {
+		sem[i] = eval(p[0], p[1])
+	}
+	return sem
+}
+
+// Get a single node tree computing the same values of t. If t is not
+// already a reference, a copy of t is stored in the cache with its semantics
+func (c *SemanticCache) Reference(t *node.Node) *node.Node {
+	if len(t.Children()) == 0 && c.entry(t.Value()) != nil {
+		return node.New(t.Value())
+	}
+	e := &semEntry{tree: t.Copy(), sem: c.Semantics(t)}
+	c.mu.Lock()
+	id := c.nextID
+	c.nextID++
+	e.ref = &Primitive{fmt.Sprintf("GS_%v", id), false, -1, func(x, y NumericIn) NumericOut {
+		if i, ok := c.index[[2]NumericIn{x, y}]; ok && e.sem != nil {
+			return e.sem[i]
+		}
+		e.once.Do(func() {
+			e.eval = node.CompileTree(e.tree).(*Primitive).Eval
+		})
+		return e.eval(x, y)
+	}, nil, nil}
+	c.entries[id] = e
+	c.refs[e.ref] = e
+	c.mu.Unlock()
+	return node.New(e.ref)
+}
+
+// Build the full tree, replacing the references with the trees they stand
+// for. Beware that the result can be exponentially large
+func (c *SemanticCache) Expand(t *node.Node) *node.Node {
+	full, _ := c.ExpandLimit(t, -1)
+	return full
+}
+
+// Like Expand, but fails if the full tree has more than maxSize nodes
+// (negative for no limit)
+func (c *SemanticCache) ExpandLimit(t *node.Node, maxSize int) (*node.Node, error) {
+	size := 0
+	var expand func(t *node.Node) *node.Node
+	expand = func(t *node.Node) *node.Node {
+		if maxSize >= 0 && size > maxSize {
+			return nil
+		}
+		if len(t.Children()) == 0 {
+			if e := c.entry(t.Value()); e != nil {
+				return expand(e.tree)
+			}
+			size++
+			return node.New(t.Value())
+		}
+		size++
+		children := make([]*node.Node, len(t.Children()))
+		for i, ch := range t.Children() {
+			children[i] = expand(ch)
+		}
+		return node.New(t.Value(), children...)
+	}
+	full := expand(t)
+	if maxSize >= 0 && size > maxSize {
+		return nil, fmt.Errorf("expanded tree has more than %v nodes", maxSize)
+	}
+	return full, nil
+}
+
+// Drop the trees not needed by the live ones, and the semantics of the trees
+// they do not reference directly. Trees referenced by the kept trees are
+// kept too, so pruned references still work (although slower) and can be
+// expanded. Must not be called while references are evaluated
+func (c *SemanticCache) Prune(live []*node.Node) {
+	used := make(map[*semEntry]bool) // Referenced by live trees
+	kept := make(map[*semEntry]bool) // Referenced by live or kept trees
+	var visit func(t *node.Node, direct bool)
+	visit = func(t *node.Node, direct bool) {
+		nodes, _, _ := t.Enumerate()
+		for _, n := range nodes {
+			e := c.entry(n.Value())
+			if e == nil {
+				continue
+			}
+			if direct {
+				used[e] = true
+			}
+			if !kept[e] {
+				kept[e] = true
+				visit(e.tree, false)
+			}
+		}
+	}
+	for _, t := range live {
+		visit(t, true)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, e := range c.entries {
+		if !kept[e] {
+			delete(c.entries, id)
+			delete(c.refs, e.ref)
+		} else if !used[e] {
+			e.sem = nil
+		}
+	}
+}
+
+// Allow reg to resolve the references stored in the cache
+func (c *SemanticCache) RegisterReferences(reg *gp.Registry) {
+	reg.RegisterEphemeral("GS_", func(name string) (gp.Primitive, error) {
+		id, err := strconv.Atoi(strings.TrimPrefix(name, "GS_"))
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		e, ok := c.entries[id]
+		if !ok {
+			return nil, fmt.Errorf("reference %q is not in the cache", name)
+		}
+		return e.ref, nil
+	})
+}
+
+// Geometric semantic crossover and mutation
+type GeometricSemantic struct {
+	Cache *SemanticCache
+	Gen   func(maxH int) *node.Node // Generate the random trees
+	Depth int                       // Max depth of random trees
+	Step  NumericOut                // Mutation step
+
+	sum, sub, mul, lgst *Primitive
+}
+
+// Primitives combining the parents. They are shared by all the operators,
+// and can be used as functionals, so that they are registered only once
+var (
+	Sum  = MakeBinary("Sum", func(a, b NumericOut) NumericOut { return a + b })
+	Sub  = MakeBinary("Sub", func(a, b NumericOut) NumericOut { return a - b })
+	Mul  = MakeBinary("Mul", func(a, b NumericOut) NumericOut { return a * b })
+	Lgst = MakeUnary("Lgst", func(a NumericOut) NumericOut { return NumericOut(1 / (1 + math.Exp(-float64(a)))) })
+)
+
+// Build the operators, using gen to generate random trees of depth up to depth
+func NewGeometricSemantic(cache *SemanticCache, gen func(maxH int) *node.Node, depth int, step NumericOut) *GeometricSemantic {
+	return &GeometricSemantic{cache, gen, depth, step, Sum, Sub, Mul, Lgst}
+}
+
+// Primitives used to combine the parents, to be registered for loading trees
+func (g *GeometricSemantic) Primitives() []gp.Primitive {
+	return []gp.Primitive{g.sum, g.sub, g.mul, g.lgst}
+}
+
+// Random tree with values in [0, 1]
+func (g *GeometricSemantic) randomMask() *node.Node {
+	return node.New(g.lgst, g.Gen(g.Depth))
+}
+
+// Build the offspring t1 * R + t2 * (1 - R) and t2 * R + t1 * (1 - R), where
+// R is a random tree with values in [0, 1]. Parents are not modified
+func (g *GeometricSemantic) Crossover(t1, t2 *node.Node) (*node.Node, *node.Node) {
+	r1, r2 := g.Cache.Reference(t1), g.Cache.Reference(t2)
+	mask := g.randomMask()
+	mix := func(a, b *node.Node) *node.Node {
+		return node.New(g.sum,
+			node.New(g.mul, a.Copy(), mask.Copy()),
+			node.New(g.mul, b.Copy(), node.New(g.sub, node.New(MakeConstant(1)), mask.Copy())))
+	}
+	return mix(r1, r2), mix(r2, r1)
+}
+
+// Build the offspring t + Step * (R1 - R2), where R1 and R2 are random
+// trees with values in [0, 1]. The parent is not modified
+func (g *GeometricSemantic) Mutation(t *node.Node) *node.Node {
+	return node.New(g.sum, g.Cache.Reference(t),
+		node.New(g.mul, node.New(MakeConstant(g.Step)), node.New(g.sub, g.randomMask(), g.randomMask())))
+}
+
+// Drop the cached semantics not needed by the live trees, returning the
+// number of semantics still stored
+func (g *GeometricSemantic) Prune(live []*node.Node) int {
+	g.Cache.Prune(live)
+	return g.Cache.Len()
+}
+
+// Build the full tree of t, which can reference the cached parents, failing
+// if it has more than maxSize nodes
+func (g *GeometricSemantic) Expand(t *node.Node, maxSize int) (*node.Node, error) {
+	return g.Cache.ExpandLimit(t, maxSize)
+}
@@ -0,0 +1,111 @@
+package binary
+
+import (
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/node"
+	"math"
+	"testing"
+)
+
+func TestGeometricSemantic(t *testing.T) {
+	// Offspring are built with the shared Sum and Mul, so the same ones are
+	// used here: a registry rejects different primitives with the same name
+	funcs := []gp.Primitive{
+		Sum,
+		Mul,
+		MakeUnary("Neg", func(a NumericOut) NumericOut { return -a }),
+	}
+	terms := []gp.Primitive{MakeIdentityX(), MakeIdentityY(), MakeConstant(2)}
+	gen := func(maxH int) *node.Node { return node.MakeTreeHalfAndHalf(0, maxH, funcs, terms) }
+
+	cache := NewSemanticCache(GridPoints(8, 6))
+	gs := NewGeometricSemantic(cache, gen, 2, 0.1)
+
+	// Semantics of c is between the ones of a and b
+	between := func(c, a, b *node.Node) bool {
+		s, s1, s2 := cache.Semantics(c), cache.Semantics(a), cache.Semantics(b)
+		for i := range s {
+			lo, hi := math.Min(float64(s1[i]), float64(s2[i])), math.Max(float64(s1[i]), float64(s2[i]))
+			if float64(s[i]) < lo-1e-9 || float64(s[i]) > hi+1e-9 {
+				return false
+			}
+		}
+		return true
+	}
+
+	pop := make([]*node.Node, 6)
+	for i := range pop {
+		pop[i] = gen(3)
+	}
+	for g := 0; g < 8; g++ {
+		next := make([]*node.Node, 0, len(pop))
+		for i := 0; i < len(pop); i += 2 {
+			a, b := pop[i], pop[i+1]
+			c1, c2 := gs.Crossover(a, b)
+			if !between(c1, a, b) || !between(c2, a, b) {
+				t.Fatal("Crossover offspring are not between the parents", a, b)
+			}
+			m := gs.Mutation(c1)
+			sm, sc := cache.Semantics(m), cache.Semantics(c1)
+			for k := range sm {
+				if math.Abs(float64(sm[k]-sc[k])) > 0.1+1e-9 {
+					t.Fatal("Mutation moved the semantics more than the step")
+				}
+			}
+			next = append(next, m, c2)
+		}
+		pop = next
+		gs.Prune(pop)
+	}
+	// Offspring do not grow with generations
+	for _, p := range pop {
+		if node.Depth(p) > 2+2+3 {
+			t.Error("Offspring is too deep", node.Depth(p), p)
+		}
+	}
+	if n := cache.Len(); n > 2*len(pop) {
+		t.Error("Cache stores", n, "semantics after pruning, expected at most", 2*len(pop))
+	}
+
+	// References can be resolved and expanded
+	reg := gp.NewRegistry()
+	for _, prims := range [][]gp.Primitive{funcs, terms, gs.Primitives()} {
+		if err := reg.Register(prims...); err != nil {
+			t.Fatal("Cannot register primitives:", err)
+		}
+	}
+	RegisterEphemerals(reg)
+	cache.RegisterReferences(reg)
+	js, _ := pop[0].MarshalJSON()
+	back, err := node.Unmarshal(js, reg.Lookup)
+	if err != nil {
+		t.Fatal("Cannot load offspring:", err)
+	}
+	small := NewSemanticCache(GridPoints(3, 2))
+	full := small.Expand(cache.Expand(back))
+	s, e := small.Semantics(back), small.Semantics(full)
+	for i := range s {
+		if math.Abs(float64(s[i]-e[i])) > 1e-9 {
+			t.Fatal("Expanded tree computes a different value")
+		}
+	}
+	if _, err := cache.ExpandLimit(back, node.Size(full)); err != nil {
+		t.Error("Cannot expand within the size of the full tree:", err)
+	}
+	if _, err := cache.ExpandLimit(back, node.Size(full)-1); err == nil {
+		t.Error("Expected an error expanding beyond the size limit")
+	}
+
+	// Trees not needed by the live ones are dropped
+	gs.Prune(pop[:1])
+	if n := len(cache.entries); n == 0 || n >= cache.nextID {
+		t.Error("Cache keeps", n, "of", cache.nextID, "trees for a single live tree")
+	}
+	gs.Prune(nil)
+	if n := len(cache.entries); n != 0 {
+		t.Error("Cache keeps", n, "trees without live ones")
+	}
+	if _, err := reg.Lookup("GS_0"); err == nil {
+		t.Error("Expected an error loading a dropped reference")
+	}
+}
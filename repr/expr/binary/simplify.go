@@ -0,0 +1,132 @@
+package binary
+
+import (
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/node"
+	"strings"
+)
+
+// Returns true if p is a constant terminal, as built by MakeConstant
+func IsConstant(p gp.Primitive) bool {
+	c, ok := p.(*Primitive)
+	return ok && !c.functional && c.ephemeral == nil && strings.HasPrefix(c.name, "C_")
+}
+
+// Convert a compiled constant expression in a constant terminal
+func FoldConstant(p gp.Primitive) (gp.Primitive, bool) {
+	c, ok := p.(*Primitive)
+	if !ok || c.Eval == nil {
+		return nil, false
+	}
+	return MakeConstant(c.Eval(0, 0)), true
+}
+
+// Value of the constant in n, if n is a constant terminal
+func constValue(n *node.Node) (NumericOut, bool) {
+	if len(n.Children()) != 0 || !IsConstant(n.Value()) {
+		return 0, false
+	}
+	return n.Value().(*Primitive).Eval(0, 0), true
+}
+
+// Returns true if n is the constant v
+func isConst(n *node.Node, v NumericOut) bool {
+	c, ok := constValue(n)
+	return ok && c == v
+}
+
+// The i-th child of n, if the j-th child is the constant v
+func childIfConst(i, j int, v NumericOut) node.Rule {
+	return func(n *node.Node) *node.Node {
+		if isConst(n.Children()[j], v) {
+			return n.Children()[i]
+		}
+		return nil
+	}
+}
+
+// The constant c, if the j-th child is the constant v
+func constIfConst(c NumericOut, j int, v NumericOut) node.Rule {
+	return func(n *node.Node) *node.Node {
+		if isConst(n.Children()[j], v) {
+			return node.New(MakeConstant(c))
+		}
+		return nil
+	}
+}
+
+// The first child, if all the children (starting from the first) are equal
+func childIfEqual(first int) node.Rule {
+	return func(n *node.Node) *node.Node {
+		ch := n.Children()
+		for _, c := range ch[first+1:] {
+			if !node.Equal(ch[first], c) {
+				return nil
+			}
+		}
+		return ch[first]
+	}
+}
+
+// The only child of the only child, e.g. Neg(Neg(x)) -> x
+func involution(n *node.Node) *node.Node {
+	c := n.Children()[0]
+	if c.Value().Name() == n.Value().Name() {
+		return c.Children()[0]
+	}
+	return nil
+}
+
+// The only child, e.g. Abs(Abs(x)) -> Abs(x)
+func idempotent(n *node.Node) *node.Node {
+	if c := n.Children()[0]; c.Value().Name() == n.Value().Name() {
+		return c
+	}
+	return nil
+}
+
+// Build a simplifier that folds constant subtrees and applies algebraic
+// rules to the primitives with the names and semantics used by the expr
+// app (Sum, Sub, Mul, Div, Pow, Min, Max, ITE, Neg, Abs).
+// Rules Sub(x, x) -> 0, Mul(x, 0) -> 0 and Div(x, x) -> 1 do not hold when x
+// evaluates to an infinity or NaN, so they are used only if assumeFinite
+func MakeSimplifier(assumeFinite bool) *node.Simplifier {
+	s := node.NewSimplifier(IsConstant, FoldConstant)
+	s.AddRule("Sum", childIfConst(0, 1, 0), childIfConst(1, 0, 0))
+	s.AddRule("Sub", childIfConst(0, 1, 0))
+	s.AddRule("Mul", childIfConst(0, 1, 1), childIfConst(1, 0, 1))
+	// Division by zero is 1
+	s.AddRule("Div", childIfConst(0, 1, 1), constIfConst(1, 1, 0))
+	if assumeFinite {
+		s.AddRule("Sub", func(n *node.Node) *node.Node {
+			if node.Equal(n.Children()[0], n.Children()[1]) {
+				return node.New(MakeConstant(0))
+			}
+			return nil
+		})
+		s.AddRule("Mul", constIfConst(0, 0, 0), constIfConst(0, 1, 0))
+		// Being x/0 = 1, x/x is 1 for every finite x
+		s.AddRule("Div", func(n *node.Node) *node.Node {
+			if node.Equal(n.Children()[0], n.Children()[1]) {
+				return node.New(MakeConstant(1))
+			}
+			return nil
+		})
+	}
+	s.AddRule("Pow", childIfConst(0, 1, 1), constIfConst(1, 1, 0))
+	s.AddRule("Min", childIfEqual(0))
+	s.AddRule("Max", childIfEqual(0))
+	s.AddRule("ITE", childIfEqual(1), func(n *node.Node) *node.Node {
+		// Constant condition picks one branch
+		if c, ok := constValue(n.Children()[0]); ok {
+			if c >= 0 {
+				return n.Children()[1]
+			}
+			return n.Children()[2]
+		}
+		return nil
+	})
+	s.AddRule("Neg", involution)
+	s.AddRule("Abs", idempotent)
+	return s
+}
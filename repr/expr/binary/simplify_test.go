@@ -0,0 +1,76 @@
+package binary
+
+import (
+	"github.com/akiross/gogp/node"
+	"math"
+	"testing"
+)
+
+// Equal values, with NaN equal to itself
+func sameValue(a, b NumericOut) bool {
+	return a == b || a != a && b != b
+}
+
+func TestSimplify(t *testing.T) {
+	x, y := MakeIdentityX(), MakeIdentityY()
+	c := func(v NumericOut) *node.Node { return node.New(MakeConstant(v)) }
+	sum := MakeBinary("Sum", func(a, b NumericOut) NumericOut { return a + b })
+	sub := MakeBinary("Sub", func(a, b NumericOut) NumericOut { return a - b })
+	mul := MakeBinary("Mul", func(a, b NumericOut) NumericOut { return a * b })
+	div := MakeBinary("Div", func(a, b NumericOut) NumericOut {
+		if b == 0 {
+			return 1
+		}
+		return a / b
+	})
+	neg := MakeUnary("Neg", func(a NumericOut) NumericOut { return -a })
+	log := MakeUnary("Log", func(a NumericOut) NumericOut { return NumericOut(math.Log(float64(a))) })
+	ite := MakeTernary("ITE", func(a, b, c NumericOut) NumericOut {
+		if a >= 0 {
+			return b
+		}
+		return c
+	})
+	n := node.New
+
+	cases := []struct {
+		tree   *node.Node
+		exp    string
+		finite bool // Assume finite values
+	}{
+		// Constant folding
+		{n(sum, c(1), n(mul, c(2), c(3))), "T{C_7}", false},
+		// Sub(x, x) and Mul(c, 0)
+		{n(sum, n(y), n(sub, n(x), n(x))), "T{IdY}", true},
+		{n(mul, n(sum, n(x), n(y)), c(0)), "T{C_0}", true},
+		{n(div, n(neg, n(x)), n(neg, n(x))), "T{C_1}", true},
+		// but not when values can be infinite or NaN, e.g. Log(0)
+		{n(sub, n(log, n(x)), n(log, n(x))), "F{Sub}(F{Log}(T{IdX}), F{Log}(T{IdX}))", false},
+		{n(mul, n(log, n(x)), c(0)), "F{Mul}(F{Log}(T{IdX}), T{C_0})", false},
+		{n(div, n(log, n(x)), n(log, n(x))), "F{Div}(F{Log}(T{IdX}), F{Log}(T{IdX}))", false},
+		{n(mul, c(NumericOut(math.Inf(1))), c(0)), "T{C_NaN}", false},
+		{n(mul, c(1), n(neg, n(neg, n(x)))), "T{IdX}", false},
+		// Rules apply after folding
+		{n(sum, n(x), n(sub, c(2), c(2))), "T{IdX}", false},
+		{n(ite, c(-1), n(x), n(mul, n(y), c(1))), "T{IdY}", false},
+		// Nothing to do
+		{n(sum, n(x), n(y)), "F{Sum}(T{IdX}, T{IdY})", false},
+	}
+	for _, cs := range cases {
+		before := cs.tree.String()
+		simp := MakeSimplifier(cs.finite).Simplify(cs.tree)
+		if simp.String() != cs.exp {
+			t.Error("Simplifying", before, "expected", cs.exp, "got", simp)
+		}
+		if cs.tree.String() != before {
+			t.Error("Simplify modified the original tree", before)
+		}
+		// Semantics is preserved
+		f, g := node.CompileTree(cs.tree).(*Primitive), node.CompileTree(simp).(*Primitive)
+		for _, p := range [][2]NumericIn{{0, 0}, {0.5, -1}, {3, 2}} {
+			if !sameValue(f.Eval(p[0], p[1]), g.Eval(p[0], p[1])) {
+				t.Error("Simplified", before, "computes a different value in", p)
+			}
+		}
+	}
+}
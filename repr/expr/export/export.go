@@ -0,0 +1,287 @@
+// Package export writes evolved expression trees as source code of
+// standalone functions, so they can be used without the gogp runtime.
+// Operators have the semantics of the primitives defined in
+// apps/base/repr/expr, e.g. Div returns 1 when dividing by zero and ITE
+// picks its second argument when the first one is not negative
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/node"
+	"github.com/akiross/gogp/repr/expr/binary"
+	"github.com/akiross/gogp/repr/expr/unary"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Arity of the operators that can be exported
+var arity = map[string]int{
+	"ITE": 3,
+	"Sum": 2, "Sub": 2, "Mul": 2, "Div": 2, "Min": 2, "Max": 2, "Pow": 2,
+	"Sqr": 1, "Sqrt": 1, "Abs": 1, "Neg": 1, "Sign": 1, "Sin": 1, "Cos": 1, "Tanh": 1, "Lgst": 1,
+}
+
+// Operators written with infix notation in every language
+var infix = map[string]string{"Sum": "+", "Sub": "-", "Mul": "*"}
+
+// A Resolver tells which operator is computed by a primitive, using the names
+// of apps/base/repr/expr. Variables are IdX and IdY, and constants are named
+// C, with their value in c
+type Resolver func(p gp.Primitive) (op string, c float64, err error)
+
+// Resolve primitives of repr/expr/binary by their names
+func Binary(p gp.Primitive) (string, float64, error) {
+	name := p.Name()
+	if _, ok := arity[name]; ok || name == "IdX" || name == "IdY" {
+		return name, 0, nil
+	}
+	if strings.HasPrefix(name, "GS_") {
+		return "", 0, fmt.Errorf("semantic reference %q must be expanded first", name)
+	}
+	if c, err := binary.ParseConstant(name); err == nil {
+		return "C", float64(c.(*binary.Primitive).Eval(0, 0)), nil
+	}
+	return "", 0, fmt.Errorf("unknown primitive %q", name)
+}
+
+// Resolve primitives of repr/expr/unary, whose only variable is IdX
+func Unary(p gp.Primitive) (string, float64, error) {
+	switch name := p.Name(); name {
+	case "Sum", "Sub", "Mul", "Abs":
+		return name, 0, nil
+	case "ProtectedDiv":
+		return "Div", 0, nil
+	case "Square":
+		return "Sqr", 0, nil
+	case "Identity":
+		return "IdX", 0, nil
+	}
+	if t, ok := p.(unary.Terminal); ok && unary.IsConstant(t) {
+		return "C", float64(t(0)), nil
+	}
+	return "", 0, fmt.Errorf("unknown primitive %q", p.Name())
+}
+
+// A Language writes the expressions as functions
+type Language struct {
+	helpers map[string]string      // Code of the helper function computing each operator
+	helper  func(op string) string // Name of the helper function of op
+	number  func(v float64) string
+	// Write the source file with a function name(vars...) returning body
+	file func(name string, vars []string, helpers []string, body string) string
+}
+
+// Helpers named like op_div, for C and Python
+func snakeHelper(op string) string {
+	return "op_" + strings.ToLower(op)
+}
+
+// Number literal, with ok false if v is not finite
+func literal(v float64) (string, bool) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "", false
+	}
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if math.Signbit(v) {
+		s = "(" + s + ")"
+	}
+	return s, true
+}
+
+// Go source of package pkg, e.g. func F(x, y float64) float64
+func GoPackage(pkg string) *Language {
+	return &Language{
+		helper: func(op string) string { return "op" + op },
+		helpers: map[string]string{
+			"ITE":  "func opITE(a, b, c float64) float64 {\n\tif a >= 0 {\n\t\treturn b\n\t}\n\treturn c\n}",
+			"Div":  "func opDiv(a, b float64) float64 {\n\tif b == 0 {\n\t\treturn 1\n\t}\n\treturn a / b\n}",
+			"Min":  "func opMin(a, b float64) float64 {\n\tif a < b {\n\t\treturn a\n\t}\n\treturn b\n}",
+			"Max":  "func opMax(a, b float64) float64 {\n\tif a > b {\n\t\treturn a\n\t}\n\treturn b\n}",
+			"Pow":  "func opPow(a, b float64) float64 {\n\treturn math.Pow(a, b)\n}",
+			"Sqr":  "func opSqr(a float64) float64 {\n\treturn a * a\n}",
+			"Sqrt": "func opSqrt(a float64) float64 {\n\tif a < 0 {\n\t\treturn math.Sqrt(-a)\n\t}\n\treturn math.Sqrt(a)\n}",
+			"Abs":  "func opAbs(a float64) float64 {\n\tif a < 0 {\n\t\treturn -a\n\t}\n\treturn a\n}",
+			"Neg":  "func opNeg(a float64) float64 {\n\treturn -a\n}",
+			"Sign": "func opSign(a float64) float64 {\n\tif a < 0 {\n\t\treturn -1\n\t}\n\treturn 1\n}",
+			"Sin":  "func opSin(a float64) float64 {\n\treturn math.Sin(a)\n}",
+			"Cos":  "func opCos(a float64) float64 {\n\treturn math.Cos(a)\n}",
+			"Tanh": "func opTanh(a float64) float64 {\n\treturn math.Tanh(a)\n}",
+			"Lgst": "func opLgst(a float64) float64 {\n\treturn 1 / (1 + math.Exp(-a))\n}",
+		},
+		number: func(v float64) string {
+			if s, ok := literal(v); ok {
+				return s
+			}
+			if math.IsNaN(v) {
+				return "math.NaN()"
+			}
+			return fmt.Sprintf("math.Inf(%d)", int(math.Copysign(1, v)))
+		},
+		file: func(name string, vars []string, helpers []string, body string) string {
+			var b bytes.Buffer
+			fmt.Fprintf(&b, "package %s\n\n", pkg)
+			code := strings.Join(helpers, "\n\n") + body
+			if strings.Contains(code, "math.") {
+				b.WriteString("import \"math\"\n\n")
+			}
+			for _, h := range helpers {
+				b.WriteString(h + "\n\n")
+			}
+			fmt.Fprintf(&b, "func %s(%s float64) float64 {\n\treturn %s\n}\n", name, strings.Join(vars, ", "), body)
+			return b.String()
+		},
+	}
+}
+
+// Go source of package expr
+var Go = GoPackage("expr")
+
+// C source, e.g. double f(double x, double y), to be linked with -lm
+var C = &Language{
+	helper: snakeHelper,
+	helpers: map[string]string{
+		"ITE":  "static double op_ite(double a, double b, double c) { return a >= 0 ? b : c; }",
+		"Div":  "static double op_div(double a, double b) { return b == 0 ? 1 : a / b; }",
+		"Min":  "static double op_min(double a, double b) { return a < b ? a : b; }",
+		"Max":  "static double op_max(double a, double b) { return a > b ? a : b; }",
+		"Pow":  "static double op_pow(double a, double b) { return pow(a, b); }",
+		"Sqr":  "static double op_sqr(double a) { return a * a; }",
+		"Sqrt": "static double op_sqrt(double a) { return a < 0 ? sqrt(-a) : sqrt(a); }",
+		"Abs":  "static double op_abs(double a) { return a < 0 ? -a : a; }",
+		"Neg":  "static double op_neg(double a) { return -a; }",
+		"Sign": "static double op_sign(double a) { return a < 0 ? -1 : 1; }",
+		"Sin":  "static double op_sin(double a) { return sin(a); }",
+		"Cos":  "static double op_cos(double a) { return cos(a); }",
+		"Tanh": "static double op_tanh(double a) { return tanh(a); }",
+		"Lgst": "static double op_lgst(double a) { return 1 / (1 + exp(-a)); }",
+	},
+	number: func(v float64) string {
+		if s, ok := literal(v); ok {
+			return s
+		}
+		if math.IsNaN(v) {
+			return "NAN"
+		}
+		if v < 0 {
+			return "(-INFINITY)"
+		}
+		return "INFINITY"
+	},
+	file: func(name string, vars []string, helpers []string, body string) string {
+		var b bytes.Buffer
+		b.WriteString("#include <math.h>\n\n")
+		for _, h := range helpers {
+			b.WriteString(h + "\n")
+		}
+		if len(helpers) > 0 {
+			b.WriteString("\n")
+		}
+		params := make([]string, len(vars))
+		for i, v := range vars {
+			params[i] = "double " + v
+		}
+		fmt.Fprintf(&b, "double %s(%s) {\n\treturn %s;\n}\n", name, strings.Join(params, ", "), body)
+		return b.String()
+	},
+}
+
+// Python source, e.g. def f(x, y). Math functions that raise exceptions on
+// values that Go accepts are wrapped to return the same values of Go
+var Python = &Language{
+	helper: snakeHelper,
+	helpers: map[string]string{
+		"ITE":  "def op_ite(a, b, c):\n    return b if a >= 0 else c",
+		"Div":  "def op_div(a, b):\n    return 1.0 if b == 0 else a / b",
+		"Min":  "def op_min(a, b):\n    return a if a < b else b",
+		"Max":  "def op_max(a, b):\n    return a if a > b else b",
+		"Pow":  "def op_pow(a, b):\n    try:\n        return math.pow(a, b)\n    except OverflowError:\n        return -math.inf if a < 0 and b % 2 == 1 else math.inf\n    except ValueError:\n        return math.inf if a == 0 else math.nan",
+		"Sqr":  "def op_sqr(a):\n    return a * a",
+		"Sqrt": "def op_sqrt(a):\n    return math.sqrt(-a) if a < 0 else math.sqrt(a)",
+		"Abs":  "def op_abs(a):\n    return -a if a < 0 else a",
+		"Neg":  "def op_neg(a):\n    return -a",
+		"Sign": "def op_sign(a):\n    return -1.0 if a < 0 else 1.0",
+		"Sin":  "def op_sin(a):\n    return math.sin(a) if math.isfinite(a) else math.nan",
+		"Cos":  "def op_cos(a):\n    return math.cos(a) if math.isfinite(a) else math.nan",
+		"Tanh": "def op_tanh(a):\n    return math.tanh(a)",
+		"Lgst": "def op_lgst(a):\n    try:\n        return 1 / (1 + math.exp(-a))\n    except OverflowError:\n        return 0.0",
+	},
+	number: func(v float64) string {
+		if s, ok := literal(v); ok {
+			return s
+		}
+		if math.IsNaN(v) {
+			return "math.nan"
+		}
+		if v < 0 {
+			return "(-math.inf)"
+		}
+		return "math.inf"
+	},
+	file: func(name string, vars []string, helpers []string, body string) string {
+		var b bytes.Buffer
+		b.WriteString("import math\n\n\n")
+		for _, h := range helpers {
+			b.WriteString(h + "\n\n\n")
+		}
+		fmt.Fprintf(&b, "def %s(%s):\n    return %s\n", name, strings.Join(vars, ", "), body)
+		return b.String()
+	},
+}
+
+// Expression computing t, recording the operators used
+func (l *Language) expr(t *node.Node, r Resolver, vars []string, used map[string]bool) (string, error) {
+	op, c, err := r(t.Value())
+	if err != nil {
+		return "", err
+	}
+	switch op {
+	case "C":
+		return l.number(c), nil
+	case "IdX", "IdY":
+		i := map[string]int{"IdX": 0, "IdY": 1}[op]
+		if i >= len(vars) {
+			return "", fmt.Errorf("variable %s is not available", op)
+		}
+		return vars[i], nil
+	}
+	ch := t.Children()
+	if len(ch) != arity[op] {
+		return "", fmt.Errorf("operator %s has %d arguments instead of %d", op, len(ch), arity[op])
+	}
+	args := make([]string, len(ch))
+	for i := range ch {
+		if args[i], err = l.expr(ch[i], r, vars, used); err != nil {
+			return "", err
+		}
+	}
+	if sym, ok := infix[op]; ok {
+		return "(" + args[0] + " " + sym + " " + args[1] + ")", nil
+	}
+	used[op] = true
+	return l.helper(op) + "(" + strings.Join(args, ", ") + ")", nil
+}
+
+// Write t as the source of a function called name, taking vars as arguments
+// (x and y for binary expressions) and using r to recognize the primitives.
+// The helper functions implementing the operators are written as well
+func (l *Language) Export(t *node.Node, r Resolver, name string, vars ...string) (string, error) {
+	used := make(map[string]bool)
+	body, err := l.expr(t, r, vars, used)
+	if err != nil {
+		return "", err
+	}
+	var ops []string
+	for op := range used {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	helpers := make([]string, len(ops))
+	for i, op := range ops {
+		helpers[i] = l.helpers[op]
+	}
+	return l.file(name, vars, helpers, body), nil
+}
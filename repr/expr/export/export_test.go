@@ -0,0 +1,194 @@
+package export
+
+import (
+	"fmt"
+	"github.com/akiross/gogp/apps/base/repr/expr"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/node"
+	"github.com/akiross/gogp/repr/expr/binary"
+	"github.com/akiross/gogp/repr/expr/unary"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var points = []float64{-1.5, -0.3, 0, 0.7, 2}
+
+// Programs printing the value of f on the points, one per line
+var mains = map[string]string{
+	"go": `package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func main() {
+	for _, x := range []float64{%[1]s} {
+		for _, y := range []float64{%[1]s} {
+			fmt.Println(strconv.FormatFloat(f(x, y), 'g', -1, 64))
+		}
+	}
+}
+`,
+	"c": `#include <stdio.h>
+
+int main() {
+	double p[] = {%[1]s};
+	for (int i = 0; i < %[2]d; i++)
+		for (int j = 0; j < %[2]d; j++)
+			printf("%%.17g\n", f(p[i], p[j]));
+	return 0;
+}
+`,
+	"python": `
+for x in [%[1]s]:
+    for y in [%[1]s]:
+        print(repr(float(f(x, y))))
+`,
+}
+
+// Compile and run the exported source of f, returning the printed values
+func run(t *testing.T, lang, src string) []float64 {
+	dir := t.TempDir()
+	var ps []string
+	for _, p := range points {
+		ps = append(ps, fmt.Sprint(p))
+	}
+	main := fmt.Sprintf(mains[lang], strings.Join(ps, ", "), len(points))
+	var cmd *exec.Cmd
+	switch lang {
+	case "go":
+		ioutil.WriteFile(filepath.Join(dir, "f.go"), []byte(src), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(main), 0644)
+		cmd = exec.Command("go", "run", "f.go", "main.go")
+		cmd.Env = append(os.Environ(), "GOFLAGS=", "GO111MODULE=off")
+	case "c":
+		ioutil.WriteFile(filepath.Join(dir, "f.c"), []byte(src+main), 0644)
+		if out, err := exec.Command("cc", "-o", filepath.Join(dir, "f"), filepath.Join(dir, "f.c"), "-lm").CombinedOutput(); err != nil {
+			t.Fatal("Cannot compile C source:", err, string(out), src)
+		}
+		cmd = exec.Command(filepath.Join(dir, "f"))
+	case "python":
+		ioutil.WriteFile(filepath.Join(dir, "f.py"), []byte(src+main), 0644)
+		cmd = exec.Command("python3", "f.py")
+	}
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatal("Cannot run", lang, "source:", err, string(out), src)
+	}
+	var vals []float64
+	for _, line := range strings.Fields(string(out)) {
+		v, err := strconv.ParseFloat(line, 64)
+		if strings.HasSuffix(line, "nan") { // C may print -nan
+			v, err = math.NaN(), nil
+		}
+		if err != nil {
+			t.Fatal("Cannot parse output", line)
+		}
+		vals = append(vals, v)
+	}
+	return vals
+}
+
+func same(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return a == b
+	}
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Abs(a))
+}
+
+func TestExportBinary(t *testing.T) {
+	rand.Seed(5)
+	expr.AddDefaultPrimitives()
+	vars := []gp.Primitive{binary.MakeIdentityX(), binary.MakeIdentityY(), binary.MakeConstant(-0.25), binary.MakeConstant(2)}
+	// Logistic of the geometric semantic operators
+	funcs := expr.Functionals
+	for _, p := range binary.NewGeometricSemantic(binary.NewSemanticCache(nil), nil, 0, 0).Primitives() {
+		if p.Name() == "Lgst" {
+			funcs = append(funcs, p)
+		}
+	}
+	// Every operator on random arguments, and some random trees
+	var trees []*node.Node
+	for _, f := range funcs {
+		args := make([]*node.Node, f.Arity())
+		for i := range args {
+			args[i] = node.MakeTreeHalfAndHalf(0, 2, expr.Functionals, vars)
+		}
+		trees = append(trees, node.New(f, args...))
+	}
+	for i := 0; i < 3; i++ {
+		trees = append(trees, node.MakeTreeHalfAndHalf(0, 5, expr.Functionals, append(vars, expr.Terminals...)))
+	}
+
+	langs := map[string]*Language{"go": GoPackage("main"), "c": C, "python": Python}
+	tools := map[string]string{"go": "go", "c": "cc", "python": "python3"}
+	for name, lang := range langs {
+		if _, err := exec.LookPath(tools[name]); err != nil {
+			t.Log("Skipping", name, "source, cannot find", tools[name])
+			continue
+		}
+		for i, tree := range trees {
+			// Go sources are slow to build, check only the random trees
+			if name == "go" && i < len(funcs) {
+				continue
+			}
+			src, err := lang.Export(tree, Binary, "f", "x", "y")
+			if err != nil {
+				t.Fatal("Cannot export", tree, err)
+			}
+			eval := node.CompileTree(tree).(*binary.Primitive).Eval
+			vals := run(t, name, src)
+			k := 0
+			for _, x := range points {
+				for _, y := range points {
+					if want := float64(eval(binary.NumericIn(x), binary.NumericIn(y))); !same(vals[k], want) {
+						t.Fatalf("%s function computes %v in (%v, %v), expected %v\n%s", name, vals[k], x, y, want, src)
+					}
+					k++
+				}
+			}
+		}
+	}
+
+	gs := node.New(&binary.Primitive{}) // Unnamed primitive
+	if _, err := Python.Export(gs, Binary, "f", "x", "y"); err == nil {
+		t.Error("Unknown primitives should not be exported")
+	}
+}
+
+func TestExportUnary(t *testing.T) {
+	funcs := []gp.Primitive{unary.Functional2(unary.Sum), unary.Functional2(unary.ProtectedDiv), unary.Functional1(unary.Square)}
+	x := node.New(unary.Terminal(unary.Identity))
+	tree := node.New(funcs[0], node.New(funcs[2], x), node.New(funcs[1], x, node.New(unary.Constant1(3))))
+	src, err := C.Export(tree, Unary, "f", "x")
+	if err != nil {
+		t.Fatal("Cannot export", err)
+	}
+	if !strings.Contains(src, "return (op_sqr(x) + op_div(x, 3));") {
+		t.Error("Wrong C source\n", src)
+	}
+	if _, err := C.Export(tree, Unary, "f"); err == nil {
+		t.Error("Variables must be declared")
+	}
+	eval := node.CompileTree(tree).(unary.Terminal)
+	if v := eval(2); v != 4+2.0/3 {
+		t.Error("Unary tree computes", v)
+	}
+	// Other terminals are not constants
+	sqr := node.New(unary.Terminal(func(x unary.NumericIn) unary.NumericOut { return unary.NumericOut(x * x) }))
+	if _, err := C.Export(node.New(funcs[0], x, sqr), Unary, "f", "x"); err == nil {
+		t.Error("Unknown terminals should not be exported as constants")
+	}
+}
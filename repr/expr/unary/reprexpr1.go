@@ -1,6 +1,11 @@
 package unary
 
-import "github.com/akiross/gogp/gp"
+import (
+	"github.com/akiross/gogp/gp"
+	"reflect"
+	"runtime"
+	"strings"
+)
 
 type NumericIn int
 type NumericOut float64
@@ -10,29 +15,44 @@ type Terminal func(x NumericIn) NumericOut // For expressions in 1 variable
 type Functional1 func(args ...gp.Primitive) gp.Primitive // Unary operations
 type Functional2 func(args ...gp.Primitive) gp.Primitive // Binary operations
 
-// The following are to satisfy the interface
+// The following are to satisfy the interface. Names are the ones of the
+// wrapped functions, e.g. Sum or Identity, closures are named func1, func2...
 func (self Terminal) IsFunctional() bool                 { return false }
+func (self Terminal) IsEphemeral() bool                  { return false }
 func (self Terminal) Arity() int                         { return -1 }
 func (self Terminal) Run(p ...gp.Primitive) gp.Primitive { return self }
+func (self Terminal) Name() string                       { return gp.FuncName(self) }
 
 func (self Functional1) IsFunctional() bool                 { return true }
+func (self Functional1) IsEphemeral() bool                  { return false }
 func (self Functional1) Arity() int                         { return 1 }
 func (self Functional1) Run(p ...gp.Primitive) gp.Primitive { return self(p[0]) }
+func (self Functional1) Name() string                       { return gp.FuncName(self) }
 
 func (self Functional2) IsFunctional() bool                 { return true }
+func (self Functional2) IsEphemeral() bool                  { return false }
 func (self Functional2) Arity() int                         { return 2 }
 func (self Functional2) Run(p ...gp.Primitive) gp.Primitive { return self(p[0], p[1]) }
+func (self Functional2) Name() string                       { return gp.FuncName(self) }
 
 func Identity(x NumericIn) NumericOut {
-	return x
+	return NumericOut(x)
 }
 
-func Constant1(c NumericIn) Terminal {
+func Constant1(c NumericOut) Terminal {
 	return func(_ NumericIn) NumericOut {
 		return c
 	}
 }
 
+// Returns true if t is a constant built by Constant1. Closures have no
+// names, so the one of their code is checked: when Constant1 is inlined,
+// the closure is named after the caller, followed by Constant1.func1
+func IsConstant(t Terminal) bool {
+	name := runtime.FuncForPC(reflect.ValueOf(t).Pointer()).Name()
+	return strings.HasSuffix(name, ".Constant1.func1")
+}
+
 func Sum(args ...gp.Primitive) gp.Primitive {
 	return Terminal(func(x NumericIn) NumericOut {
 		return args[0].(Terminal)(x) + args[1].(Terminal)(x)
@@ -54,7 +74,7 @@ func Mul(args ...gp.Primitive) gp.Primitive {
 func ProtectedDiv(args ...gp.Primitive) gp.Primitive {
 	return Terminal(func(x NumericIn) NumericOut {
 		n, d := args[0].(Terminal)(x), args[1].(Terminal)(x)
-		if d == NumericCout(0) {
+		if d == NumericOut(0) {
 			return NumericOut(1)
 		} else {
 			return n / d
@@ -15,6 +15,10 @@ func (self Terminal) IsFunctional() bool {
 	return false
 }
 
+func (self Terminal) IsEphemeral() bool {
+	return false
+}
+
 func (self Terminal) Arity() int {
 	return -1 // Not used
 }
@@ -31,6 +35,10 @@ func (self Functional) IsFunctional() bool {
 	return true
 }
 
+func (self Functional) IsEphemeral() bool {
+	return false
+}
+
 func (self Functional) Arity() int {
 	return 2
 }
@@ -40,7 +48,26 @@ func (self Functional) Run(p ...gp.Primitive) gp.Primitive {
 }
 
 func (self Functional) Name() string {
-	return "Functional"
+	return gp.FuncName(self)
+}
+
+// Terminals are closures, so they need a name to be told apart
+type NamedTerminal struct {
+	Terminal
+	name string
+}
+
+// Give a name to t, unique among the primitives used
+func Named(name string, t Terminal) *NamedTerminal {
+	return &NamedTerminal{t, name}
+}
+
+func (self *NamedTerminal) Name() string {
+	return self.name
+}
+
+func (self *NamedTerminal) Run(p ...gp.Primitive) gp.Primitive {
+	return self.Terminal
 }
 
 // Buils a Terminal that fills the entire area with given color
@@ -0,0 +1,367 @@
+package glsl
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/image/draw2d/imgut"
+	"github.com/akiross/gogp/node"
+	"github.com/akiross/gogp/repr/split/vhs"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A Fill is the translation of a terminal, coloring a rectangle of the image.
+// Coordinates are normalized in [0, 1], with the origin in the top left corner
+// like in the CPU renderers, and refer to the top left corner of the pixels
+type Fill interface {
+	// GLSL expression of type vec3, the color at point p in [x1, x2] x [y1, y2]
+	Code(x1, y1, x2, y2 float64) string
+	// The color at point (x, y), computed as the GLSL code does
+	Color(x, y, x1, y1, x2, y2 float64) [3]float64
+}
+
+// Float literal, with a decimal point as required by GLSL ES
+func lit(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEnN") {
+		s += ".0"
+	}
+	return s
+}
+
+// Functions used by the fills, like the ones of imgut and repr/rr
+const prelude = `
+// Linear shading from s to e, like imgut.LinearShade
+vec3 linear(vec2 p, vec2 size, vec2 s, vec2 e, float a, float b) {
+	vec2 d = e - s;
+	float c1 = dot(d, s);
+	float c2 = dot(d, e);
+	float c = dot(d, p / size);
+	if (c <= c1)
+		return vec3(a);
+	if (c >= c2)
+		return vec3(b);
+	return vec3((a * (c2 - c) + b * (c - c1)) / (c2 - c1));
+}
+
+// Point p relative to the rectangle lim (x1, y1, x2, y2)
+vec2 local(vec2 p, vec4 lim) {
+	return (p - lim.xy) / (lim.zw - lim.xy);
+}
+
+// Triangle above the main diagonal or below the anti diagonal, like rr.DiagShade
+vec3 diagonal(vec2 p, vec4 lim, bool mainDiag, float a, float b) {
+	vec2 q = local(p, lim);
+	bool inside = mainDiag ? q.x >= q.y : q.x + q.y >= 1.0;
+	return vec3(inside ? b : a);
+}
+
+// Band along the main or anti diagonal, like rr.DiagLine
+vec3 line(vec2 p, vec4 lim, bool mainDiag, float w, float a, float b) {
+	vec2 q = local(p, lim);
+	float d = mainDiag ? abs(q.x - q.y) : abs(q.x + q.y - 1.0);
+	return vec3(d <= w ? b : a);
+}
+`
+
+func vec4(x1, y1, x2, y2 float64) string {
+	return fmt.Sprintf("vec4(%s, %s, %s, %s)", lit(x1), lit(y1), lit(x2), lit(y2))
+}
+
+// A solid RGB color
+type Solid [3]float64
+
+func Gray(c float64) Solid {
+	return Solid{c, c, c}
+}
+
+func (s Solid) Code(x1, y1, x2, y2 float64) string {
+	return fmt.Sprintf("vec3(%s, %s, %s)", lit(s[0]), lit(s[1]), lit(s[2]))
+}
+
+func (s Solid) Color(x, y, x1, y1, x2, y2 float64) [3]float64 {
+	return s
+}
+
+// Linear shading between two gray levels, see imgut.LinearShade
+type Linear struct {
+	Start, End     float64
+	SX, SY, EX, EY float64
+}
+
+func (l Linear) Code(x1, y1, x2, y2 float64) string {
+	return fmt.Sprintf("linear(p, vec2(%s, %s), vec2(%s, %s), vec2(%s, %s), %s, %s)",
+		lit(x2-x1), lit(y2-y1), lit(l.SX), lit(l.SY), lit(l.EX), lit(l.EY), lit(l.Start), lit(l.End))
+}
+
+func (l Linear) Color(x, y, x1, y1, x2, y2 float64) [3]float64 {
+	xd, yd := l.EX-l.SX, l.EY-l.SY
+	c1, c2 := xd*l.SX+yd*l.SY, xd*l.EX+yd*l.EY
+	c := xd*x/(x2-x1) + yd*y/(y2-y1)
+	switch {
+	case c <= c1:
+		return Gray(l.Start)
+	case c >= c2:
+		return Gray(l.End)
+	}
+	return Gray((l.Start*(c2-c) + l.End*(c-c1)) / (c2 - c1))
+}
+
+// A gray background with a triangle of another gray, above the main diagonal
+// (from top left to bottom right) or below the anti diagonal, see rr.DiagShade
+type Diagonal struct {
+	Back, Fore float64
+	Main       bool
+}
+
+func (d Diagonal) Code(x1, y1, x2, y2 float64) string {
+	return fmt.Sprintf("diagonal(p, %s, %t, %s, %s)", vec4(x1, y1, x2, y2), d.Main, lit(d.Back), lit(d.Fore))
+}
+
+func (d Diagonal) Color(x, y, x1, y1, x2, y2 float64) [3]float64 {
+	qx, qy := (x-x1)/(x2-x1), (y-y1)/(y2-y1)
+	if d.Main && qx >= qy || !d.Main && qx+qy >= 1 {
+		return Gray(d.Fore)
+	}
+	return Gray(d.Back)
+}
+
+// A gray background with a band of another gray along the main or the anti
+// diagonal. The band is Width wide, relative to the sides, see rr.DiagLine
+type Line struct {
+	Back, Fore, Width float64
+	Main              bool
+}
+
+func (l Line) Code(x1, y1, x2, y2 float64) string {
+	return fmt.Sprintf("line(p, %s, %t, %s, %s, %s)", vec4(x1, y1, x2, y2), l.Main, lit(l.Width*0.5), lit(l.Back), lit(l.Fore))
+}
+
+func (l Line) Color(x, y, x1, y1, x2, y2 float64) [3]float64 {
+	qx, qy := (x-x1)/(x2-x1), (y-y1)/(y2-y1)
+	d := math.Abs(qx + qy - 1)
+	if l.Main {
+		d = math.Abs(qx - qy)
+	}
+	if d <= l.Width*0.5 {
+		return Gray(l.Fore)
+	}
+	return Gray(l.Back)
+}
+
+// A Dialect recognizes the primitives of a representation
+type Dialect struct {
+	// Tell if a functional splits the area vertically (left and right) or
+	// horizontally (above and below)
+	Split func(p gp.Primitive) (vertical bool, err error)
+	// Translate a terminal
+	Fill func(p gp.Primitive) (Fill, error)
+}
+
+// Trees of repr/rr, using the terminals built by apps/base/repr/rr
+var RR = Dialect{split, rrFill}
+
+// Trees of repr/split/vhs with solid terminals
+var VHS = Dialect{split, vhsFill}
+
+// Both representations name their functionals VSplit and HSplit
+func split(p gp.Primitive) (bool, error) {
+	switch name := p.Name(); name {
+	case "VSplit":
+		return true, nil
+	case "HSplit":
+		return false, nil
+	default:
+		return false, fmt.Errorf("unknown functional %q", name)
+	}
+}
+
+// Terminals are recognized by their names, that encode their parameters
+func rrFill(p gp.Primitive) (Fill, error) {
+	name := p.Name()
+	var f Fill
+	var c, k, s int
+	switch {
+	case name == "Black":
+		f = Gray(0)
+	case name == "White":
+		f = Gray(1)
+	case strings.HasPrefix(name, "G"):
+		if _, err := fmt.Sscanf(name, "G%X", &c); err == nil && fmt.Sprintf("G%02X", c) == name {
+			f = Gray(float64(c) / 255)
+		}
+	case strings.HasPrefix(name, "T_"):
+		if _, err := fmt.Sscanf(name, "T_%d", &c); err == nil && fmt.Sprintf("T_%d", c) == name {
+			f = Gray(float64(c) / 255)
+		}
+	case strings.HasPrefix(name, "EPH_"):
+		var sx, sy, ex, ey int
+		if _, err := fmt.Sscanf(name, "EPH_%x-%x_%d-%d_%d-%d", &c, &k, &sx, &sy, &ex, &ey); err == nil &&
+			fmt.Sprintf("EPH_%x-%x_%d-%d_%d-%d", c, k, sx, sy, ex, ey) == name {
+			f = Linear{float64(c) / 255, float64(k) / 255, float64(sx) / 100, float64(sy) / 100, float64(ex) / 100, float64(ey) / 100}
+		}
+	case strings.HasPrefix(name, "L_"):
+		var sx, sy, ex, ey int
+		if _, err := fmt.Sscanf(name, "L_%d-%d_%d-%d_%d-%d", &c, &k, &sx, &sy, &ex, &ey); err == nil &&
+			fmt.Sprintf("L_%d-%d_%d-%d_%d-%d", c, k, sx, sy, ex, ey) == name {
+			f = Linear{float64(c) / 255, float64(k) / 255, float64(sx) / 100, float64(sy) / 100, float64(ex) / 100, float64(ey) / 100}
+		}
+	case strings.HasPrefix(name, "Df_"), strings.HasPrefix(name, "dF_"):
+		if _, err := fmt.Sscanf(name[3:], "%x-%x", &c, &k); err == nil && fmt.Sprintf("%s%x-%x", name[:3], c, k) == name {
+			f = Diagonal{float64(c) / 255, float64(k) / 255, name[0] == 'D'}
+		}
+	case strings.HasPrefix(name, "Dl_"), strings.HasPrefix(name, "dL_"):
+		if _, err := fmt.Sscanf(name[3:], "%x_%x-%x", &s, &c, &k); err == nil && fmt.Sprintf("%s%x_%x-%x", name[:3], s, c, k) == name {
+			f = Line{float64(c) / 255, float64(k) / 255, float64(s/15) / 15, name[0] == 'D'}
+		}
+	}
+	if f == nil {
+		return nil, fmt.Errorf("terminal %q cannot be translated", name)
+	}
+	return f, nil
+}
+
+// Terminals are closures, their color is found drawing them on a small image
+func vhsFill(p gp.Primitive) (Fill, error) {
+	var t vhs.Terminal
+	switch v := p.(type) {
+	case vhs.Terminal:
+		t = v
+	case *vhs.NamedTerminal:
+		t = v.Terminal
+	default:
+		return nil, fmt.Errorf("%T is not a vhs terminal", p)
+	}
+	const size = 4
+	img := imgut.Create(size, size, imgut.MODE_RGB)
+	t(0, 0, size, size, img)
+	first := img.Surf.At(0, 0)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if img.Surf.At(x, y) != first {
+				return nil, fmt.Errorf("terminal is not a solid color")
+			}
+		}
+	}
+	r, g, b, _ := first.RGBA()
+	return Solid{float64(r>>8) / 255, float64(g>>8) / 255, float64(b>>8) / 255}, nil
+}
+
+// A Shader is the translation of a tree, that can be written as GLSL or
+// rasterized on the CPU to check the translation
+type Shader struct {
+	vertical bool
+	sub      [2]*Shader // Nil for terminals
+	fill     Fill
+}
+
+// Translate a tree of splits, using d to recognize its primitives
+func Translate(t *node.Node, d Dialect) (*Shader, error) {
+	p := t.Value()
+	if !p.IsFunctional() {
+		f, err := d.Fill(p)
+		if err != nil {
+			return nil, err
+		}
+		return &Shader{fill: f}, nil
+	}
+	vertical, err := d.Split(p)
+	if err != nil {
+		return nil, err
+	}
+	ch := t.Children()
+	if len(ch) != 2 {
+		return nil, fmt.Errorf("split %q has %d children", p.Name(), len(ch))
+	}
+	s := &Shader{vertical: vertical}
+	for i := range s.sub {
+		if s.sub[i], err = Translate(ch[i], d); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Statements setting color at point p in the rectangle
+func (s *Shader) body(b *bytes.Buffer, indent string, x1, y1, x2, y2 float64) {
+	if s.fill != nil {
+		fmt.Fprintf(b, "%scolor = %s;\n", indent, s.fill.Code(x1, y1, x2, y2))
+		return
+	}
+	if s.vertical {
+		xh := (x1 + x2) * 0.5
+		fmt.Fprintf(b, "%sif (p.x < %s) {\n", indent, lit(xh))
+		s.sub[0].body(b, indent+"\t", x1, y1, xh, y2)
+		fmt.Fprintf(b, "%s} else {\n", indent)
+		s.sub[1].body(b, indent+"\t", xh, y1, x2, y2)
+	} else {
+		yh := (y1 + y2) * 0.5
+		fmt.Fprintf(b, "%sif (p.y < %s) {\n", indent, lit(yh))
+		s.sub[0].body(b, indent+"\t", x1, y1, x2, yh)
+		fmt.Fprintf(b, "%s} else {\n", indent)
+		s.sub[1].body(b, indent+"\t", x1, yh, x2, y2)
+	}
+	fmt.Fprintf(b, "%s}\n", indent)
+}
+
+// The main function, computing p from gl_FragCoord and writing color to out
+func (s *Shader) main(out string) string {
+	var b bytes.Buffer
+	b.WriteString(prelude)
+	b.WriteString(`
+void main() {
+	// Top left corner of the pixel, with the origin in the top left corner
+	vec2 p = (vec2(gl_FragCoord.x, resolution.y - gl_FragCoord.y) - 0.5) / resolution;
+	vec3 color;
+`)
+	s.body(&b, "\t", 0, 0, 1, 1)
+	fmt.Fprintf(&b, "\t%s;\n}\n", out)
+	return b.String()
+}
+
+// Standalone fragment shader for OpenGL 3. The size of the viewport must be
+// passed in the resolution uniform
+func (s *Shader) Fragment() string {
+	return "#version 130\nuniform vec2 resolution;\nout vec3 outColor;\n" + s.main("outColor = color")
+}
+
+// Fragment shader for WebGL (GLSL ES 1.00). The size of the canvas must be
+// passed in the resolution uniform
+func (s *Shader) WebGL() string {
+	return "precision highp float;\nuniform vec2 resolution;\n" + s.main("gl_FragColor = vec4(color, 1.0)")
+}
+
+func (s *Shader) color(x, y, x1, y1, x2, y2 float64) [3]float64 {
+	switch {
+	case s.fill != nil:
+		return s.fill.Color(x, y, x1, y1, x2, y2)
+	case s.vertical:
+		if xh := (x1 + x2) * 0.5; x < xh {
+			return s.sub[0].color(x, y, x1, y1, xh, y2)
+		} else {
+			return s.sub[1].color(x, y, xh, y1, x2, y2)
+		}
+	default:
+		if yh := (y1 + y2) * 0.5; y < yh {
+			return s.sub[0].color(x, y, x1, y1, x2, yh)
+		} else {
+			return s.sub[1].color(x, y, x1, yh, x2, y2)
+		}
+	}
+}
+
+// Draw on img what the shader would draw on a viewport of the same size.
+// Colors are rounded to 8 bits like the GPU does, while the CPU renderers
+// truncate them, so they can differ by one level
+func (s *Shader) Rasterize(img *imgut.Image) {
+	to8 := func(v float64) uint8 { return uint8(math.Max(0, math.Min(1, v))*0xff + 0.5) }
+	for y := 0; y < img.H; y++ {
+		for x := 0; x < img.W; x++ {
+			c := s.color(float64(x)/float64(img.W), float64(y)/float64(img.H), 0, 0, 1, 1)
+			img.Surf.Set(x, y, color.RGBA{to8(c[0]), to8(c[1]), to8(c[2]), 0xff})
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package glsl
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/akiross/gogp/apps/base/repr/rr"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/image/draw2d/imgut"
+	"github.com/akiross/gogp/node"
+	rrepr "github.com/akiross/gogp/repr/rr"
+	"github.com/akiross/gogp/repr/split/vhs"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Fraction of pixels that differ by more than one level
+func mismatch(a, b *imgut.Image) float64 {
+	da, db := imgut.ToSlice(a), imgut.ToSlice(b)
+	bad := 0
+	for i := range da {
+		if math.Abs(da[i]-db[i]) > 1 {
+			bad++
+		}
+	}
+	return float64(bad) / float64(len(da))
+}
+
+// Draw the tree on the CPU and with the shader
+func compare(t *testing.T, tree *node.Node, d Dialect, draw func(*node.Node, *imgut.Image)) float64 {
+	s, err := Translate(tree, d)
+	if err != nil {
+		t.Fatal("Cannot translate", tree, err)
+	}
+	for _, code := range []string{s.Fragment(), s.WebGL()} {
+		if strings.Count(code, "{") != strings.Count(code, "}") {
+			t.Fatal("Unbalanced braces in shader\n", code)
+		}
+	}
+	// Splits fall on pixel boundaries up to depth 6
+	cpu, gpu := imgut.Create(64, 64, imgut.MODE_RGB), imgut.Create(64, 64, imgut.MODE_RGB)
+	draw(tree, cpu)
+	s.Rasterize(gpu)
+	return mismatch(cpu, gpu)
+}
+
+func TestRR(t *testing.T) {
+	rand.Seed(3)
+	solid := append(rr.Palette(16), rr.BlackWhite()...)
+	shades := append(append(solid, rrepr.MakeEphimeral("MakeShade", rr.MakeShadeColor)), rr.Shades(2, 2)...)
+	for i := 0; i < 20; i++ {
+		if m := compare(t, node.MakeTreeHalfAndHalf(0, 6, rr.Functionals, shades), RR, rr.Draw); m != 0 {
+			t.Error("Shader differs from CPU renderer on", m*100, "% of pixels")
+		}
+	}
+	// Diagonals are antialiased by the CPU renderer
+	diags := append(solid,
+		rrepr.MakeEphimeral("MakeDiagFill", rr.MakeDiagFill),
+		rrepr.MakeEphimeral("MakeDiagLine", rr.MakeDiagLine))
+	for i := 0; i < 20; i++ {
+		if m := compare(t, node.MakeTreeHalfAndHalf(0, 4, rr.Functionals, diags), RR, rr.Draw); m > 0.1 {
+			t.Error("Shader differs from CPU renderer on", m*100, "% of pixels")
+		}
+	}
+
+	if _, err := Translate(node.New(rrepr.MakeTerminal("L_1", rrepr.Filler(0))), RR); err == nil {
+		t.Error("Unknown terminals should not be translated")
+	}
+}
+
+func TestVHS(t *testing.T) {
+	funcs := []gp.Primitive{vhs.Functional(vhs.VSplit), vhs.Functional(vhs.HSplit)}
+	var terms []gp.Primitive
+	for i := 0; i < 5; i++ {
+		c := float64(i) / 4
+		terms = append(terms, vhs.Named(fmt.Sprint("C", i), vhs.Filler(c, 1-c, c*0.5)))
+	}
+	draw := func(t *node.Node, img *imgut.Image) {
+		node.CompileTree(t).(vhs.Terminal)(0, 0, float64(img.W), float64(img.H), img)
+	}
+	for i := 0; i < 20; i++ {
+		if m := compare(t, node.MakeTreeHalfAndHalf(0, 6, funcs, terms), VHS, draw); m != 0 {
+			t.Error("Shader differs from CPU renderer on", m*100, "% of pixels")
+		}
+	}
+
+	shade := node.New(vhs.Terminal(vhs.LinShade(0, 1, 0, 0, 1, 1)))
+	if _, err := Translate(shade, VHS); err == nil {
+		t.Error("Shaded terminals should not be translated")
+	}
+}
+
+// The GLSL emitted for each fill, since Rasterize checks only Color
+func TestFillCode(t *testing.T) {
+	cases := []struct {
+		fill           Fill
+		x1, y1, x2, y2 float64
+		code           string
+	}{
+		{Solid{1, 0.5, 0}, 0, 0, 1, 1, "vec3(1.0, 0.5, 0.0)"},
+		{Gray(0.25), 0, 0, 1, 1, "vec3(0.25, 0.25, 0.25)"},
+		{Linear{0.2, 0.8, 0.1, 0, 0.9, 1}, 0.5, 0, 1, 0.5, "linear(p, vec2(0.5, 0.5), vec2(0.1, 0.0), vec2(0.9, 1.0), 0.2, 0.8)"},
+		{Diagonal{0, 1, true}, 0, 0.5, 0.5, 1, "diagonal(p, vec4(0.0, 0.5, 0.5, 1.0), true, 0.0, 1.0)"},
+		{Line{0.5, 1, 0.2, false}, 0, 0, 1, 1, "line(p, vec4(0.0, 0.0, 1.0, 1.0), false, 0.1, 0.5, 1.0)"},
+	}
+	for _, c := range cases {
+		if code := c.fill.Code(c.x1, c.y1, c.x2, c.y2); code != c.code {
+			t.Errorf("Wrong code for %#v: expected %s, got %s", c.fill, c.code, code)
+		}
+	}
+
+	// Splits halve the rectangle passed to the fills
+	s := &Shader{vertical: true, sub: [2]*Shader{
+		{fill: Gray(0)},
+		{sub: [2]*Shader{{fill: Gray(1)}, {fill: Diagonal{0, 1, false}}}},
+	}}
+	var b bytes.Buffer
+	s.body(&b, "\t", 0, 0, 1, 1)
+	expected := `	if (p.x < 0.5) {
+		color = vec3(0.0, 0.0, 0.0);
+	} else {
+		if (p.y < 0.5) {
+			color = vec3(1.0, 1.0, 1.0);
+		} else {
+			color = diagonal(p, vec4(0.5, 0.5, 1.0, 1.0), false, 0.0, 1.0);
+		}
+	}
+`
+	if b.String() != expected {
+		t.Error("Wrong shader body\n", b.String())
+	}
+}
+
+// Shaders are compiled by glslangValidator, if available: the desktop one
+// as GLSL 1.30 and the WebGL one as GLSL ES 1.00, having no #version
+func TestCompile(t *testing.T) {
+	if _, err := exec.LookPath("glslangValidator"); err != nil {
+		t.Skip("Cannot find glslangValidator")
+	}
+	rand.Seed(5)
+	terms := append(rr.Palette(16),
+		rrepr.MakeEphimeral("MakeShade", rr.MakeShadeColor),
+		rrepr.MakeEphimeral("MakeDiagFill", rr.MakeDiagFill),
+		rrepr.MakeEphimeral("MakeDiagLine", rr.MakeDiagLine))
+	dir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		s, err := Translate(node.MakeTreeHalfAndHalf(0, 5, rr.Functionals, terms), RR)
+		if err != nil {
+			t.Fatal("Cannot translate tree:", err)
+		}
+		for name, code := range map[string]string{"desktop.frag": s.Fragment(), "webgl.frag": s.WebGL()} {
+			path := filepath.Join(dir, name)
+			if err := ioutil.WriteFile(path, []byte(code), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if out, err := exec.Command("glslangValidator", path).CombinedOutput(); err != nil {
+				t.Fatal("Invalid shader:", err, string(out), code)
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package glsl
+
+// Build a fragment shader drawing tree, a body made of nested VSPLIT,
+// HSPLIT and FILL macros, e.g. VSPLIT(FILL(vec3(0.3)), FILL(vec3(1.0))).
+// The shader expects the fragUV coordinates in [0, 1] as input
+func MakeFragmentShader(tree string) string {
+	shader := `#version 130
+in vec2 fragUV;
+out vec3 outColor;
+
+#define FILL(col) outColor = col;
+
+#define HSPLIT(above, below) \
+	if (lim.z < fragUV.y && fragUV.y <= (lim.z + lim.w) * 0.5) {\
+		lim = vec4(lim.xy, lim.z, (lim.z + lim.w) * 0.5);\
+		below\
+	}\
+	else {\
+		lim = vec4(lim.xy, (lim.z + lim.w) * 0.5, lim.w);\
+		above\
+	}
+#define VSPLIT(left, right) \
+	if (lim.x < fragUV.x && fragUV.x <= (lim.x + lim.y) * 0.5) {\
+		lim = vec4(lim.x, (lim.x + lim.y) * 0.5, lim.zw);\
+		left\
+	}\
+	else {\
+		lim = vec4((lim.x + lim.y) * 0.5, lim.y, lim.zw);\
+		right\
+	}
+void main() {
+	vec4 lim = vec4(0, 1, 0, 1);`
+
+	return shader + tree + "\n}"
+}
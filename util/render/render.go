@@ -0,0 +1,140 @@
+// Render a tree saved by evolve (e.g. log/name-tree-N.json) to a PNG image
+// of any size, without the need of a GPU, optionally writing shaders (rr, or
+// vhs with solid terminals) or source code (expr) computing it. Usage:
+//
+//	render [-repr rr|vhs|expr] [-w 512] [-h 512] [-o out.png] [-glsl out.frag] [-webgl out.frag]
+//		[-go f.go] [-c f.c] [-py f.py] tree.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/akiross/gogp/apps/base/repr/expr"
+	"github.com/akiross/gogp/apps/base/repr/rr"
+	"github.com/akiross/gogp/apps/base/repr/vhs"
+	"github.com/akiross/gogp/gp"
+	"github.com/akiross/gogp/image/draw2d/imgut"
+	"github.com/akiross/gogp/node"
+	"github.com/akiross/gogp/repr/expr/binary"
+	"github.com/akiross/gogp/repr/expr/export"
+	"github.com/akiross/gogp/util/glsl"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Primitives that can appear in the trees saved with the given representation
+func registry(repr string) (*gp.Registry, func(*node.Node, *imgut.Image), error) {
+	reg := gp.NewRegistry()
+	var prims [][]gp.Primitive
+	var draw func(*node.Node, *imgut.Image)
+	switch repr {
+	case "rr":
+		prims = [][]gp.Primitive{rr.Functionals, rr.Palette(16), rr.BlackWhite()}
+		rr.RegisterEphemerals(reg)
+		draw = rr.Draw
+	case "vhs":
+		prims = [][]gp.Primitive{vhs.Functionals, vhs.Terminals}
+		vhs.RegisterTerminals(reg)
+		draw = vhs.Draw
+	case "expr":
+		expr.AddDefaultPrimitives()
+		prims = [][]gp.Primitive{expr.Functionals, expr.Terminals}
+		binary.RegisterEphemerals(reg)
+		draw = expr.Draw
+	default:
+		return nil, nil, fmt.Errorf("unknown representation %q", repr)
+	}
+	for _, p := range prims {
+		if err := reg.Register(p...); err != nil {
+			return nil, nil, err
+		}
+	}
+	return reg, draw, nil
+}
+
+func main() {
+	repr := flag.String("repr", "rr", "Representation of the tree (rr, vhs, expr)")
+	width := flag.Int("w", 512, "Width of the rendered image")
+	height := flag.Int("h", 512, "Height of the rendered image")
+	gray := flag.Bool("gray", false, "Render a gray scale image")
+	outPath := flag.String("o", "", "Output PNG path (default is the tree path with .png extension)")
+	shaderPath := flag.String("glsl", "", "Write the OpenGL fragment shader drawing the tree to this path (rr, vhs)")
+	webglPath := flag.String("webgl", "", "Write the WebGL fragment shader drawing the tree to this path (rr, vhs)")
+	goPath := flag.String("go", "", "Write the Go function F(x, y) computing the tree to this path (expr only)")
+	cPath := flag.String("c", "", "Write the C function f(x, y) computing the tree to this path (expr only)")
+	pyPath := flag.String("py", "", "Write the Python function f(x, y) computing the tree to this path (expr only)")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "ERROR: Specify the path of one tree")
+		os.Exit(1)
+	}
+	treePath := flag.Arg(0)
+	if *outPath == "" {
+		*outPath = strings.TrimSuffix(treePath, ".json") + ".png"
+	}
+
+	reg, draw, err := registry(*repr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+	data, err := ioutil.ReadFile(treePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR: Cannot read tree:", err)
+		os.Exit(1)
+	}
+	tree, err := node.Unmarshal(data, reg.Lookup)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR: Cannot load tree:", err)
+		os.Exit(1)
+	}
+
+	mode := imgut.MODE_RGB
+	if *gray {
+		mode = imgut.MODE_G8
+	}
+	img := imgut.Create(*width, *height, mode)
+	draw(tree, img)
+	img.WritePNG(*outPath)
+
+	// Source code to write, by path
+	sources := make(map[string]string)
+	if *shaderPath != "" || *webglPath != "" {
+		dialect, ok := map[string]glsl.Dialect{"rr": glsl.RR, "vhs": glsl.VHS}[*repr]
+		if !ok {
+			fmt.Fprintln(os.Stderr, "ERROR: Shaders can be built only for rr and vhs trees")
+			os.Exit(1)
+		}
+		shader, err := glsl.Translate(tree, dialect)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: Cannot build shader:", err)
+			os.Exit(1)
+		}
+		sources[*shaderPath], sources[*webglPath] = shader.Fragment(), shader.WebGL()
+	}
+	if *goPath != "" || *cPath != "" || *pyPath != "" {
+		if *repr != "expr" {
+			fmt.Fprintln(os.Stderr, "ERROR: Only expr trees can be exported as source code")
+			os.Exit(1)
+		}
+		for path, lang := range map[string]*export.Language{*goPath: export.Go, *cPath: export.C, *pyPath: export.Python} {
+			name := "f"
+			if lang == export.Go {
+				name = "F"
+			}
+			if sources[path], err = lang.Export(tree, export.Binary, name, "x", "y"); err != nil {
+				fmt.Fprintln(os.Stderr, "ERROR: Cannot export tree:", err)
+				os.Exit(1)
+			}
+		}
+	}
+	delete(sources, "")
+	for path, code := range sources {
+		if err := ioutil.WriteFile(path, []byte(code), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: Cannot write source:", err)
+			os.Exit(1)
+		}
+	}
+}
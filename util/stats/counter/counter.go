@@ -1,16 +1,28 @@
 package counter
 
-// Counter keeps track of the frequency of binary events
+import (
+	"bytes"
+	"encoding/gob"
+	"sync"
+)
+
+// Counter keeps track of the frequency of binary events.
+// It is safe for concurrent use
 type BoolCounter struct {
+	mu                  sync.Mutex
 	trueCount, totCount int
 }
 
 func (c *BoolCounter) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.trueCount = 0
 	c.totCount = 0
 }
 
 func (c *BoolCounter) Count(v bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if v {
 		c.trueCount++
 	}
@@ -18,10 +30,14 @@ func (c *BoolCounter) Count(v bool) {
 }
 
 func (c *BoolCounter) AbsoluteFrequency() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.trueCount
 }
 
 func (c *BoolCounter) RelativeFrequency() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return float64(c.trueCount) / float64(c.totCount)
 }
 
@@ -63,3 +79,27 @@ func (c *Expected) Precision() float64 {
 func (c *Expected) Recall() float64 {
 	return float64(c.truePos) / float64(c.truePos+c.trueNeg)
 }
+
+// Exported state of the counters, used for encoding
+type boolCounterGob struct{ TrueCount, TotCount int }
+
+// Encode the counter state, e.g. to save a checkpoint
+func (c *BoolCounter) GobEncode() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&boolCounterGob{c.trueCount, c.totCount})
+	return buf.Bytes(), err
+}
+
+// Restore the counter state encoded by GobEncode
+func (c *BoolCounter) GobDecode(data []byte) error {
+	var s boolCounterGob
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.trueCount, c.totCount = s.TrueCount, s.TotCount
+	return nil
+}
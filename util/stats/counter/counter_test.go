@@ -2,6 +2,7 @@ package counter
 
 import (
 	"math/rand"
+	"sync"
 	"testing"
 )
 
@@ -50,5 +51,28 @@ func TestIntCounter(t *testing.T) {
 	}
 }
 
+func TestConcurrentCount(t *testing.T) {
+	var b BoolCounter
+	var c IntCounter
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				b.Count(j%2 == 0)
+				c.Count(i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if b.AbsoluteFrequency() != 4000 || b.RelativeFrequency() != 0.5 {
+		t.Error("Wrong bool counts", b.AbsoluteFrequency(), b.RelativeFrequency())
+	}
+	if c.TotalCounts() != 8000 || c.AbsoluteFrequency(3) != 1000 {
+		t.Error("Wrong int counts", c.TotalCounts(), c.AbsoluteFrequency(3))
+	}
+}
+
 func TestExpected(t *testing.T) {
 }
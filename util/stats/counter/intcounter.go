@@ -1,18 +1,30 @@
 package counter
 
-import "sort"
+import (
+	"bytes"
+	"encoding/gob"
+	"sort"
+	"sync"
+)
 
+// IntCounter keeps track of the frequency of integer values.
+// It is safe for concurrent use
 type IntCounter struct {
+	mu       sync.Mutex
 	intCount map[int]int
 	totCount int
 }
 
 func (c *IntCounter) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.intCount = make(map[int]int)
 	c.totCount = 0
 }
 
 func (c *IntCounter) Count(v int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if c.intCount == nil {
 		c.intCount = make(map[int]int)
 	}
@@ -22,6 +34,8 @@ func (c *IntCounter) Count(v int) {
 
 // Returns the value that have been counted (not their frequencies)
 func (c *IntCounter) Counted() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	keys := make(sort.IntSlice, len(c.intCount))
 	i := 0
 	for k := range c.intCount {
@@ -33,13 +47,45 @@ func (c *IntCounter) Counted() []int {
 }
 
 func (c *IntCounter) TotalCounts() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.totCount
 }
 
 func (c *IntCounter) AbsoluteFrequency(v int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.intCount[v]
 }
 
 func (c *IntCounter) RelativeFrequency(v int) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return float64(c.intCount[v]) / float64(c.totCount)
 }
+
+type intCounterGob struct {
+	IntCount map[int]int
+	TotCount int
+}
+
+// Encode the counter state, e.g. to save a checkpoint
+func (c *IntCounter) GobEncode() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&intCounterGob{c.intCount, c.totCount})
+	return buf.Bytes(), err
+}
+
+// Restore the counter state encoded by GobEncode
+func (c *IntCounter) GobDecode(data []byte) error {
+	var s intCounterGob
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.intCount, c.totCount = s.IntCount, s.TotCount
+	return nil
+}
@@ -1,5 +1,10 @@
 package max
 
+import (
+	"bytes"
+	"encoding/gob"
+)
+
 type Max struct {
 	max           float64
 	count, maxIdx int // how many values visited, which one was the maximum
@@ -42,3 +47,25 @@ func (m *Max) GetId() int {
 func (m *Max) Count() int {
 	return m.count
 }
+
+type maxGob struct {
+	Value        float64
+	Count, Index int
+}
+
+// Encode the observations, e.g. to save a checkpoint
+func (m *Max) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&maxGob{m.max, m.count, m.maxIdx})
+	return buf.Bytes(), err
+}
+
+// Restore the observations encoded by GobEncode
+func (m *Max) GobDecode(data []byte) error {
+	var s maxGob
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	m.max, m.count, m.maxIdx = s.Value, s.Count, s.Index
+	return nil
+}
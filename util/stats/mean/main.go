@@ -1,5 +1,10 @@
 package mean
 
+import (
+	"bytes"
+	"encoding/gob"
+)
+
 type Mean struct {
 	count int     // Number of accumulations
 	acc   float64 // Accumulated values
@@ -30,3 +35,25 @@ func (m *Mean) Count() int {
 func (m *Mean) PartialMean() float64 {
 	return m.acc / float64(m.count)
 }
+
+type meanGob struct {
+	Count int
+	Acc   float64
+}
+
+// Encode the accumulated values, e.g. to save a checkpoint
+func (m *Mean) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&meanGob{m.count, m.acc})
+	return buf.Bytes(), err
+}
+
+// Restore the accumulated values encoded by GobEncode
+func (m *Mean) GobDecode(data []byte) error {
+	var s meanGob
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	m.count, m.acc = s.Count, s.Acc
+	return nil
+}
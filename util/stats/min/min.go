@@ -1,5 +1,10 @@
 package min
 
+import (
+	"bytes"
+	"encoding/gob"
+)
+
 type Min struct {
 	min           float64
 	count, minIdx int // how many values visited, which one was the minimum
@@ -42,3 +47,25 @@ func (m *Min) GetId() int {
 func (m *Min) Count() int {
 	return m.count
 }
+
+type minGob struct {
+	Value        float64
+	Count, Index int
+}
+
+// Encode the observations, e.g. to save a checkpoint
+func (m *Min) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&minGob{m.min, m.count, m.minIdx})
+	return buf.Bytes(), err
+}
+
+// Restore the observations encoded by GobEncode
+func (m *Min) GobDecode(data []byte) error {
+	var s minGob
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	m.min, m.count, m.minIdx = s.Value, s.Count, s.Index
+	return nil
+}
@@ -1,12 +1,18 @@
 package sequence
 
 import (
+	"bytes"
+	"encoding/gob"
 	"github.com/akiross/gogp/util/stats/max"
 	"github.com/akiross/gogp/util/stats/min"
 	"github.com/akiross/gogp/util/stats/variance"
+	"sync"
 )
 
+// SequenceStats can be observed and cleared concurrently. Reading the
+// embedded Min and Max must not happen while observations are made
 type SequenceStats struct {
+	mu sync.Mutex
 	min.Min
 	max.Max
 	variance.Variance
@@ -17,6 +23,8 @@ func Create() *SequenceStats {
 }
 
 func (ss *SequenceStats) Observe(val float64) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
 	ss.Min.Observe(val)
 	ss.Max.Observe(val)
 	ss.Variance.Accumulate(val)
@@ -24,7 +32,31 @@ func (ss *SequenceStats) Observe(val float64) {
 
 // Clear all the observations
 func (ss *SequenceStats) Clear() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
 	ss.Min.Clear()
 	ss.Max.Clear()
 	ss.Variance.Reset()
 }
+
+type sequenceGob struct {
+	Min      *min.Min
+	Max      *max.Max
+	Variance *variance.Variance
+}
+
+// Encode the observations, e.g. to save a checkpoint
+func (ss *SequenceStats) GobEncode() ([]byte, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&sequenceGob{&ss.Min, &ss.Max, &ss.Variance})
+	return buf.Bytes(), err
+}
+
+// Restore the observations encoded by GobEncode
+func (ss *SequenceStats) GobDecode(data []byte) error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(&sequenceGob{&ss.Min, &ss.Max, &ss.Variance})
+}
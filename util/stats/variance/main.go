@@ -1,8 +1,15 @@
 package variance
 
-import "github.com/akiross/gogp/util/stats/mean"
+import (
+	"bytes"
+	"encoding/gob"
+	"github.com/akiross/gogp/util/stats/mean"
+	"sync"
+)
 
+// Variance is safe for concurrent use
 type Variance struct {
+	mu        sync.Mutex
 	mean.Mean         // Embedded mean
 	sqAcc     float64 // Squared accumulator
 }
@@ -13,25 +20,77 @@ func Create() *Variance {
 
 // Reset clearning everything accumulated
 func (v *Variance) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	v.Mean.Reset()
 	v.sqAcc = 0
 }
 
 // Accumulate value to compute mean and variance
 func (v *Variance) Accumulate(val float64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
 	v.Mean.Accumulate(val)
 	v.sqAcc += val * val
 }
 
+// Number of accumulated values
+func (v *Variance) Count() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.Mean.Count()
+}
+
+// Compute mean for the values accumulated until now
+func (v *Variance) PartialMean() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.Mean.PartialMean()
+}
+
+func (v *Variance) partialVar() float64 {
+	mean := v.Mean.PartialMean()
+	return v.sqAcc/float64(v.Mean.Count()) - mean*mean
+}
+
 // Compute variance for the values accumulated until now
 func (v *Variance) PartialVar() float64 {
-	mean := v.PartialMean()
-	return v.sqAcc/float64(v.Count()) - mean*mean
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.partialVar()
 }
 
 // Bessel-corrected version of PartialVar
 // To be used when working with samples, and real mean is unknown
 func (v *Variance) PartialVarBessel() float64 {
-	n := float64(v.Count())
-	return v.PartialVar() * n / (n - 1.0)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	n := float64(v.Mean.Count())
+	return v.partialVar() * n / (n - 1.0)
+}
+
+type varianceGob struct {
+	Mean  *mean.Mean
+	SqAcc float64
+}
+
+// Encode the accumulated values, e.g. to save a checkpoint
+func (v *Variance) GobEncode() ([]byte, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&varianceGob{&v.Mean, v.sqAcc})
+	return buf.Bytes(), err
+}
+
+// Restore the accumulated values encoded by GobEncode
+func (v *Variance) GobDecode(data []byte) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s := varianceGob{Mean: &v.Mean}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s); err != nil {
+		return err
+	}
+	v.sqAcc = s.SqAcc
+	return nil
 }
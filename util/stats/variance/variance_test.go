@@ -32,3 +32,22 @@ func TestVariance(t *testing.T) {
 		v.Reset()
 	}
 }
+
+func TestGob(t *testing.T) {
+	v := Create()
+	for _, val := range []float64{600, 470, 170, 430, 300} {
+		v.Accumulate(val)
+	}
+	data, err := v.GobEncode()
+	if err != nil {
+		t.Fatal("Cannot encode:", err)
+	}
+	w := Create()
+	if err := w.GobDecode(data); err != nil {
+		t.Fatal("Cannot decode:", err)
+	}
+	if w.Count() != v.Count() || w.PartialMean() != v.PartialMean() || w.PartialVar() != v.PartialVar() {
+		t.Errorf("Decoded variance (%v, %v, %v) differs from (%v, %v, %v)",
+			w.Count(), w.PartialMean(), w.PartialVar(), v.Count(), v.PartialMean(), v.PartialVar())
+	}
+}
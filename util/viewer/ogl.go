@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/akiross/gogloo"
+	"github.com/akiross/gogp/util/glsl"
 	"github.com/go-gl/gl/v4.1-core/gl"
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/veandco/go-sdl2/sdl_image"
@@ -17,37 +18,6 @@ var vertShader, fragShader *gogloo.ShaderObject
 var canvasVao *gogloo.VertexArrayObject
 var shadProg *gogloo.ProgramObject
 
-func MakeFragmentShader(tree string) string {
-	shader := `#version 130
-in vec2 fragUV;
-out vec3 outColor;
-
-#define FILL(col) outColor = col;
-
-#define HSPLIT(above, below) \
-	if (lim.z < fragUV.y && fragUV.y <= (lim.z + lim.w) * 0.5) {\
-		lim = vec4(lim.xy, lim.z, (lim.z + lim.w) * 0.5);\
-		below\
-	}\
-	else {\
-		lim = vec4(lim.xy, (lim.z + lim.w) * 0.5, lim.w);\
-		above\
-	}
-#define VSPLIT(left, right) \
-	if (lim.x < fragUV.x && fragUV.x <= (lim.x + lim.y) * 0.5) {\
-		lim = vec4(lim.x, (lim.x + lim.y) * 0.5, lim.zw);\
-		left\
-	}\
-	else {\
-		lim = vec4((lim.x + lim.y) * 0.5, lim.x, lim.zw);\
-		right\
-	}
-void main() {
-	vec4 lim = vec4(0, 1, 0, 1);`
-
-	return shader + tree + "\n}"
-}
-
 func checkGLError(where string) {
 	switch gl.GetError() {
 	case gl.NO_ERROR:
@@ -276,7 +246,7 @@ void main() {
 	fragUV = vertUV;
 }
 `
-	fragShaderSrc := MakeFragmentShader("HSPLIT(VSPLIT(FILL(vec3(0.3)), FILL(vec3(1.0))), HSPLIT(VSPLIT(FILL(vec3(1, 0, 0)), FILL(vec3(0, 0, 1))), FILL(vec3(1, 1, 0))))")
+	fragShaderSrc := glsl.MakeFragmentShader("HSPLIT(VSPLIT(FILL(vec3(0.3)), FILL(vec3(1.0))), HSPLIT(VSPLIT(FILL(vec3(1, 0, 0)), FILL(vec3(0, 0, 1))), FILL(vec3(1, 1, 0))))")
 	glslCompStartTime := time.Now()
 
 	vertShader = gogloo.CreateShader("VShad", vertShaderSrc, gl.VERTEX_SHADER)